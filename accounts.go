@@ -0,0 +1,63 @@
+package main
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/services"
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selectAccounts platformda birden fazla mağaza varsa işlemin hangisinde yapılacağını sorar.
+// Boş, "0" ya da "hepsi" tüm mağazaları seçer; geçersiz seçimde nil döner.
+func selectAccounts[S any](platform string, accounts []S, key func(S) string, reader *bufio.Reader) []S {
+	if len(accounts) <= 1 {
+		return accounts
+	}
+
+	fmt.Printf("\n%s mağazaları:\n", platform)
+	for i, a := range accounts {
+		fmt.Printf("%d- %s\n", i+1, core.AccountIDOf(key(a)))
+	}
+	choice := askInput("Mağaza seçin (0/hepsi: tüm mağazalar): ", reader)
+	if choice == "" || choice == "0" || strings.EqualFold(choice, "hepsi") {
+		return accounts
+	}
+	if i, err := strconv.Atoi(choice); err == nil && i >= 1 && i <= len(accounts) {
+		return accounts[i-1 : i]
+	}
+	fmt.Println("Geçersiz seçim!")
+	return nil
+}
+
+// accountsLabel menü başlığında seçili mağazayı gösterir; tek mağazalı kurulumda boştur
+func accountsLabel[S any](selected, all []S, key func(S) string) string {
+	switch {
+	case len(all) <= 1:
+		return ""
+	case len(selected) == 1:
+		return " [" + core.AccountIDOf(key(selected[0])) + "]"
+	default:
+		return " [TÜM MAĞAZALAR]"
+	}
+}
+
+// forAccounts işlemi seçilen her mağazada sırayla çalıştırır; bir mağazadaki hata diğerlerini durdurmaz
+func forAccounts[S any](ctx context.Context, accounts []S, key func(S) string, run func(S) error) {
+	for _, a := range accounts {
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] İşlem kapanış nedeniyle kesildi.")
+			return
+		}
+		if len(accounts) > 1 {
+			fmt.Printf("\n[*] Mağaza: %s\n", key(a))
+		}
+		report(run(a))
+	}
+}
+
+func hbKey(s *services.HBService) string        { return s.Key }
+func pzrKey(s *services.PazaramaService) string { return s.Key }
+func pttKey(s *services.PttService) string      { return s.Key }
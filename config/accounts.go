@@ -0,0 +1,44 @@
+package config
+
+import (
+	"arbitraj-bot/core"
+	"fmt"
+	"strings"
+)
+
+// normalizeAccounts boş hesap listelerini tek varsayılan hesapla doldurur ve hesap id'lerini doğrular.
+// Aynı id iki kez kullanılırsa iki mağaza aynı DB kayıtlarını paylaşırdı.
+func normalizeAccounts(cfg *core.Config) error {
+	if len(cfg.Pazarama) == 0 {
+		cfg.Pazarama = core.Accounts[core.PazaramaConfig]{{}}
+	}
+	if len(cfg.Hepsiburada) == 0 {
+		cfg.Hepsiburada = core.Accounts[core.HepsiburadaConfig]{{}}
+	}
+	if len(cfg.Ptt) == 0 {
+		cfg.Ptt = core.Accounts[core.PttConfig]{{}}
+	}
+
+	var keys []string
+	for _, a := range cfg.Pazarama {
+		keys = append(keys, a.Key())
+	}
+	for _, a := range cfg.Hepsiburada {
+		keys = append(keys, a.Key())
+	}
+	for _, a := range cfg.Ptt {
+		keys = append(keys, a.Key())
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if strings.Count(key, ":") > 1 || strings.HasSuffix(key, ":") {
+			return fmt.Errorf("geçersiz hesap id'si: %s (':' içeremez)", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("hesap id'si birden fazla kez tanımlı: %s", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 )
 
+// LoadConfig config.json'u okur ve secret referanslarını (env:/secret:) çözer.
+// Çözülen değerler yalnızca bellekte tutulur; config dosyaya geri yazılmaz.
 func LoadConfig(path string) (core.Config, error) {
 	var config core.Config
 	file, err := os.Open(path)
@@ -13,6 +15,12 @@ func LoadConfig(path string) (core.Config, error) {
 		return config, err
 	}
 	defer file.Close()
-	err = json.NewDecoder(file).Decode(&config)
-	return config, err
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return config, err
+	}
+	if err := normalizeAccounts(&config); err != nil {
+		return config, err
+	}
+	return config, resolveSecrets(&config, SecretsPath(path))
+
 }
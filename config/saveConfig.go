@@ -1,19 +0,0 @@
-package config
-
-import (
-	"arbitraj-bot/core"
-	"encoding/json"
-	"os"
-)
-
-func SaveConfig(path string, config core.Config) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(config)
-}
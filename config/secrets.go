@@ -0,0 +1,261 @@
+package config
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/utils"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// config.json'daki secret alanları düz değer yerine referans tutabilir:
+//
+//	"client_secret": "env:PAZARAMA_CLIENT_SECRET"   -> ortam değişkeninden
+//	"client_secret": "secret:pazarama_client_secret" -> config klasöründeki şifreli secrets.enc dosyasından
+const (
+	envPrefix    = "env:"
+	secretPrefix = "secret:"
+
+	// PassphraseEnv secrets.enc parolasının okunduğu ortam değişkeni; tanımlı değilse parola konsoldan sorulur
+	PassphraseEnv = "ARBITRAJ_SECRETS_PASSPHRASE"
+
+	secretsFileName  = "secrets.enc"
+	pbkdf2Iterations = 600_000
+)
+
+// ErrWrongPassphrase parola yanlışsa ya da dosya bozulmuşsa döner (GCM doğrulaması ikisini ayırt edemez)
+var ErrWrongPassphrase = errors.New("secrets dosyası çözülemedi: parola yanlış ya da dosya bozuk")
+
+// secretsFile diskteki biçim; []byte alanlar JSON'da base64 olarak yazılır
+type secretsFile struct {
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+type secretField struct {
+	name  string
+	value *string
+}
+
+// secretFields referans çözülen ve loglarda maskelenen config alanları
+func secretFields(cfg *core.Config) []secretField {
+	var fields []secretField
+	for i := range cfg.Pazarama {
+		a := &cfg.Pazarama[i]
+		fields = append(fields,
+			secretField{fieldName("pazarama", a.ID, "client_id"), &a.ClientID},
+			secretField{fieldName("pazarama", a.ID, "client_secret"), &a.ClientSecret},
+		)
+	}
+	for i := range cfg.Hepsiburada {
+		a := &cfg.Hepsiburada[i]
+		fields = append(fields, secretField{fieldName("hepsiburada", a.ID, "api_secret"), &a.ApiSecret})
+	}
+	for i := range cfg.Ptt {
+		a := &cfg.Ptt[i]
+		fields = append(fields,
+			secretField{fieldName("ptt", a.ID, "username"), &a.Username},
+			secretField{fieldName("ptt", a.ID, "password"), &a.Password},
+			secretField{fieldName("ptt", a.ID, "panel_psswd"), &a.PanelPasswd},
+			secretField{fieldName("ptt", a.ID, "token"), &a.Token},
+		)
+	}
+	return fields
+}
+
+// fieldName uyarı ve hata mesajlarındaki alan adı (Örn: "pazarama.client_secret", "pazarama[magaza2].client_secret")
+func fieldName(platform, accountID, field string) string {
+	if accountID == "" {
+		return platform + "." + field
+	}
+	return fmt.Sprintf("%s[%s].%s", platform, accountID, field)
+}
+
+// SecretsPath config dosyasının yanındaki şifreli secrets dosyasının yolu
+func SecretsPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), secretsFileName)
+}
+
+// resolveSecrets env:/secret: referanslarını gerçek değerlerle değiştirir ve tüm secret'ları maskelemeye kaydeder.
+// secrets.enc yalnızca en az bir secret: referansı varsa açılır.
+func resolveSecrets(cfg *core.Config, secretsPath string) error {
+	var stored map[string]string
+	for _, f := range secretFields(cfg) {
+		raw := strings.TrimSpace(*f.value)
+		switch {
+		case raw == "":
+			continue
+		case strings.HasPrefix(raw, envPrefix):
+			name := strings.TrimPrefix(raw, envPrefix)
+			v, ok := os.LookupEnv(name)
+			if !ok || v == "" {
+				return fmt.Errorf("%s: %s ortam değişkeni tanımlı değil", f.name, name)
+			}
+			*f.value = v
+		case strings.HasPrefix(raw, secretPrefix):
+			if stored == nil {
+				passphrase, err := Passphrase()
+				if err != nil {
+					return err
+				}
+				if stored, err = LoadSecrets(secretsPath, passphrase); err != nil {
+					return err
+				}
+			}
+			name := strings.TrimPrefix(raw, secretPrefix)
+			v, ok := stored[name]
+			if !ok {
+				return fmt.Errorf("%s: '%s' secrets dosyasında yok (arbitraj-bot --secret-set %s ile ekleyin)", f.name, name, name)
+			}
+			*f.value = v
+		default:
+			log.Printf("[UYARI] config.json'da düz metin secret: %s (env: ya da secret: referansı kullanın)", f.name)
+		}
+		utils.RegisterSecret(*f.value)
+	}
+	return nil
+}
+
+// LoadSecrets şifreli secrets dosyasını çözer; dosya yoksa boş liste döner
+func LoadSecrets(path, passphrase string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file secretsFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("secrets dosyası okunamadı: %w", err)
+	}
+	gcm, err := newGCM(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("secrets dosyası okunamadı: %w", err)
+	}
+	return secrets, nil
+}
+
+// SaveSecrets listeyi her kayıtta yeni salt ve nonce ile şifreleyip yalnızca sahibinin okuyabileceği şekilde yazar
+func SaveSecrets(path, passphrase string, secrets map[string]string) error {
+	file := secretsFile{Iterations: pbkdf2Iterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Yarım yazılmış dosya tüm secret'ları kaybettirir; önce geçici dosyaya yazıp yerine taşıyoruz
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SecretNames dosyadaki secret adlarını sıralı döndürür (değerleri göstermeden listelemek için)
+func SecretNames(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Passphrase parolayı ortam değişkeninden, yoksa konsoldan okur
+func Passphrase() (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	fmt.Print("[?] Secrets dosyası parolası: ")
+	p := ReadPassword()
+	if p == "" {
+		return "", fmt.Errorf("secrets parolası girilmedi (%s ortam değişkeni de tanımlanabilir)", PassphraseEnv)
+	}
+	return p, nil
+}
+
+// ReadLine stdin'den tek satır okur. Menünün bufio.Reader'ı sonradan açıldığından tampon kullanmıyoruz;
+// aksi halde parolanın arkasından gelen girdi burada kalırdı.
+func ReadLine() string {
+	var sb strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 0 || err != nil || buf[0] == '\n' {
+			break
+		}
+		sb.WriteByte(buf[0])
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// ReadPassword konsoldan yazılanı ekrana basmadan okur; stdin terminal değilse (pipe, dosya) ReadLine'a düşer
+func ReadPassword() string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return ReadLine()
+	}
+	b, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 {
+		iterations = pbkdf2Iterations
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Her pazaryerinde birden fazla mağaza (hesap) tanımlanabilir. DB'deki platform sütunlarına hesabın anahtarı yazılır:
+// id'si boş ya da "default" olan hesap düz platform adını ("hb", "pazarama", "ptt") kullanır, böylece tek hesaplı
+// kurulumdan kalan kayıtlar olduğu gibi geçerli kalır; diğer hesaplar "pazarama:magaza2" biçiminde tutulur.
+const (
+	DefaultAccountID = "default"
+	accountSeparator = ":"
+)
+
+// AccountKey hesabın DB'deki platform anahtarı
+func AccountKey(platform, accountID string) string {
+	if accountID == "" || accountID == DefaultAccountID {
+		return platform
+	}
+	return platform + accountSeparator + accountID
+}
+
+// PlatformOf hesap anahtarının pazaryeri kısmı ("pazarama:magaza2" -> "pazarama")
+func PlatformOf(key string) string {
+	platform, _, _ := strings.Cut(key, accountSeparator)
+	return platform
+}
+
+// AccountIDOf hesap anahtarının hesap kısmı; varsayılan hesap için DefaultAccountID
+func AccountIDOf(key string) string {
+	if _, id, ok := strings.Cut(key, accountSeparator); ok {
+		return id
+	}
+	return DefaultAccountID
+}
+
+// IsDefaultAccount anahtar varsayılan hesaba mı ait (products tablosundaki platform sütunlarını kullanan hesap)
+func IsDefaultAccount(key string) bool {
+	return !strings.Contains(key, accountSeparator)
+}
+
+// Accounts config'de hesap listesi; eski tek hesaplı biçim ({...}) de tek elemanlı liste olarak okunur
+type Accounts[T any] []T
+
+func (a *Accounts[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var single T
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*a = Accounts[T]{single}
+		return nil
+	}
+
+	var list []T
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
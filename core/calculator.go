@@ -9,6 +9,23 @@ import (
 )
 
 func CalculateNewPrice(currentPrice float64, operation string) float64 {
+	newPrice := ApplyPriceOperation(currentPrice, operation)
+
+	// 3. Durum: Emniyet Kilidi (2 katı üstü veya %50 altı)
+	if newPrice > currentPrice*2 || (newPrice < currentPrice*0.5 && newPrice != 0) {
+		// fmt.Printf ile ekranda hangi üründe takıldığımızı kullanıcıya hatırlatmak iyi olur
+		msg := fmt.Sprintf("\n[!] KRİTİK FİYAT DEĞİŞİMİ: %.2f TL -> %.2f TL. Onaylıyor musunuz?", currentPrice, newPrice)
+		if !AskConfirmation(msg) {
+			fmt.Println("[x] Değişiklik reddedildi, eski fiyat korunuyor.")
+			return currentPrice
+		}
+	}
+
+	return newPrice
+}
+
+// ApplyPriceOperation işlemi (Örn: "*1.2", "+10", "149.90") onay sormadan uygular
+func ApplyPriceOperation(currentPrice float64, operation string) float64 {
 	operation = strings.TrimSpace(operation)
 	// Virgül kullanılmışsa noktaya çevir (Hata payını azaltır)
 	operation = strings.ReplaceAll(operation, ",", ".")
@@ -52,16 +69,6 @@ func CalculateNewPrice(currentPrice float64, operation string) float64 {
 		}
 	}
 
-	// 3. Durum: Emniyet Kilidi (2 katı üstü veya %50 altı)
-	if newPrice > currentPrice*2 || (newPrice < currentPrice*0.5 && newPrice != 0) {
-		// fmt.Printf ile ekranda hangi üründe takıldığımızı kullanıcıya hatırlatmak iyi olur
-		msg := fmt.Sprintf("\n[!] KRİTİK FİYAT DEĞİŞİMİ: %.2f TL -> %.2f TL. Onaylıyor musunuz?", currentPrice, newPrice)
-		if !AskConfirmation(msg) {
-			fmt.Println("[x] Değişiklik reddedildi, eski fiyat korunuyor.")
-			return currentPrice
-		}
-	}
-
 	return newPrice
 }
 
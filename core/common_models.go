@@ -1,37 +1,71 @@
 package core
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"time"
+)
 
 // --- CONFIG YAPILARI ---
+// Hesap yapılarındaki ID mağazayı ayırt eder (boş: varsayılan hesap), Markup o mağazanın tüm fiyatlarına
+// ürünün platform marjının üzerine uygulanan çarpandır (0: uygulanmaz).
 type PazaramaConfig struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
+	ID           string  `json:"id,omitempty"`
+	ClientID     string  `json:"client_id"`
+	ClientSecret string  `json:"client_secret"`
+	Markup       float64 `json:"markup,omitempty"`
 }
 
+func (c PazaramaConfig) Key() string { return AccountKey("pazarama", c.ID) }
+
 type HepsiburadaConfig struct {
-	MerchantID string `json:"merchant_id"`
-	ApiSecret  string `json:"api_secret"`
-	UserAgent  string `json:"user_agent"`
+	ID         string  `json:"id,omitempty"`
+	MerchantID string  `json:"merchant_id"`
+	ApiSecret  string  `json:"api_secret"`
+	UserAgent  string  `json:"user_agent"`
+	Markup     float64 `json:"markup,omitempty"`
 }
 
+func (c HepsiburadaConfig) Key() string { return AccountKey("hb", c.ID) }
+
 type PttConfig struct {
+	ID          string `json:"id,omitempty"`
 	Username    string `json:"username"`
 	Password    string `json:"password"`
 	PanelEmail  string `json:"panel_email"`
 	PanelPasswd string `json:"panel_psswd"`
-	Token       string `json:"token"`
+	Token       string `json:"token"` // Elle yapıştırılan eski token; yalnızca kayıtlı panel oturumu yoksa kullanılır
+
+	// Panel girişindeki OTP kodunun kaynağı: "prompt" (varsayılan, konsoldan sorar), "file" ya da "http"
+	OtpProvider string `json:"otp_provider,omitempty"`
+	OtpFile     string `json:"otp_file,omitempty"` // file: kodun yazılacağı dosya (Örn: SMS yönlendirme betiği yazar)
+	OtpURL      string `json:"otp_url,omitempty"`  // http: ?otpId=... ile sorgulanan, kod gelince 200 dönen adres
+
+	Markup float64 `json:"markup,omitempty"`
 }
 
+func (c PttConfig) Key() string { return AccountKey("ptt", c.ID) }
+
 type Config struct {
-	Pazarama    PazaramaConfig    `json:"pazarama"`
-	Hepsiburada HepsiburadaConfig `json:"hepsiburada"`
-	Ptt         PttConfig         `json:"ptt"`
+	// Her pazaryeri tek hesap ({...}) ya da hesap listesi ([{...}, {"id": "magaza2", ...}]) olarak yazılabilir
+	Pazarama    Accounts[PazaramaConfig]    `json:"pazarama"`
+	Hepsiburada Accounts[HepsiburadaConfig] `json:"hepsiburada"`
+	Ptt         Accounts[PttConfig]         `json:"ptt"`
+
+	// Alan bazlı veri sahipliği (Örn: "price": "master", "stock": "supplier", "images": "richest")
+	MergePolicy map[string]string `json:"merge_policy,omitempty"`
+
+	// true ise pazaryerlerine yazma istekleri gönderilmez, dry_run_requests tablosuna kaydedilir
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Fiyat/stok gönderiminden kaç saniye sonra ilanın geri okunup doğrulanacağı (0: varsayılan 120 sn)
+	VerifyDelaySeconds int `json:"verify_delay_seconds,omitempty"`
 }
 
 // --- PAZARAMA MODELLERİ ---
 type PazaramaAuthResponse struct {
 	Data struct {
 		AccessToken string `json:"accessToken"`
+		ExpiresIn   int    `json:"expiresIn"`
 	} `json:"data"`
 }
 
@@ -68,6 +102,10 @@ type PttProduct struct {
 	KategoriId     int
 	Aciklama       string
 	Gorseller      []string
+
+	// Varyant gruplama (Renk/Beden gibi eksenler aynı grup kodu altında toplanır)
+	GroupCode         string
+	VariantAttributes []VariantAttribute
 }
 
 type PttStockPriceUpdate struct {
@@ -100,10 +138,15 @@ type PttLoginResponse struct {
 		OtpId        string `json:"otpId"`
 		AccessToken  string `json:"accessToken"`  // Bazen gövdede gelebilir
 		RefreshToken string `json:"refreshToken"` // Bazen gövdede gelebilir
+		ExpiresIn    int    `json:"expiresIn"`    // Saniye; gelmezse JWT'deki exp kullanılır
 	} `json:"data"`
 	IsSuccess bool `json:"isSuccess"`
 }
 
+type PttRefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 // MasterProduct: Excel'den yükleyeceğimiz temiz veriler için
 type MasterProduct struct {
 	SKU         string
@@ -221,6 +264,8 @@ type HBAttribute struct {
 	Name      string `json:"name"`
 	Mandatory bool   `json:"mandatory"`
 	Type      string `json:"type"` // Enum veya String
+
+	IsVariant bool `json:"-"` // variantAttributes listesinden geldiyse true
 }
 
 type HBAttributeValue struct {
@@ -279,4 +324,283 @@ type Product struct {
 	HbMarkup       float64 `db:"hb_markup"`
 	PazaramaMarkup float64 `db:"pazarama_markup"`
 	PttMarkup      float64 `db:"ptt_markup"`
+
+	// Ürüne bağlı tüm pazaryeri ilanları (listings tablosundan doldurulur)
+	Listings []Listing `db:"-"`
+}
+
+// --- SİPARİŞ VE SATIN ALMA MODELLERİ ---
+
+// OrderLine: Herhangi bir pazaryerinden gelen tek bir sipariş satırı
+type OrderLine struct {
+	Platform    string  `db:"platform"`     // 'hb', 'pazarama', 'ptt'
+	OrderNumber string  `db:"order_number"` // Platformun sipariş numarası
+	Barcode     string  `db:"barcode"`      // Master barkod
+	Quantity    int     `db:"quantity"`
+	UnitPrice   float64 `db:"unit_price"`
+	OrderDate   string  `db:"order_date"` // 'YYYY-MM-DD HH:MM:SS'
+}
+
+// SupplierInfo: Bir ürünün hangi tedarikçiden, hangi koşullarla alındığı
+type SupplierInfo struct {
+	Barcode      string  `db:"barcode"`
+	SupplierName string  `db:"supplier_name"`
+	SupplierSku  string  `db:"supplier_sku"`
+	UnitCost     float64 `db:"unit_cost"`
+	MinOrderQty  int     `db:"min_order_qty"`
+	LeadTimeDays int     `db:"lead_time_days"`
+
+	// Tedarikçinin bildirdiği stok (Excel'de sütun boşsa StockReported=false)
+	Stock         int  `db:"-"`
+	StockReported bool `db:"-"`
+}
+
+// ReorderSuggestion: Satış hızına göre hesaplanan satın alma önerisi
+type ReorderSuggestion struct {
+	SupplierName  string
+	SupplierSku   string
+	Barcode       string
+	ProductName   string
+	Stock         int
+	DailyVelocity float64 // Tüm kanallardan günlük ortalama satış
+	DaysOfCover   float64 // Mevcut stok kaç gün yeter (-1: satış yok)
+	SuggestedQty  int
+	UnitCost      float64
+	TotalCost     float64
+}
+
+// SalesSummary: Gerçek + tahmini satışlardan hesaplanan ürün bazlı kârlılık
+type SalesSummary struct {
+	Barcode      string
+	ProductName  string
+	Quantity     int // Toplam satış (gerçek + tahmini)
+	EstimatedQty int // Bunun ne kadarı stok düşüşünden tahmin edildi
+	Revenue      float64
+	Cost         float64
+	Profit       float64
+}
+
+// --- PAKET / SET MODELLERİ ---
+
+// Bundle: Tekil barkodlardan oluşan 2'li paket veya karışık set
+type Bundle struct {
+	BundleBarcode string
+	PriceRule     string // Bileşen toplamına uygulanacak işlem (Örn: "*0.95", "+10"). Boşsa toplam fiyat
+	Components    []BundleComponent
+}
+
+type BundleComponent struct {
+	Barcode  string
+	Quantity int
+}
+
+// --- VARYANT MODELLERİ ---
+
+// VariantInfo: Bir ürünün ait olduğu varyant grubu ve grubu ayıran eksen değerleri (Renk, Beden vb.)
+type VariantInfo struct {
+	Barcode    string
+	GroupCode  string
+	Attributes []VariantAttribute
+}
+
+type VariantAttribute struct {
+	Name  string // Örn: 'Renk'
+	Value string // Örn: 'Kırmızı'
+}
+
+// CategoryDefault: Kategorinin zorunlu özelliği için hafızaya alınmış varsayılan değer
+type CategoryDefault struct {
+	AttributeID   string
+	AttributeName string
+	ValueID       string
+	ValueName     string
+}
+
+// HBImportStatus: Hepsiburada ürün yükleme (trackingId) sonucunun ürün bazlı satırı
+type HBImportStatus struct {
+	MerchantSku       string `json:"merchantSku"`
+	HbSku             string `json:"hbSku"`
+	ProductStatus     string `json:"productStatus"`
+	ValidationResults []struct {
+		AttributeName string `json:"attributeName"`
+		Message       string `json:"message"`
+	} `json:"validationResults"`
+}
+
+// PttUploadResponse: UpdateProductsV3 SOAP yanıtı (paket ve ürün bazlı sonuçlar)
+type PttUploadResponse struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Fault   string   `xml:"Body>Fault>faultstring"`
+	Result  struct {
+		Success    bool               `xml:"Success"`
+		TrackingId string             `xml:"TrackingId"`
+		Message    string             `xml:"Message"`
+		Items      []PttProductResult `xml:"ProductResults>ProductV3Response"`
+	} `xml:"Body>UpdateProductsV3Response>UpdateProductsV3Result"`
+}
+
+// PttProductResult: yükleme ve takip yanıtlarındaki ürün bazlı sonuç
+type PttProductResult struct {
+	Barcode   string `xml:"Barcode"`
+	Success   bool   `xml:"Success"`
+	Message   string `xml:"Message"`
+	ProductId int64  `xml:"ProductId"` // Kabul edilen ürünün PTT UrunId'si (yanıtta varsa)
+}
+
+// PttTrackingResponse: UpdateProductsV3 trackingId sorgusunun SOAP yanıtı
+type PttTrackingResponse struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Fault   string   `xml:"Body>Fault>faultstring"`
+	Result  struct {
+		Completed bool               `xml:"IsCompleted"`
+		Message   string             `xml:"Message"`
+		Items     []PttProductResult `xml:"ProductResults>ProductV3Response"`
+	} `xml:"Body>GetTrackingResultV3Response>GetTrackingResultV3Result"`
+}
+
+// PazaramaBatchResult: getProductBatchResult yanıtı
+type PazaramaBatchResult struct {
+	Data struct {
+		Status      int `json:"status"` // 1: İşleniyor, 2: Tamamlandı
+		FailedCount int `json:"failedCount"`
+		BatchResult []struct {
+			Reason string `json:"reason"`
+			Code   string `json:"code"`
+		} `json:"batchResult"`
+	} `json:"data"`
+	Success bool `json:"success"`
+}
+
+// PublishRejection: Pazaryerinin reddettiği ürün ve sebebi
+type PublishRejection struct {
+	Platform     string
+	BatchID      string
+	Barcode      string // Master barkod
+	PlatformCode string // Platforma gönderilen kod (Örn: -PZR ekli)
+	Reason       string
+	CreatedAt    string
+}
+
+// --- ARKA PLAN İŞLERİ ---
+
+// Job: Pazaryerine gönderilmiş bir paketin sonucunu takip eden kalıcı iş
+type Job struct {
+	ID         int64
+	Kind       string // 'pazarama_batch', 'hb_import'
+	Platform   string
+	ExternalID string // batchRequestId / trackingId
+	Status     string // QUEUED, RUNNING, DONE, FAILED, TIMEOUT
+	Attempts   int
+	NextRunAt  string
+	DeadlineAt string
+	LastError  string
+	CreatedAt  string
+	UpdatedAt  string
+}
+
+// DelistedListing: Senkronizasyonlarda art arda bulunamayıp DELISTED işaretlenen ilan
+type DelistedListing struct {
+	Platform      string
+	Barcode       string
+	ProductName   string
+	PlatformID    string
+	MissCount     int
+	FirstMissedAt string
+	DelistedAt    string
+}
+
+// --- VERİ SAHİPLİĞİ VE ÇAKIŞMALAR ---
+
+// Master veriye yazan kaynaklar (Pazaryerleri kendi anahtarlarıyla yazar: "hb", "pazarama", "ptt")
+const (
+	SourceMaster   = "master"   // Master ürün Excel'i
+	SourceSupplier = "supplier" // Tedarikçi listesi
+)
+
+// FieldConflict: Alanın sahibi olmayan bir kaynaktan gelip uygulanmayan farklı değer
+type FieldConflict struct {
+	Barcode       string
+	Field         string
+	Source        string
+	CurrentValue  string
+	IncomingValue string
+	SeenCount     int
+	LastSeenAt    string
+}
+
+// DuplicateListing: Aynı barkod için pazaryerinde bulunan iki farklı ilan
+type DuplicateListing struct {
+	Platform     string
+	Barcode      string
+	ListingA     string
+	ListingB     string
+	PriceA       float64
+	PriceB       float64
+	StockA       int
+	StockB       int
+	SeenCount    int
+	FirstSeenAt  string
+	LastSeenAt   string
+	Status       string // OPEN, RESOLVED
+	CanonicalID  string
+	DeactivateID string
+}
+
+// Listing: Bir master ürüne bağlı pazaryeri ilanı (Bir ürünün aynı platformda birden fazla ilanı olabilir)
+type Listing struct {
+	Platform        string
+	ExternalID      string // hb_sku, pazarama kodu, ptt UrunId
+	PlatformBarcode string // Platformdaki ham barkod (Örn: "-PZR" ekli kod, PTT'nin ekli barkodu)
+	MasterBarcode   string
+	Status          string // ACTIVE, DELISTED, DEACTIVATE
+	SyncStatus      string // Son gönderim sonucu (SYNCED, ERROR...)
+	SyncMessage     string
+	LastPrice       float64
+	LastStock       int
+	UpdatedAt       string
+}
+
+// DryRunRequest: Dry-run modunda gönderilmeyip kaydedilen yazma isteği
+type DryRunRequest struct {
+	ID         int
+	Platform   string
+	Operation  string
+	Method     string
+	URL        string
+	Payload    string
+	SeenCount  int
+	LastSeenAt string
+}
+
+// BlockedWrite: Acil durdurma açıkken gönderilmeyip incelemeye alınan yazma isteği
+type BlockedWrite struct {
+	ID        int
+	Platform  string
+	Operation string
+	Method    string
+	URL       string
+	Payload   string
+	CreatedAt string
+}
+
+// PushVerification: Gönderilen fiyat/stoğun pazaryerinde gerçekten uygulandığının geri okuma kontrolü
+type PushVerification struct {
+	Platform      string
+	ExternalID    string
+	Barcode       string
+	ExpectedPrice float64
+	ExpectedStock int
+	Status        string // PENDING, VERIFIED, MISMATCH, FAILED
+	RepushCount   int
+	SeenPrice     float64
+	SeenStock     int
+	Message       string
+	PushedAt      string
+}
+
+// AuthToken: Pazaryeri panel oturumu; program yeniden başladığında tekrar giriş gerekmez
+type AuthToken struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
 }
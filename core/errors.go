@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- HATA TİPLERİ ---
+// Servisler pazaryeri hatalarını bu tiplerle döndürür; CLI errors.As ile türüne bakıp
+// hatayı tek biçimde gösterir ve çıkış kodunu buna göre seçer.
+
+// AuthError kimlik bilgisi ya da token pazaryeri tarafından reddedildi (401/403, token alınamadı)
+type AuthError struct {
+	Platform string
+	Message  string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s kimlik doğrulama hatası: %s", e.Platform, e.Message)
+}
+
+// RateLimited pazaryeri istek sınırına takıldı (429); RetryAfter biliniyorsa dolu gelir
+type RateLimited struct {
+	Platform   string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimited) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s istek sınırına takıldı, %s sonra tekrar deneyin", e.Platform, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s istek sınırına takıldı", e.Platform)
+}
+
+// FieldError tek bir alanın doğrulama hatası
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError ürün verisi gönderilmeden önce eksik/hatalı bulundu
+type ValidationError struct {
+	Platform string
+	Subject  string // Örn: barkod
+	Fields   []FieldError
+}
+
+// NewValidationError tek alanlı doğrulama hatası üretir
+func NewValidationError(platform, field, message string) *ValidationError {
+	return &ValidationError{Platform: platform, Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// RemoteRejected pazaryeri isteği aldı ama reddetti; Message platformun döndürdüğü metindir
+type RemoteRejected struct {
+	Platform   string
+	Operation  string
+	StatusCode int
+	Message    string
+}
+
+func (e *RemoteRejected) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s %s reddedildi (HTTP %d): %s", e.Platform, e.Operation, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s %s reddedildi: %s", e.Platform, e.Operation, e.Message)
+}
+
+// PartialResult işlemin bir kısmı tamamlandı; Err kalan kısmın neden yapılamadığını taşır
+type PartialResult struct {
+	Platform  string
+	Operation string
+	Done      int
+	Failed    int
+	Err       error
+}
+
+func (e *PartialResult) Error() string {
+	msg := fmt.Sprintf("%s %s yarım kaldı (%d tamam, %d başarısız)", e.Platform, e.Operation, e.Done, e.Failed)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *PartialResult) Unwrap() error { return e.Err }
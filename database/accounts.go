@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"log"
+)
+
+// products tablosundaki hb_/pazarama_/ptt_ sütunları yalnızca varsayılan hesaba aittir.
+// Ek mağazaların ilanları listings'te, ürün bazındaki durumları burada hesap anahtarıyla tutulur.
+func InitAccountSyncTable() {
+	sqlStatus := `
+	CREATE TABLE IF NOT EXISTS account_sync_status (
+		platform TEXT,                       -- Hesap anahtarı (Örn: 'pazarama:magaza2')
+		barcode TEXT,                        -- Master barkod
+		sync_status TEXT,
+		sync_message TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(platform, barcode)
+	);`
+
+	if _, err := DB.Exec(sqlStatus); err != nil {
+		log.Printf("Tablo oluşturma hatası (account_sync_status): %v", err)
+	}
+}
+
+func setAccountSyncStatus(ctx context.Context, barcode, key, status, message string) {
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO account_sync_status (platform, barcode, sync_status, sync_message) VALUES (?, ?, ?, ?)
+		ON CONFLICT(platform, barcode) DO UPDATE SET
+			sync_status = excluded.sync_status,
+			sync_message = excluded.sync_message,
+			updated_at = CURRENT_TIMESTAMP`, key, barcode, status, message)
+	if err != nil {
+		log.Printf("[DB HATA] Durum yazılamadı (%s/%s): %v", barcode, key, err)
+	}
+}
+
+// GetAccountSyncStatus ek mağazanın ürün için son yazdığı durum; kayıt yoksa boş döner
+func GetAccountSyncStatus(ctx context.Context, key, barcode string) string {
+	var status string
+	DB.QueryRowContext(ctx, "SELECT COALESCE(sync_status, '') FROM account_sync_status WHERE platform = ? AND barcode = ?", key, barcode).Scan(&status)
+	return status
+}
+
+// ProductExists master kayıtta barkod var mı
+func ProductExists(ctx context.Context, barcode string) bool {
+	var n int
+	DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE barcode = ?", barcode).Scan(&n)
+	return n > 0
+}
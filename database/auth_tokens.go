@@ -0,0 +1,62 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func InitAuthTokenTable() {
+	// Pazaryeri panel oturumları; program yeniden başladığında tekrar giriş (OTP) gerekmez
+	sqlTokens := `
+	CREATE TABLE IF NOT EXISTS auth_tokens (
+		platform TEXT PRIMARY KEY,
+		access_token TEXT,
+		refresh_token TEXT,
+		expires_at DATETIME,                 -- access token'ın bitiş zamanı
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(sqlTokens); err != nil {
+		log.Printf("Tablo oluşturma hatası (auth_tokens): %v", err)
+	}
+}
+
+// SaveAuthToken platformun güncel oturumunu yazar
+func SaveAuthToken(ctx context.Context, platform string, t core.AuthToken) {
+	ttl := fmt.Sprintf("+%d seconds", int(time.Until(t.ExpiresAt).Seconds()))
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO auth_tokens (platform, access_token, refresh_token, expires_at, updated_at)
+		VALUES (?, ?, ?, datetime('now', ?), CURRENT_TIMESTAMP)
+		ON CONFLICT(platform) DO UPDATE SET
+			access_token = excluded.access_token, refresh_token = excluded.refresh_token,
+			expires_at = excluded.expires_at, updated_at = CURRENT_TIMESTAMP`,
+		platform, t.AccessToken, t.RefreshToken, ttl)
+	if err != nil {
+		log.Printf("[DB-HATA] Oturum kaydedilemedi (%s): %v", platform, err)
+	}
+}
+
+// GetAuthToken kayıtlı oturumu döndürür; bitiş zamanı DB saatine göre kalan süreden hesaplanır
+func GetAuthToken(ctx context.Context, platform string) (core.AuthToken, bool) {
+	var t core.AuthToken
+	var remaining int64
+	err := DB.QueryRowContext(ctx, `
+		SELECT COALESCE(access_token, ''), COALESCE(refresh_token, ''),
+			COALESCE(CAST(strftime('%s', expires_at) AS INTEGER) - CAST(strftime('%s', 'now') AS INTEGER), 0)
+		FROM auth_tokens WHERE platform = ?`, platform).Scan(&t.AccessToken, &t.RefreshToken, &remaining)
+	if err != nil {
+		return t, false
+	}
+	t.ExpiresAt = time.Now().Add(time.Duration(remaining) * time.Second)
+	return t, true
+}
+
+// DeleteAuthToken oturumu siler; sonraki istek yeniden giriş yapar
+func DeleteAuthToken(ctx context.Context, platform string) {
+	if _, err := DB.ExecContext(ctx, "DELETE FROM auth_tokens WHERE platform = ?", platform); err != nil {
+		log.Printf("[DB-HATA] Oturum silinemedi (%s): %v", platform, err)
+	}
+}
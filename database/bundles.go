@@ -0,0 +1,177 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+	"math"
+)
+
+func InitBundleTables() {
+	sqlBundles := `
+	CREATE TABLE IF NOT EXISTS bundles (
+		bundle_barcode TEXT PRIMARY KEY,     -- Paketin kendi barkodu (products tablosunda da bulunur)
+		price_rule TEXT DEFAULT ''           -- Bileşen toplamına uygulanacak işlem (*, /, +, -)
+	);`
+
+	sqlComponents := `
+	CREATE TABLE IF NOT EXISTS bundle_components (
+		bundle_barcode TEXT,
+		component_barcode TEXT,
+		quantity INTEGER DEFAULT 1,          -- Pakette bu bileşenden kaç adet var
+		PRIMARY KEY(bundle_barcode, component_barcode)
+	);`
+
+	if _, err := DB.Exec(sqlBundles); err != nil {
+		log.Printf("Tablo oluşturma hatası (bundles): %v", err)
+	}
+	if _, err := DB.Exec(sqlComponents); err != nil {
+		log.Printf("Tablo oluşturma hatası (bundle_components): %v", err)
+	}
+}
+
+// SaveBundle paket tanımını kaydeder, eski bileşen listesini tamamen değiştirir
+func SaveBundle(ctx context.Context, b core.Bundle) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO bundles (bundle_barcode, price_rule) VALUES (?, ?)
+		ON CONFLICT(bundle_barcode) DO UPDATE SET price_rule = excluded.price_rule`,
+		b.BundleBarcode, b.PriceRule)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM bundle_components WHERE bundle_barcode = ?", b.BundleBarcode); err != nil {
+		return err
+	}
+
+	for _, c := range b.Components {
+		if c.Barcode == b.BundleBarcode {
+			return fmt.Errorf("paket kendisini bileşen olarak içeremez: %s", c.Barcode)
+		}
+		qty := c.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO bundle_components (bundle_barcode, component_barcode, quantity) VALUES (?, ?, ?)",
+			b.BundleBarcode, c.Barcode, qty)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBundle barkod bir paketse tanımını döndürür
+func GetBundle(ctx context.Context, barcode string) (core.Bundle, bool) {
+	b := core.Bundle{BundleBarcode: barcode}
+	err := DB.QueryRowContext(ctx, "SELECT COALESCE(price_rule, '') FROM bundles WHERE bundle_barcode = ?", barcode).Scan(&b.PriceRule)
+	if err != nil {
+		return b, false
+	}
+
+	rows, err := DB.QueryContext(ctx, "SELECT component_barcode, quantity FROM bundle_components WHERE bundle_barcode = ?", barcode)
+	if err != nil {
+		return b, false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c core.BundleComponent
+		if err := rows.Scan(&c.Barcode, &c.Quantity); err == nil {
+			b.Components = append(b.Components, c)
+		}
+	}
+	return b, len(b.Components) > 0
+}
+
+func GetAllBundleBarcodes(ctx context.Context) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT bundle_barcode FROM bundles")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var barcodes []string
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err == nil {
+			barcodes = append(barcodes, b)
+		}
+	}
+	return barcodes, nil
+}
+
+// GetBundlesContaining bir bileşeni içeren paketlerin barkodlarını döndürür
+func GetBundlesContaining(ctx context.Context, componentBarcode string) []string {
+	rows, err := DB.QueryContext(ctx, "SELECT bundle_barcode FROM bundle_components WHERE component_barcode = ?", componentBarcode)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var barcodes []string
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err == nil {
+			barcodes = append(barcodes, b)
+		}
+	}
+	return barcodes
+}
+
+// RecalculateBundle paket stoğunu bileşenlerin yetebildiği en az paket sayısı,
+// fiyatını da bileşen toplamı + fiyat kuralı olarak products tablosuna yazar
+func RecalculateBundle(ctx context.Context, bundleBarcode string) (int, float64, error) {
+	b, ok := GetBundle(ctx, bundleBarcode)
+	if !ok {
+		return 0, 0, fmt.Errorf("paket tanımı bulunamadı: %s", bundleBarcode)
+	}
+
+	stock := math.MaxInt32
+	total := 0.0
+	for _, c := range b.Components {
+		var compStock int
+		var compPrice float64
+		err := DB.QueryRowContext(ctx, "SELECT stock, price FROM products WHERE barcode = ?", c.Barcode).Scan(&compStock, &compPrice)
+		if err != nil {
+			// Bileşen master'da yoksa paket satılamaz
+			stock = 0
+			continue
+		}
+		if compStock < 0 {
+			compStock = 0
+		}
+		if avail := compStock / c.Quantity; avail < stock {
+			stock = avail
+		}
+		total += compPrice * float64(c.Quantity)
+	}
+
+	price := math.Round(core.ApplyPriceOperation(total, b.PriceRule)*100) / 100
+
+	// Değer değişmediyse UPDATE atmıyoruz; trigger boşuna is_dirty=1 yapmasın
+	_, err := DB.ExecContext(ctx, `
+		UPDATE products SET stock = ?, price = ?
+		WHERE barcode = ? AND (stock != ? OR price != ?)`,
+		stock, price, bundleBarcode, stock, price)
+	if err != nil {
+		return stock, price, err
+	}
+	return stock, price, nil
+}
+
+// DecrementStock master stoktan adet düşer (satış kaydı için)
+func DecrementStock(ctx context.Context, barcode string, qty int) {
+	_, err := DB.ExecContext(ctx, "UPDATE products SET stock = MAX(stock - ?, 0) WHERE barcode = ?", qty, barcode)
+	if err != nil {
+		log.Printf("[DB-HATA] Stok düşülemedi (%s): %v", barcode, err)
+	}
+}
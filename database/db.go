@@ -2,13 +2,13 @@ package database
 
 import (
 	"arbitraj-bot/core"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -95,19 +95,48 @@ func InitDB() {
 	InitGlobalCategoryTables()
 	InitBrandTable()
 	InitPazaramaAttributeTable()
+	InitOrderTables()
+	InitStockHistoryTables()
+	InitBundleTables()
+	InitVariantTables()
+	InitPublishTables()
+	InitRejectionTable()
+	InitJobTable()
+	InitDelistingTable()
+	InitSyncStateTables()
+	InitMergeTables()
+	InitDuplicateTable()
+	InitListingTable()
+	InitDryRunTable()
+	InitKillSwitchTables()
+	InitVerificationTable()
+	InitPushFingerprintTable()
+	InitAuthTokenTable()
+	InitAccountSyncTable()
 
 	log.Println("[LOG] Master Veritabanı ve Otomatik Tetikleyiciler hazır.")
 }
 
-func UpdateProductImage(barcode string, imagePath string) {
+// Close kapanışta WAL içeriğini ana dosyaya yazıp bağlantıyı kapatır
+func Close() {
+	if DB == nil {
+		return
+	}
+	DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	if err := DB.Close(); err != nil {
+		log.Printf("[DB-HATA] Veritabanı kapatılamadı: %v", err)
+	}
+}
+
+func UpdateProductImage(ctx context.Context, barcode string, imagePath string) {
 	query := `UPDATE products SET image_path = ? WHERE barcode = ?`
-	_, err := DB.Exec(query, imagePath, barcode)
+	_, err := DB.ExecContext(ctx, query, imagePath, barcode)
 	if err != nil {
 		log.Printf("DB Resim Güncelleme Hatası: %v", err)
 	}
 }
 
-func SavePlatformCategories(platform, parentID, parentName, catID, catName string, isLeaf bool) {
+func SavePlatformCategories(ctx context.Context, platform, parentID, parentName, catID, catName string, isLeaf bool) {
 	query := `
 		INSERT INTO platform_categories (platform, parent_id, parent_name, category_id, category_name, is_leaf)
 		VALUES (?, ?, ?, ?, ?, ?)
@@ -117,17 +146,17 @@ func SavePlatformCategories(platform, parentID, parentName, catID, catName strin
 			category_name = excluded.category_name,
 			is_leaf = excluded.is_leaf
 	`
-	_, err := DB.Exec(query, platform, parentID, parentName, catID, catName, isLeaf)
+	_, err := DB.ExecContext(ctx, query, platform, parentID, parentName, catID, catName, isLeaf)
 	if err != nil {
 		log.Printf("[DB-HATA] Kategori kaydedilemedi: %v", err)
 	}
 }
 
-func SearchPlatformCategory(platform, keyword string) ([]core.HBCategory, error) {
+func SearchPlatformCategory(ctx context.Context, platform, keyword string) ([]core.HBCategory, error) {
 	query := `SELECT category_id, category_name FROM platform_categories 
 	          WHERE platform = ? AND category_name LIKE ? AND is_leaf = 1`
 
-	rows, err := DB.Query(query, platform, "%"+keyword+"%")
+	rows, err := DB.QueryContext(ctx, query, platform, "%"+keyword+"%")
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +185,7 @@ func InitPttCategoryTable() {
 	}
 }
 
-func SavePlatformCategory(platform, parentId, parentName, catId, catName string, isLeaf bool) {
+func SavePlatformCategory(ctx context.Context, platform, parentId, parentName, catId, catName string, isLeaf bool) {
 	query := `
     INSERT INTO platform_categories (platform, parent_id, parent_name, category_id, category_name, is_leaf)
     VALUES (?, ?, ?, ?, ?, ?)
@@ -166,7 +195,7 @@ func SavePlatformCategory(platform, parentId, parentName, catId, catName string,
         category_name = excluded.category_name,
         is_leaf = excluded.is_leaf;`
 
-	_, err := DB.Exec(query, platform, parentId, parentName, catId, catName, isLeaf)
+	_, err := DB.ExecContext(ctx, query, platform, parentId, parentName, catId, catName, isLeaf)
 	if err != nil {
 		log.Printf("[HATA] Kategori mühürlenemedi (%s): %v", catName, err)
 	}
@@ -207,8 +236,8 @@ func InitGlobalCategoryTables() {
 	log.Println("[LOG] Global kategori tabloları hazır.")
 }
 
-func ClearCategoryMappings() {
-	_, err := DB.Exec("DELETE FROM category_mappings")
+func ClearCategoryMappings(ctx context.Context) {
+	_, err := DB.ExecContext(ctx, "DELETE FROM category_mappings")
 	if err != nil {
 		fmt.Printf("[HATA] Mapping tablosu temizlenemedi: %v\n", err)
 	} else {
@@ -244,9 +273,8 @@ func InitPazaramaAttributeTable() {
 	}
 }
 
-func GetDirtyProducts() ([]core.Product, error) {
-	query := `
-		SELECT 
+// productColumns tüm ürün sorgularında ortak SELECT listesi (scanProducts ile aynı sırada olmalı)
+const productColumns = `
 			barcode, 
 			COALESCE(product_name, ''), 
 			COALESCE(brand, ''), 
@@ -269,11 +297,66 @@ func GetDirtyProducts() ([]core.Product, error) {
 			COALESCE(ptt_sync_message, ''),
 			hb_markup,
 			pazarama_markup,
-			ptt_markup
+			ptt_markup`
+
+func GetDirtyProducts(ctx context.Context) ([]core.Product, error) {
+	query := `SELECT ` + productColumns + `
 		FROM products 
 		WHERE is_dirty = 1 LIMIT 50`
 
-	rows, err := DB.Query(query)
+	products, err := queryProducts(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	// Watcher her ilana ayrı gönderim yapar
+	return attachListings(ctx, products)
+}
+
+// GetProductsByBarcodes verilen barkodlara ait master ürünleri döndürür
+func GetProductsByBarcodes(ctx context.Context, barcodes []string) ([]core.Product, error) {
+	if len(barcodes) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(barcodes)), ",")
+	args := make([]interface{}, len(barcodes))
+	for i, b := range barcodes {
+		args[i] = b
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM products WHERE barcode IN (%s) ORDER BY barcode`, productColumns, placeholders)
+	return queryProducts(ctx, query, args...)
+}
+
+// platformIDColumn platformun products tablosundaki ilan ID sütunu (hb_sku, pazarama_id, ptt_id)
+func platformIDColumn(platform string) string {
+	if platform == "hb" {
+		return "hb_sku"
+	}
+	return fmt.Sprintf("%s_id", platform)
+}
+
+// GetProductsMissingPlatform platformda henüz ilanı olmayan (ID'si boş) ürünleri döndürür.
+// Ek mağazalarda (Örn: "pazarama:magaza2") yalnızca listings'e bakılır.
+func GetProductsMissingPlatform(ctx context.Context, platform string) ([]core.Product, error) {
+	if !core.IsDefaultAccount(platform) {
+		return queryProducts(ctx, `SELECT `+productColumns+`
+			FROM products
+			WHERE NOT EXISTS (SELECT 1 FROM listings l WHERE l.platform = ? AND l.master_barcode = products.barcode)
+			ORDER BY barcode`, platform)
+	}
+	column := platformIDColumn(platform)
+
+	query := fmt.Sprintf(`SELECT %s
+		FROM products 
+		WHERE COALESCE(%s, '') = ''
+		AND NOT EXISTS (SELECT 1 FROM listings l WHERE l.platform = ? AND l.master_barcode = products.barcode)
+		ORDER BY barcode`, productColumns, column)
+
+	return queryProducts(ctx, query, platform)
+}
+
+func queryProducts(ctx context.Context, query string, args ...interface{}) ([]core.Product, error) {
+	rows, err := DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -311,14 +394,19 @@ func GetDirtyProducts() ([]core.Product, error) {
 	return products, nil
 }
 
-func UpdateSyncResult(barcode string, platform string, status string, message string) {
+func UpdateSyncResult(ctx context.Context, barcode string, platform string, status string, message string) {
+	if !core.IsDefaultAccount(platform) {
+		setAccountSyncStatus(ctx, barcode, platform, status, message)
+		MarkClean(ctx, barcode)
+		return
+	}
 
 	columnStatus := fmt.Sprintf("%s_sync_status", platform)
 	columnMessage := fmt.Sprintf("%s_sync_message", platform)
 
 	query := fmt.Sprintf("UPDATE products SET %s = ?, %s = ?, is_dirty = 0 WHERE barcode = ?", columnStatus, columnMessage)
 
-	result, err := DB.Exec(query, status, message, barcode)
+	result, err := DB.ExecContext(ctx, query, status, message, barcode)
 	if err != nil {
 		log.Printf("[DB HATA] Güncelleme yapılamadı (%s): %v", barcode, err)
 		return
@@ -332,40 +420,155 @@ func UpdateSyncResult(barcode string, platform string, status string, message st
 	}
 }
 
-func SaveProduct(p core.Product) {
-	var exHB, exPZR, exPTT sql.NullString
-	var exPrice float64
-	var exStock int
-	err := DB.QueryRow("SELECT hb_sku, pazarama_id, ptt_id, price, stock FROM products WHERE barcode = ?", p.Barcode).
-		Scan(&exHB, &exPZR, &exPTT, &exPrice, &exStock)
+// MarkClean gönderilecek platformu olmayan ürünün is_dirty bayrağını indirir
+func MarkClean(ctx context.Context, barcode string) {
+	if _, err := DB.ExecContext(ctx, "UPDATE products SET is_dirty = 0 WHERE barcode = ?", barcode); err != nil {
+		log.Printf("[DB HATA] is_dirty sıfırlanamadı (%s): %v", barcode, err)
+	}
+}
 
-	if err == nil {
+// SetSyncStatus yalnızca platformun durum/mesaj alanlarını yazar; is_dirty'ye dokunmaz
+// (Yayınlama akışları diğer platformların bekleyen güncellemelerini silmemeli)
+func SetSyncStatus(ctx context.Context, barcode string, platform string, status string, message string) {
+	if !core.IsDefaultAccount(platform) {
+		setAccountSyncStatus(ctx, barcode, platform, status, message)
+		return
+	}
+	query := fmt.Sprintf("UPDATE products SET %s_sync_status = ?, %s_sync_message = ? WHERE barcode = ?", platform, platform)
+	if _, err := DB.ExecContext(ctx, query, status, message, barcode); err != nil {
+		log.Printf("[DB HATA] Durum yazılamadı (%s/%s): %v", barcode, platform, err)
+	}
+}
+
+// GetSyncStatus ürünün hesaptaki kayıtlı durumunu okur; kayıt yoksa boş döner
+func GetSyncStatus(ctx context.Context, barcode string, platform string) string {
+	if !core.IsDefaultAccount(platform) {
+		return GetAccountSyncStatus(ctx, platform, barcode)
+	}
+	var status string
+	query := fmt.Sprintf("SELECT COALESCE(%s_sync_status, '') FROM products WHERE barcode = ?", platform)
+	DB.QueryRowContext(ctx, query, barcode).Scan(&status)
+	return status
+}
+
+// SetPlatformID ürünün platformdaki ID'sini (hb_sku, pazarama_id, ptt_id) yazar; ek mağazalarda yalnızca ilan açılır
+func SetPlatformID(ctx context.Context, barcode string, platform string, platformID string) {
+	if core.IsDefaultAccount(platform) {
+		column := platformIDColumn(platform)
+		query := fmt.Sprintf("UPDATE products SET %s = ? WHERE barcode = ?", column)
+		if _, err := DB.ExecContext(ctx, query, platformID, barcode); err != nil {
+			log.Printf("[DB HATA] Platform ID yazılamadı (%s/%s): %v", barcode, platform, err)
+		}
+	}
+
+	_, err := DB.ExecContext(ctx, `INSERT OR IGNORE INTO listings (platform, external_id, platform_barcode, master_barcode)
+		VALUES (?, ?, ?, ?)`, platform, platformID, barcode, barcode)
+	if err != nil {
+		log.Printf("[DB HATA] İlan kaydı açılamadı (%s/%s): %v", barcode, platform, err)
+	}
+}
+
+// GetCategoryMapping master kategori adının platform ID'lerini döndürür
+func GetCategoryMapping(ctx context.Context, masterCategory string) (core.CategoryMapping, bool) {
+	m := core.CategoryMapping{MasterCategoryName: masterCategory}
+	var pttID sql.NullInt64
+	var pzrID, hbID sql.NullString
+	err := DB.QueryRowContext(ctx, "SELECT ptt_id, pazarama_id, hb_id FROM category_mappings WHERE master_category_name = ?", masterCategory).
+		Scan(&pttID, &pzrID, &hbID)
+	if err != nil {
+		return m, false
+	}
+	m.PttID = int(pttID.Int64)
+	m.PazaramaID = pzrID.String
+	m.HbID = hbID.String
+	return m, true
+}
+
+// SaveCategoryMappingID tek bir platformun kategori eşleşmesini yazar, diğer platformlara dokunmaz
+func SaveCategoryMappingID(ctx context.Context, masterCategory string, platform string, platformCategoryID string) {
+	query := fmt.Sprintf(`
+		INSERT INTO category_mappings (master_category_name, %s_id) VALUES (?, ?)
+		ON CONFLICT(master_category_name) DO UPDATE SET %s_id = excluded.%s_id`, platform, platform, platform)
+	if _, err := DB.ExecContext(ctx, query, masterCategory, platformCategoryID); err != nil {
+		log.Printf("[DB HATA] Kategori eşleşmesi yazılamadı (%s): %v", masterCategory, err)
+	}
+}
+
+// GetCategoryDefaults platform_category_defaults tablosundaki varsayılan özellik değerlerini döndürür (attribute_id -> value)
+func GetCategoryDefaults(ctx context.Context, platform string, categoryID string) map[string]core.CategoryDefault {
+	defaults := make(map[string]core.CategoryDefault)
+	rows, err := DB.QueryContext(ctx, `
+		SELECT attribute_id, COALESCE(attribute_name, ''), COALESCE(value_id, ''), COALESCE(value_name, '')
+		FROM platform_category_defaults WHERE platform = ? AND category_id = ?`, platform, categoryID)
+	if err != nil {
+		return defaults
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d core.CategoryDefault
+		if err := rows.Scan(&d.AttributeID, &d.AttributeName, &d.ValueID, &d.ValueName); err == nil {
+			defaults[d.AttributeID] = d
+		}
+	}
+	return defaults
+}
+
+func SaveCategoryDefault(ctx context.Context, platform string, categoryID string, d core.CategoryDefault) error {
+	_, err := DB.ExecContext(ctx, `
+		INSERT OR REPLACE INTO platform_category_defaults 
+		(platform, category_id, attribute_id, attribute_name, value_id, value_name) 
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		platform, categoryID, d.AttributeID, d.AttributeName, d.ValueID, d.ValueName)
+	return err
+}
+
+// SaveProduct ürünü master DB'ye yazar. Alanlar 'source' kaynağının o alandaki sahipliğine göre
+// birleştirilir (bkz. mergePolicy); sahibi olmayan kaynaktan gelen farklı değerler çakışma olarak kaydedilir.
+func SaveProduct(ctx context.Context, p core.Product, source string) {
+	var existing core.Product
+	found := false
+	if rows, err := queryProducts(ctx, "SELECT "+productColumns+" FROM products WHERE barcode = ?", p.Barcode); err == nil && len(rows) > 0 {
+		existing, found = rows[0], true
+	}
+	exHB, exPZR, exPTT := existing.HbSku, existing.PazaramaId, existing.PttId
+	exPrice, exStock := existing.Price, existing.Stock
+
+	// Mükerrer çözümünde kapatılmaya ayrılan ilanın verisi master kayda işlenmez
+	for _, l := range []struct{ platform, id string }{{"pazarama", p.PazaramaId}, {"ptt", p.PttId}, {"hb", p.HbSku}} {
+		if l.id != "" && IsDeactivatedListing(ctx, l.platform, l.id) {
+			fmt.Printf("[MÜKERRER] %s | %s ilanı kapatılacak olarak işaretli, atlandı.\n", p.Barcode, l.id)
+			return
+		}
+	}
+
+	if found {
 		// PAZARAMA KONTROLÜ
-		if p.PazaramaId != "" && exPZR.Valid && exPZR.String != "" && exPZR.String != p.PazaramaId {
-			LogDuplicate("Pazarama", p.Barcode, exPZR.String, p.PazaramaId, exPrice, p.Price, exStock, p.Stock)
+		if p.PazaramaId != "" && exPZR != "" && exPZR != p.PazaramaId && !IsDeactivatedListing(ctx, "pazarama", exPZR) {
+			RecordDuplicate(ctx, "pazarama", p.Barcode, exPZR, p.PazaramaId, exPrice, p.Price, exStock, p.Stock)
 		}
 
 		// PTT KONTROLÜ
-		if p.PttId != "" && exPTT.Valid && exPTT.String != "" && exPTT.String != p.PttId {
-			LogDuplicate("PTT", p.Barcode, exPTT.String, p.PttId, exPrice, p.Price, exStock, p.Stock)
+		if p.PttId != "" && exPTT != "" && exPTT != p.PttId && !IsDeactivatedListing(ctx, "ptt", exPTT) {
+			RecordDuplicate(ctx, "ptt", p.Barcode, exPTT, p.PttId, exPrice, p.Price, exStock, p.Stock)
 		}
 
 		// HEPSİBURADA KONTROLÜ
-		if p.HbSku != "" && exHB.Valid && exHB.String != "" && exHB.String != p.HbSku {
-			LogDuplicate("Hepsiburada", p.Barcode, exHB.String, p.HbSku, exPrice, p.Price, exStock, p.Stock)
+		if p.HbSku != "" && exHB != "" && exHB != p.HbSku && !IsDeactivatedListing(ctx, "hb", exHB) {
+			RecordDuplicate(ctx, "hb", p.Barcode, exHB, p.HbSku, exPrice, p.Price, exStock, p.Stock)
 		}
 	}
 
 	matchMessage := "YENİ KAYIT"
-	if err == nil {
+	if found {
 		var platforms []string
-		if exHB.Valid && exHB.String != "" {
+		if exHB != "" {
 			platforms = append(platforms, "HB")
 		}
-		if exPZR.Valid && exPZR.String != "" {
+		if exPZR != "" {
 			platforms = append(platforms, "Pazarama")
 		}
-		if exPTT.Valid && exPTT.String != "" {
+		if exPTT != "" {
 			platforms = append(platforms, "PTT")
 		}
 
@@ -384,6 +587,9 @@ func SaveProduct(p core.Product) {
 		p.PttSyncMessage = matchMessage
 	}
 
+	// Master alanlar politika ile birleştirilir; platform ID/durum alanları aşağıda COALESCE ile korunur
+	merged := mergeProduct(ctx, existing, p, source)
+
 	query := `
     INSERT INTO products (
         barcode, product_name, brand, category_name, description, 
@@ -394,11 +600,15 @@ func SaveProduct(p core.Product) {
         hb_markup, pazarama_markup, ptt_markup
     ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     ON CONFLICT(barcode) DO UPDATE SET
-        product_name = COALESCE(NULLIF(excluded.product_name, ''), products.product_name),
-        brand = COALESCE(NULLIF(excluded.brand, ''), products.brand),
-        price = CASE WHEN excluded.price > 0 THEN excluded.price ELSE products.price END,
+        product_name = excluded.product_name,
+        brand = excluded.brand,
+        category_name = excluded.category_name,
+        description = excluded.description,
+        images = excluded.images,
+        price = excluded.price,
         stock = excluded.stock,
         vat_rate = excluded.vat_rate,
+        delivery_time = excluded.delivery_time,
         hb_sku = COALESCE(NULLIF(excluded.hb_sku, ''), products.hb_sku),
         hb_sync_status = COALESCE(NULLIF(excluded.hb_sync_status, ''), products.hb_sync_status),
         hb_sync_message = COALESCE(NULLIF(excluded.hb_sync_message, ''), products.hb_sync_message),
@@ -411,9 +621,9 @@ func SaveProduct(p core.Product) {
         is_dirty = 1,
         updated_at = CURRENT_TIMESTAMP;`
 
-	_, err = DB.Exec(query,
-		p.Barcode, p.ProductName, p.Brand, p.CategoryName, p.Description,
-		p.Price, p.VatRate, p.Stock, p.DeliveryTime, p.Images,
+	_, err := DB.ExecContext(ctx, query,
+		merged.Barcode, merged.ProductName, merged.Brand, merged.CategoryName, merged.Description,
+		merged.Price, merged.VatRate, merged.Stock, merged.DeliveryTime, merged.Images,
 		p.HbSku, p.HbSyncStatus, p.HbSyncMessage,
 		p.PazaramaId, p.PazaramaSyncStatus, p.PazaramaSyncMessage,
 		p.PttId, p.PttSyncStatus, p.PttSyncMessage,
@@ -427,7 +637,7 @@ func SaveProduct(p core.Product) {
 	fmt.Printf("[DB] İşlem Tamamlandı: %s (%s)\n", p.Barcode, matchMessage)
 }
 
-func SyncExcelToDB(products []core.ExcelProduct) {
+func SyncExcelToDB(ctx context.Context, products []core.ExcelProduct) {
 	fmt.Printf("[EXCEL] %d ürün işleniyor...\n", len(products))
 
 	for _, ep := range products {
@@ -443,37 +653,7 @@ func SyncExcelToDB(products []core.ExcelProduct) {
 			DeliveryTime: ep.DeliveryTime,
 			Images:       ep.MainImage,
 		}
-		SaveProduct(p)
+		SaveProduct(ctx, p, core.SourceMaster)
 	}
 	fmt.Println("[OK] Excel verileri başarıyla sisteme işlendi.")
 }
-
-func LogDuplicate(platform, barcode, existingID, newID string, oldPrice, newPrice float64, oldStock, newStock int) {
-	dirPath := "./storage"
-	fileName := dirPath + "/duplicates.log"
-
-	_ = os.MkdirAll(dirPath, 0755)
-
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Printf("[HATA] Log dosyası açılamadı: %v", err)
-		return
-	}
-	defer f.Close()
-
-	timestamp := time.Now().Format("02-01-2006 15:04:05")
-
-	logEntry := fmt.Sprintf("[%s] [%s] Barkod: %s | Mevcut(ID: %s, Fiyat: %.2f, Stok: %d) | Gelen(ID: %s, Fiyat: %.2f, Stok: %d)\n",
-		timestamp, platform, barcode, existingID, oldPrice, oldStock, newID, newPrice, newStock)
-
-	fmt.Printf("\033[33m[UYARI] Mükerrer Ürün! Barkod: %s (%s) | Mevcut Fiyat: %.2f, Yeni: %.2f | Mevcut Stok: %d, Yeni: %d\033[0m\n",
-		barcode, platform, oldPrice, newPrice, oldStock, newStock)
-
-	fmt.Printf("\033[33m[UYARI] %s Dizinine Detaylar Kaydedildi.\033[0m\n", fileName)
-
-	if _, err := f.WriteString(logEntry); err != nil {
-		log.Printf("[HATA] Log yazılamadı: %v", err)
-	}
-
-	time.Sleep(5 * time.Second)
-}
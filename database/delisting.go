@@ -0,0 +1,134 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+)
+
+func InitDelistingTable() {
+	sqlAbsences := `
+	CREATE TABLE IF NOT EXISTS listing_absences (
+		platform TEXT,                       -- 'hb', 'pazarama', 'ptt'
+		barcode TEXT,                        -- Master barkod
+		miss_count INTEGER DEFAULT 0,        -- Art arda kaç tam sync'te bulunamadı
+		first_missed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_missed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delisted_at DATETIME,                -- Eşik aşılıp DELISTED işaretlendiği an
+		PRIMARY KEY(platform, barcode)
+	);`
+
+	if _, err := DB.Exec(sqlAbsences); err != nil {
+		log.Printf("Tablo oluşturma hatası (listing_absences): %v", err)
+	}
+}
+
+// ReconcileListings tam bir sync sonrasında platformda ID'si olup listede gelmeyen ürünleri sayar.
+// 'threshold' kez art arda bulunamayan ilan DELISTED yapılır; tekrar görünen ilanın kaydı silinir.
+// Yeni DELISTED olan barkodları döndürür.
+func ReconcileListings(ctx context.Context, platform string, seen map[string]bool, threshold int) ([]string, error) {
+	query := `
+		SELECT DISTINCT l.master_barcode, COALESCE(s.sync_status, '') FROM listings l
+		LEFT JOIN account_sync_status s ON s.platform = l.platform AND s.barcode = l.master_barcode
+		WHERE l.platform = ?`
+	args := []interface{}{platform}
+	if core.IsDefaultAccount(platform) {
+		idColumn := platformIDColumn(platform)
+		query = fmt.Sprintf(`
+		SELECT barcode, COALESCE(%s_sync_status, '') FROM products
+		WHERE %s IS NOT NULL AND %s != ''`, platform, idColumn, idColumn)
+		args = nil
+	}
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	type linked struct {
+		barcode string
+		status  string
+	}
+	var products []linked
+	for rows.Next() {
+		var l linked
+		if err := rows.Scan(&l.barcode, &l.status); err == nil {
+			products = append(products, l)
+		}
+	}
+	rows.Close()
+
+	var delisted []string
+	for _, p := range products {
+		if seen[p.barcode] {
+			DB.ExecContext(ctx, "DELETE FROM listing_absences WHERE platform = ? AND barcode = ?", platform, p.barcode)
+			if p.status == "DELISTED" {
+				SetSyncStatus(ctx, p.barcode, platform, "SYNCED", "İlan platformda tekrar bulundu")
+				SetListingsStatusForProduct(ctx, platform, p.barcode, "ACTIVE")
+			}
+			continue
+		}
+
+		_, err := DB.ExecContext(ctx, `
+			INSERT INTO listing_absences (platform, barcode, miss_count) VALUES (?, ?, 1)
+			ON CONFLICT(platform, barcode) DO UPDATE SET
+				miss_count = miss_count + 1,
+				last_missed_at = CURRENT_TIMESTAMP`, platform, p.barcode)
+		if err != nil {
+			log.Printf("[DB-HATA] Eksik ilan kaydedilemedi (%s/%s): %v", platform, p.barcode, err)
+			continue
+		}
+
+		if p.status == "DELISTED" {
+			continue
+		}
+
+		var missCount int
+		DB.QueryRowContext(ctx, "SELECT miss_count FROM listing_absences WHERE platform = ? AND barcode = ?", platform, p.barcode).Scan(&missCount)
+		if missCount < threshold {
+			continue
+		}
+
+		DB.ExecContext(ctx, "UPDATE listing_absences SET delisted_at = CURRENT_TIMESTAMP WHERE platform = ? AND barcode = ?", platform, p.barcode)
+		SetSyncStatus(ctx, p.barcode, platform, "DELISTED", fmt.Sprintf("İlan art arda %d senkronizasyonda bulunamadı", missCount))
+		SetListingsStatusForProduct(ctx, platform, p.barcode, "DELISTED")
+		delisted = append(delisted, p.barcode)
+	}
+	return delisted, nil
+}
+
+// GetDelistedListings tüm platformlarda DELISTED durumundaki ilanları döndürür
+func GetDelistedListings(ctx context.Context) ([]core.DelistedListing, error) {
+	query := `
+		SELECT a.platform, a.barcode, COALESCE(p.product_name, ''),
+			CASE a.platform
+				WHEN 'hb' THEN COALESCE(p.hb_sku, '')
+				WHEN 'pazarama' THEN COALESCE(p.pazarama_id, '')
+				WHEN 'ptt' THEN COALESCE(p.ptt_id, '')
+				ELSE COALESCE((SELECT MIN(l.external_id) FROM listings l WHERE l.platform = a.platform AND l.master_barcode = a.barcode), '')
+			END,
+			a.miss_count, datetime(a.first_missed_at), datetime(a.delisted_at)
+		FROM listing_absences a
+		JOIN products p ON p.barcode = a.barcode
+		WHERE a.delisted_at IS NOT NULL
+		ORDER BY a.platform, a.delisted_at DESC`
+
+	rows, err := DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []core.DelistedListing
+	for rows.Next() {
+		var l core.DelistedListing
+		err := rows.Scan(&l.Platform, &l.Barcode, &l.ProductName, &l.PlatformID, &l.MissCount, &l.FirstMissedAt, &l.DelistedAt)
+		if err != nil {
+			fmt.Printf("[HATA] Kaldırılan ilan satırı okunamadı: %v\n", err)
+			continue
+		}
+		listings = append(listings, l)
+	}
+	return listings, nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+)
+
+func InitDryRunTable() {
+	sqlDryRun := `
+	CREATE TABLE IF NOT EXISTS dry_run_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,                       -- 'hb', 'pazarama', 'ptt'
+		operation TEXT,                      -- Örn: UpdatePriceStock, UploadProductsBulk
+		method TEXT,
+		url TEXT,
+		payload TEXT,                        -- Gönderilecek ham gövde (JSON / SOAP)
+		payload_hash TEXT UNIQUE,            -- Aynı istek tekrar gelirse yeni satır açılmaz
+		seen_count INTEGER DEFAULT 1,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(sqlDryRun); err != nil {
+		log.Printf("Tablo oluşturma hatası (dry_run_requests): %v", err)
+	}
+}
+
+// SaveDryRunRequest gönderilmeyen yazma isteğini kaydeder; birebir aynı istek sadece sayacı artırır
+func SaveDryRunRequest(ctx context.Context, platform, operation, method, url, payload string) {
+	sum := sha256.Sum256([]byte(platform + "|" + method + "|" + url + "|" + payload))
+
+	query := `
+		INSERT INTO dry_run_requests (platform, operation, method, url, payload, payload_hash)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(payload_hash) DO UPDATE SET
+			seen_count = dry_run_requests.seen_count + 1,
+			last_seen_at = CURRENT_TIMESTAMP`
+
+	_, err := DB.ExecContext(ctx, query, platform, operation, method, url, payload, hex.EncodeToString(sum[:]))
+	if err != nil {
+		log.Printf("[DB-HATA] Dry-run isteği kaydedilemedi (%s/%s): %v", platform, operation, err)
+	}
+}
+
+// GetDryRunRequests son kaydedilen istekleri yeniden eskiye döndürür
+func GetDryRunRequests(ctx context.Context, limit int) ([]core.DryRunRequest, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, COALESCE(platform, ''), COALESCE(operation, ''), COALESCE(method, ''), COALESCE(url, ''),
+			COALESCE(payload, ''), seen_count, datetime(last_seen_at)
+		FROM dry_run_requests
+		ORDER BY last_seen_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []core.DryRunRequest
+	for rows.Next() {
+		var r core.DryRunRequest
+		if err := rows.Scan(&r.ID, &r.Platform, &r.Operation, &r.Method, &r.URL, &r.Payload, &r.SeenCount, &r.LastSeenAt); err == nil {
+			requests = append(requests, r)
+		}
+	}
+	return requests, nil
+}
+
+// ClearDryRunRequests incelenen kayıtları temizler
+func ClearDryRunRequests(ctx context.Context) {
+	if _, err := DB.ExecContext(ctx, "DELETE FROM dry_run_requests"); err != nil {
+		log.Printf("[DB-HATA] Dry-run kayıtları silinemedi: %v", err)
+	}
+}
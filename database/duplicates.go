@@ -0,0 +1,133 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+func InitDuplicateTable() {
+	sqlDuplicates := `
+	CREATE TABLE IF NOT EXISTS duplicate_listings (
+		platform TEXT,                       -- 'hb', 'pazarama', 'ptt'
+		barcode TEXT,
+		listing_a TEXT,                      -- İlan ID'leri sıralı tutulur (listing_a < listing_b)
+		listing_b TEXT,
+		price_a REAL DEFAULT 0.0,
+		price_b REAL DEFAULT 0.0,
+		stock_a INTEGER DEFAULT 0,
+		stock_b INTEGER DEFAULT 0,
+		seen_count INTEGER DEFAULT 1,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		status TEXT DEFAULT 'OPEN',          -- OPEN, RESOLVED
+		canonical_id TEXT,                   -- Kalacak ilan
+		deactivate_id TEXT,                  -- Pazaryerinde kapatılacak ilan
+		resolved_at DATETIME,
+		PRIMARY KEY(platform, barcode, listing_a, listing_b)
+	);`
+
+	if _, err := DB.Exec(sqlDuplicates); err != nil {
+		log.Printf("Tablo oluşturma hatası (duplicate_listings): %v", err)
+	}
+}
+
+// RecordDuplicate aynı barkoda bağlı ikinci ilanı kaydeder; çözülmüş çiftlere dokunmaz
+func RecordDuplicate(ctx context.Context, platform, barcode, existingID, newID string, oldPrice, newPrice float64, oldStock, newStock int) {
+	idA, idB := existingID, newID
+	priceA, priceB := oldPrice, newPrice
+	stockA, stockB := oldStock, newStock
+	if idB < idA {
+		idA, idB = idB, idA
+		priceA, priceB = priceB, priceA
+		stockA, stockB = stockB, stockA
+	}
+
+	query := `
+		INSERT INTO duplicate_listings (platform, barcode, listing_a, listing_b, price_a, price_b, stock_a, stock_b)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(platform, barcode, listing_a, listing_b) DO UPDATE SET
+			price_a = excluded.price_a,
+			price_b = excluded.price_b,
+			stock_a = excluded.stock_a,
+			stock_b = excluded.stock_b,
+			seen_count = duplicate_listings.seen_count + 1,
+			last_seen_at = CURRENT_TIMESTAMP
+		WHERE duplicate_listings.status = 'OPEN'
+		RETURNING seen_count`
+
+	var seenCount int
+	err := DB.QueryRowContext(ctx, query, platform, barcode, idA, idB, priceA, priceB, stockA, stockB).Scan(&seenCount)
+	if err != nil {
+		// Çözülmüş çiftte güncelleme yapılmaz, satır dönmez
+		if err != sql.ErrNoRows {
+			log.Printf("[DB-HATA] Mükerrer ilan kaydedilemedi (%s/%s): %v", platform, barcode, err)
+		}
+		return
+	}
+
+	// Sadece ilk tespitte uyarıyoruz; sonraki görülmeler tabloda sayılır
+	if seenCount == 1 {
+		fmt.Printf("\033[33m[UYARI] Mükerrer Ürün! Barkod: %s (%s) | %s (%.2f TL, %d adet) <> %s (%.2f TL, %d adet)\033[0m\n",
+			barcode, platform, existingID, oldPrice, oldStock, newID, newPrice, newStock)
+	}
+}
+
+// IsDeactivatedListing ilan daha önce mükerrer çözümünde kapatılmak üzere işaretlendiyse true döner
+func IsDeactivatedListing(ctx context.Context, platform, listingID string) bool {
+	var n int
+	DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM duplicate_listings
+		WHERE platform = ? AND deactivate_id = ? AND status = 'RESOLVED'`, platform, listingID).Scan(&n)
+	return n > 0
+}
+
+// ResolveDuplicate kalacak ilanı seçer, diğerini kapatılacak olarak işaretler ve ürünü kalan ilana bağlar
+func ResolveDuplicate(ctx context.Context, d core.DuplicateListing, canonicalID string) error {
+	deactivateID := d.ListingB
+	if canonicalID == d.ListingB {
+		deactivateID = d.ListingA
+	} else if canonicalID != d.ListingA {
+		return fmt.Errorf("%s bu çiftin ilanlarından biri değil", canonicalID)
+	}
+
+	_, err := DB.ExecContext(ctx, `
+		UPDATE duplicate_listings SET status = 'RESOLVED', canonical_id = ?, deactivate_id = ?, resolved_at = CURRENT_TIMESTAMP
+		WHERE platform = ? AND barcode = ? AND listing_a = ? AND listing_b = ?`,
+		canonicalID, deactivateID, d.Platform, d.Barcode, d.ListingA, d.ListingB)
+	if err != nil {
+		return err
+	}
+
+	SetPlatformID(ctx, d.Barcode, d.Platform, canonicalID)
+	SetListingStatus(ctx, d.Platform, deactivateID, "DEACTIVATE")
+	return nil
+}
+
+// GetDuplicateListings verilen durumdaki mükerrer ilan çiftlerini döndürür
+func GetDuplicateListings(ctx context.Context, status string) ([]core.DuplicateListing, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT platform, barcode, listing_a, listing_b, price_a, price_b, stock_a, stock_b, seen_count,
+			datetime(first_seen_at), datetime(last_seen_at), status,
+			COALESCE(canonical_id, ''), COALESCE(deactivate_id, '')
+		FROM duplicate_listings WHERE status = ?
+		ORDER BY platform, barcode`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []core.DuplicateListing
+	for rows.Next() {
+		var d core.DuplicateListing
+		err := rows.Scan(&d.Platform, &d.Barcode, &d.ListingA, &d.ListingB, &d.PriceA, &d.PriceB, &d.StockA, &d.StockB,
+			&d.SeenCount, &d.FirstSeenAt, &d.LastSeenAt, &d.Status, &d.CanonicalID, &d.DeactivateID)
+		if err != nil {
+			fmt.Printf("[HATA] Mükerrer ilan satırı okunamadı: %v\n", err)
+			continue
+		}
+		list = append(list, d)
+	}
+	return list, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func InitJobTable() {
+	sqlJobs := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,                  -- 'pazarama_batch', 'hb_import', 'verify_push'
+		platform TEXT,
+		external_id TEXT,                    -- batchRequestId / trackingId
+		status TEXT DEFAULT 'QUEUED',        -- QUEUED, RUNNING, DONE, FAILED, TIMEOUT
+		attempts INTEGER DEFAULT 0,
+		next_run_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deadline_at DATETIME,
+		last_error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(kind, external_id)
+	);`
+
+	if _, err := DB.Exec(sqlJobs); err != nil {
+		log.Printf("Tablo oluşturma hatası (jobs): %v", err)
+	}
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(status, next_run_at)")
+}
+
+// EnqueueJob yeni bir takip işi ekler; aynı paket zaten kuyruktaysa dokunmaz
+func EnqueueJob(ctx context.Context, kind, platform, externalID string, delay, timeout time.Duration) {
+	_, err := DB.ExecContext(ctx, `
+		INSERT OR IGNORE INTO jobs (kind, platform, external_id, next_run_at, deadline_at)
+		VALUES (?, ?, ?, datetime('now', ?), datetime('now', ?))`,
+		kind, platform, externalID, sqliteOffset(delay), sqliteOffset(timeout))
+	if err != nil {
+		log.Printf("[DB-HATA] İş kuyruğa eklenemedi (%s/%s): %v", kind, externalID, err)
+	}
+}
+
+// ClaimDueJob zamanı gelmiş ilk işi RUNNING yapıp döndürür
+func ClaimDueJob(ctx context.Context) (core.Job, bool) {
+	var j core.Job
+	err := DB.QueryRowContext(ctx, `
+		UPDATE jobs SET status = 'RUNNING', updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'QUEUED' AND next_run_at <= datetime('now')
+			ORDER BY next_run_at LIMIT 1
+		)
+		RETURNING id, kind, COALESCE(platform, ''), COALESCE(external_id, ''), attempts, COALESCE(datetime(deadline_at), '')`).
+		Scan(&j.ID, &j.Kind, &j.Platform, &j.ExternalID, &j.Attempts, &j.DeadlineAt)
+	if err != nil {
+		return j, false
+	}
+	j.Status = "RUNNING"
+	return j, true
+}
+
+// IsJobExpired işin zaman aşımı sınırı geçmiş mi
+func IsJobExpired(ctx context.Context, id int64) bool {
+	var expired bool
+	DB.QueryRowContext(ctx, "SELECT datetime('now') > datetime(deadline_at) FROM jobs WHERE id = ?", id).Scan(&expired)
+	return expired
+}
+
+// RescheduleJob işi bir sonraki deneme için kuyruğa geri koyar
+// (Çalışırken RequeueJob ile baştan kuyruğa alınan işe dokunulmaz; FinishJob için de aynısı geçerli)
+func RescheduleJob(ctx context.Context, id int64, delay time.Duration, lastError string) {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE jobs SET status = 'QUEUED', attempts = attempts + 1,
+			next_run_at = datetime('now', ?), last_error = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'RUNNING'`, sqliteOffset(delay), lastError, id)
+	if err != nil {
+		log.Printf("[DB-HATA] İş yeniden planlanamadı (%d): %v", id, err)
+	}
+}
+
+// FinishJob işi son durumuna (DONE, FAILED, TIMEOUT) çeker
+func FinishJob(ctx context.Context, id int64, status string, message string) {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, attempts = attempts + 1, last_error = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'RUNNING'`, status, message, id)
+	if err != nil {
+		log.Printf("[DB-HATA] İş kapatılamadı (%d): %v", id, err)
+	}
+}
+
+// ReleaseJob kapanışta yarıda kesilen işi deneme saymadan hemen çalıştırılmak üzere kuyruğa bırakır
+func ReleaseJob(ctx context.Context, id int64) {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE jobs SET status = 'QUEUED', next_run_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'RUNNING'`, id)
+	if err != nil {
+		log.Printf("[DB-HATA] İş kuyruğa bırakılamadı (%d): %v", id, err)
+	}
+}
+
+// ResetRunningJobs program kapanırken yarım kalan işleri tekrar kuyruğa alır (başlangıçta çağrılır)
+func ResetRunningJobs(ctx context.Context) int64 {
+	result, err := DB.ExecContext(ctx, "UPDATE jobs SET status = 'QUEUED', next_run_at = CURRENT_TIMESTAMP WHERE status = 'RUNNING'")
+	if err != nil {
+		log.Printf("[DB-HATA] Yarım kalan işler sıfırlanamadı: %v", err)
+		return 0
+	}
+	n, _ := result.RowsAffected()
+	return n
+}
+
+func ListJobs(ctx context.Context, limit int) ([]core.Job, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, kind, COALESCE(platform, ''), COALESCE(external_id, ''), status, attempts,
+			COALESCE(datetime(next_run_at), ''), COALESCE(datetime(deadline_at), ''), COALESCE(last_error, ''),
+			datetime(created_at), datetime(updated_at)
+		FROM jobs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []core.Job
+	for rows.Next() {
+		var j core.Job
+		err := rows.Scan(&j.ID, &j.Kind, &j.Platform, &j.ExternalID, &j.Status, &j.Attempts,
+			&j.NextRunAt, &j.DeadlineAt, &j.LastError, &j.CreatedAt, &j.UpdatedAt)
+		if err == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+// CountJobsByStatus durum bazında iş sayılarını döndürür
+func CountJobsByStatus(ctx context.Context) map[string]int {
+	counts := make(map[string]int)
+	rows, err := DB.QueryContext(ctx, "SELECT status, COUNT(*) FROM jobs GROUP BY status")
+	if err != nil {
+		return counts
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err == nil {
+			counts[status] = n
+		}
+	}
+	return counts
+}
+
+// IsPublishBatchOpen paketin sonucu henüz işlenmemiş mi
+func IsPublishBatchOpen(ctx context.Context, platform, batchID string) bool {
+	var n int
+	DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM publish_batches WHERE platform = ? AND batch_id = ? AND status = 'OPEN'", platform, batchID).Scan(&n)
+	return n > 0
+}
+
+// sqliteOffset süreyi SQLite datetime() değiştiricisine çevirir (Örn: "+90 seconds")
+func sqliteOffset(d time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int(d.Seconds()))
+}
+
+// RequeueJob aynı hedef için işi baştan kuyruğa alır (Örn: aynı ilana yeni gönderim yapıldığında doğrulama)
+func RequeueJob(ctx context.Context, kind, platform, externalID string, delay, timeout time.Duration) {
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO jobs (kind, platform, external_id, next_run_at, deadline_at)
+		VALUES (?, ?, ?, datetime('now', ?), datetime('now', ?))
+		ON CONFLICT(kind, external_id) DO UPDATE SET
+			status = 'QUEUED', attempts = 0, last_error = NULL,
+			next_run_at = excluded.next_run_at, deadline_at = excluded.deadline_at,
+			updated_at = CURRENT_TIMESTAMP`,
+		kind, platform, externalID, sqliteOffset(delay), sqliteOffset(timeout))
+	if err != nil {
+		log.Printf("[DB-HATA] İş yeniden kuyruğa alınamadı (%s/%s): %v", kind, externalID, err)
+	}
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"database/sql"
+	"log"
+)
+
+func InitKillSwitchTables() {
+	// 1. Acil durdurma anahtarı (tek satır); program yeniden başlasa da durum korunur
+	sqlSwitch := `
+	CREATE TABLE IF NOT EXISTS write_halt (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		halted INTEGER DEFAULT 0,
+		reason TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 2. Anahtar kapalıyken engellenen yazma istekleri
+	sqlBlocked := `
+	CREATE TABLE IF NOT EXISTS blocked_writes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,
+		operation TEXT,
+		method TEXT,
+		url TEXT,
+		payload TEXT,
+		status TEXT DEFAULT 'PENDING',       -- PENDING, REVIEWED
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(sqlSwitch); err != nil {
+		log.Printf("Tablo oluşturma hatası (write_halt): %v", err)
+	}
+	if _, err := DB.Exec(sqlBlocked); err != nil {
+		log.Printf("Tablo oluşturma hatası (blocked_writes): %v", err)
+	}
+	DB.Exec("INSERT OR IGNORE INTO write_halt (id, halted) VALUES (1, 0)")
+}
+
+// SetWritesHalted tüm pazaryerlerine yazmayı durdurur ya da yeniden açar
+func SetWritesHalted(ctx context.Context, halted bool, reason string) error {
+	value := 0
+	if halted {
+		value = 1
+	}
+	_, err := DB.ExecContext(ctx, "UPDATE write_halt SET halted = ?, reason = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1", value, reason)
+	return err
+}
+
+// IsWritesHalted her çağrıda DB'ye bakar; başka bir süreçten (CLI) verilen durdurma anında geçerli olur.
+// Anahtar okunamazsa güvenli tarafta kalıp yazmayı durdurulmuş sayarız.
+func IsWritesHalted(ctx context.Context) (bool, string) {
+	var halted int
+	var reason sql.NullString
+	err := DB.QueryRowContext(ctx, "SELECT halted, reason FROM write_halt WHERE id = 1").Scan(&halted, &reason)
+	if err != nil {
+		return true, "acil durdurma anahtarı okunamadı: " + err.Error()
+	}
+	return halted == 1, reason.String
+}
+
+// SaveBlockedWrite durdurma sırasında gönderilmeyen isteği incelenmek üzere kuyruğa alır
+func SaveBlockedWrite(ctx context.Context, platform, operation, method, url, payload string) {
+	_, err := DB.ExecContext(ctx, `INSERT INTO blocked_writes (platform, operation, method, url, payload) VALUES (?, ?, ?, ?, ?)`,
+		platform, operation, method, url, payload)
+	if err != nil {
+		log.Printf("[DB-HATA] Engellenen istek kaydedilemedi (%s/%s): %v", platform, operation, err)
+	}
+}
+
+// GetBlockedWrites incelenmeyi bekleyen engellenmiş istekleri döndürür
+func GetBlockedWrites(ctx context.Context, limit int) ([]core.BlockedWrite, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, COALESCE(platform, ''), COALESCE(operation, ''), COALESCE(method, ''), COALESCE(url, ''),
+			COALESCE(payload, ''), datetime(created_at)
+		FROM blocked_writes WHERE status = 'PENDING'
+		ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var writes []core.BlockedWrite
+	for rows.Next() {
+		var w core.BlockedWrite
+		if err := rows.Scan(&w.ID, &w.Platform, &w.Operation, &w.Method, &w.URL, &w.Payload, &w.CreatedAt); err == nil {
+			writes = append(writes, w)
+		}
+	}
+	return writes, nil
+}
+
+func CountBlockedWrites(ctx context.Context) int {
+	var count int
+	DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM blocked_writes WHERE status = 'PENDING'").Scan(&count)
+	return count
+}
+
+// MarkBlockedWritesReviewed bekleyen tüm engellenmiş istekleri incelendi olarak kapatır
+func MarkBlockedWritesReviewed(ctx context.Context) {
+	if _, err := DB.ExecContext(ctx, "UPDATE blocked_writes SET status = 'REVIEWED' WHERE status = 'PENDING'"); err != nil {
+		log.Printf("[DB-HATA] Engellenen istekler kapatılamadı: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func InitListingTable() {
+	sqlListings := `
+	CREATE TABLE IF NOT EXISTS listings (
+		platform TEXT,                       -- 'hb', 'pazarama', 'ptt'
+		external_id TEXT,                    -- hb_sku, pazarama kodu, ptt UrunId
+		platform_barcode TEXT,               -- Platformdaki ham barkod
+		master_barcode TEXT,                 -- products.barcode
+		status TEXT DEFAULT 'ACTIVE',        -- ACTIVE, DELISTED, DEACTIVATE
+		sync_status TEXT,                    -- Son gönderim sonucu
+		sync_message TEXT,
+		last_price REAL DEFAULT 0.0,
+		last_stock INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(platform, external_id)
+	);`
+
+	if _, err := DB.Exec(sqlListings); err != nil {
+		log.Printf("Tablo oluşturma hatası (listings): %v", err)
+		return
+	}
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_listings_master ON listings(master_barcode, platform)")
+
+	// products tablosundaki tekil ID sütunlarını ilk açılışta listings'e taşıyoruz
+	for _, platform := range []string{"hb", "pazarama", "ptt"} {
+		column := platformIDColumn(platform)
+		_, err := DB.Exec(fmt.Sprintf(`
+			INSERT OR IGNORE INTO listings (platform, external_id, platform_barcode, master_barcode, status, sync_status, sync_message, last_price, last_stock)
+			SELECT ?, %s, barcode, barcode,
+				CASE WHEN %s_sync_status = 'DELISTED' THEN 'DELISTED' ELSE 'ACTIVE' END,
+				%s_sync_status, %s_sync_message, price, stock
+			FROM products WHERE COALESCE(%s, '') != ''`, column, platform, platform, platform, column), platform)
+		if err != nil {
+			log.Printf("[DB-HATA] %s ilanları listings'e taşınamadı: %v", platform, err)
+		}
+	}
+}
+
+// UpsertListing senkronizasyonda görülen ilanı kaydeder; görülen ilan tekrar ACTIVE sayılır
+// (Mükerrer çözümünde kapatılmaya ayrılan ilan DEACTIVATE olarak kalır)
+func UpsertListing(ctx context.Context, l core.Listing) {
+	query := `
+		INSERT INTO listings (platform, external_id, platform_barcode, master_barcode, status, last_price, last_stock)
+		VALUES (?, ?, ?, ?, 'ACTIVE', ?, ?)
+		ON CONFLICT(platform, external_id) DO UPDATE SET
+			platform_barcode = excluded.platform_barcode,
+			master_barcode = excluded.master_barcode,
+			status = CASE WHEN listings.status = 'DEACTIVATE' THEN listings.status ELSE 'ACTIVE' END,
+			last_price = excluded.last_price,
+			last_stock = excluded.last_stock,
+			updated_at = CURRENT_TIMESTAMP`
+
+	_, err := DB.ExecContext(ctx, query, l.Platform, l.ExternalID, l.PlatformBarcode, l.MasterBarcode, l.LastPrice, l.LastStock)
+	if err != nil {
+		log.Printf("[DB-HATA] İlan kaydedilemedi (%s/%s): %v", l.Platform, l.ExternalID, err)
+	}
+	invalidateDriftedFingerprint(ctx, l.Platform, l.ExternalID, l.LastPrice, l.LastStock)
+}
+
+// SetListingStatus ilanın yaşam durumunu (ACTIVE, DELISTED, DEACTIVATE) değiştirir
+func SetListingStatus(ctx context.Context, platform, externalID, status string) {
+	_, err := DB.ExecContext(ctx, "UPDATE listings SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE platform = ? AND external_id = ?",
+		status, platform, externalID)
+	if err != nil {
+		log.Printf("[DB-HATA] İlan durumu yazılamadı (%s/%s): %v", platform, externalID, err)
+	}
+}
+
+// SetListingsStatusForProduct master ürünün platformdaki tüm ilanlarının durumunu değiştirir
+func SetListingsStatusForProduct(ctx context.Context, platform, masterBarcode, status string) {
+	_, err := DB.ExecContext(ctx, `UPDATE listings SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE platform = ? AND master_barcode = ? AND status != 'DEACTIVATE'`, status, platform, masterBarcode)
+	if err != nil {
+		log.Printf("[DB-HATA] İlan durumları yazılamadı (%s/%s): %v", platform, masterBarcode, err)
+	}
+}
+
+// SetListingSyncResult tek bir ilana yapılan gönderimin sonucunu yazar
+func SetListingSyncResult(ctx context.Context, platform, externalID, syncStatus, message string) {
+	_, err := DB.ExecContext(ctx, `UPDATE listings SET sync_status = ?, sync_message = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE platform = ? AND external_id = ?`, syncStatus, message, platform, externalID)
+	if err != nil {
+		log.Printf("[DB-HATA] İlan sonucu yazılamadı (%s/%s): %v", platform, externalID, err)
+	}
+}
+
+// GetListingsByBarcodes master barkodlara bağlı tüm ilanları barkod bazında gruplar
+func GetListingsByBarcodes(ctx context.Context, barcodes []string) (map[string][]core.Listing, error) {
+	listings := make(map[string][]core.Listing)
+	if len(barcodes) == 0 {
+		return listings, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(barcodes)), ",")
+	args := make([]interface{}, len(barcodes))
+	for i, b := range barcodes {
+		args[i] = b
+	}
+
+	rows, err := DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT platform, external_id, COALESCE(platform_barcode, ''), master_barcode, status,
+			COALESCE(sync_status, ''), COALESCE(sync_message, ''), last_price, last_stock, datetime(updated_at)
+		FROM listings WHERE master_barcode IN (%s)
+		ORDER BY master_barcode, platform, external_id`, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l core.Listing
+		err := rows.Scan(&l.Platform, &l.ExternalID, &l.PlatformBarcode, &l.MasterBarcode, &l.Status,
+			&l.SyncStatus, &l.SyncMessage, &l.LastPrice, &l.LastStock, &l.UpdatedAt)
+		if err != nil {
+			fmt.Printf("[HATA] İlan satırı okunamadı: %v\n", err)
+			continue
+		}
+		listings[l.MasterBarcode] = append(listings[l.MasterBarcode], l)
+	}
+	return listings, nil
+}
+
+// attachListings ürünlerin Listings alanını doldurur
+func attachListings(ctx context.Context, products []core.Product) ([]core.Product, error) {
+	barcodes := make([]string, len(products))
+	for i, p := range products {
+		barcodes[i] = p.Barcode
+	}
+
+	listings, err := GetListingsByBarcodes(ctx, barcodes)
+	if err != nil {
+		return products, err
+	}
+	for i := range products {
+		products[i].Listings = listings[products[i].Barcode]
+	}
+	return products, nil
+}
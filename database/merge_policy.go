@@ -0,0 +1,310 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Alan bazlı varsayılan sahiplik: kaynak adı ("master", "supplier", "hb"...), "richest" (en dolu değer kazanır)
+// ya da "any" (son yazan kazanır). config.json'daki merge_policy bu değerlerin üzerine yazar.
+var mergePolicy = map[string]string{
+	"product_name":  "any",
+	"brand":         "any",
+	"category_name": core.SourceMaster,
+	"description":   "richest",
+	"images":        "richest",
+	"price":         core.SourceMaster,
+	"stock":         core.SourceSupplier,
+	"vat_rate":      core.SourceMaster,
+	"delivery_time": core.SourceMaster,
+}
+
+// Çakışma kabul edildiğinde değerin yazılacağı sütunlar (Alan adları sütun adlarıyla aynı)
+var mergeColumns = map[string]bool{
+	"product_name": true, "brand": true, "category_name": true, "description": true, "images": true,
+	"price": true, "stock": true, "vat_rate": true, "delivery_time": true,
+}
+
+func InitMergeTables() {
+	// 1. Her alanın en son hangi kaynaktan yazıldığı
+	sqlSources := `
+	CREATE TABLE IF NOT EXISTS product_field_sources (
+		barcode TEXT,
+		field TEXT,
+		source TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(barcode, field)
+	);`
+
+	// 2. Sahibi olmayan kaynaktan gelip uygulanmayan değerler
+	sqlConflicts := `
+	CREATE TABLE IF NOT EXISTS field_conflicts (
+		barcode TEXT,
+		field TEXT,
+		source TEXT,
+		current_value TEXT,
+		incoming_value TEXT,
+		seen_count INTEGER DEFAULT 1,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		resolved INTEGER DEFAULT 0,
+		PRIMARY KEY(barcode, field, source)
+	);`
+
+	if _, err := DB.Exec(sqlSources); err != nil {
+		log.Printf("Tablo oluşturma hatası (product_field_sources): %v", err)
+	}
+	if _, err := DB.Exec(sqlConflicts); err != nil {
+		log.Printf("Tablo oluşturma hatası (field_conflicts): %v", err)
+	}
+}
+
+// SetMergePolicy config'den gelen alan sahipliklerini varsayılanların üzerine yazar
+func SetMergePolicy(overrides map[string]string) {
+	for field, owner := range overrides {
+		if !mergeColumns[field] {
+			log.Printf("[UYARI] merge_policy içinde bilinmeyen alan: %s", field)
+			continue
+		}
+		mergePolicy[field] = strings.TrimSpace(owner)
+	}
+}
+
+// fieldMerger tek bir ürün kaydı için alan sahipliği kararlarını verir
+type fieldMerger struct {
+	barcode string
+	source  string
+	sources map[string]string
+}
+
+func newFieldMerger(ctx context.Context, barcode, source string) *fieldMerger {
+	m := &fieldMerger{barcode: barcode, source: source, sources: make(map[string]string)}
+
+	rows, err := DB.QueryContext(ctx, "SELECT field, source FROM product_field_sources WHERE barcode = ?", barcode)
+	if err != nil {
+		return m
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var field, src string
+		if err := rows.Scan(&field, &src); err == nil {
+			m.sources[field] = src
+		}
+	}
+	return m
+}
+
+// accept gelen değerin uygulanıp uygulanmayacağına karar verir; uygulanmayan farklı değer çakışma olarak kaydedilir
+func (m *fieldMerger) accept(ctx context.Context, field, current, incoming string, provided bool) bool {
+	if !provided {
+		return false
+	}
+
+	owner := mergePolicy[field]
+	if current == incoming {
+		// Sahibi aynı değeri teyit ettiyse alanı sahiplenir
+		if owner == m.source && m.sources[field] != m.source {
+			m.claim(ctx, field)
+		}
+		return false
+	}
+
+	switch owner {
+	case "", "any":
+	case "richest":
+		if !isRicher(field, current, incoming) {
+			return false
+		}
+	default:
+		// Sahibi henüz bu alana yazmadıysa herkes doldurabilir
+		if m.source != owner && m.sources[field] == owner {
+			saveFieldConflict(ctx, m.barcode, field, m.source, current, incoming)
+			return false
+		}
+	}
+
+	m.claim(ctx, field)
+	if m.source == owner {
+		resolveFieldConflicts(ctx, m.barcode, field)
+	}
+	return true
+}
+
+func (m *fieldMerger) claim(ctx context.Context, field string) {
+	m.sources[field] = m.source
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO product_field_sources (barcode, field, source) VALUES (?, ?, ?)
+		ON CONFLICT(barcode, field) DO UPDATE SET source = excluded.source, updated_at = CURRENT_TIMESTAMP`,
+		m.barcode, field, m.source)
+	if err != nil {
+		log.Printf("[DB-HATA] Alan kaynağı kaydedilemedi (%s/%s): %v", m.barcode, field, err)
+	}
+}
+
+// isRicher görseller için daha fazla görseli, metinler için daha uzun içeriği tercih eder
+func isRicher(field, current, incoming string) bool {
+	if field == "images" {
+		cur, inc := len(strings.Split(current, "|")), len(strings.Split(incoming, "|"))
+		if current == "" {
+			cur = 0
+		}
+		if inc != cur {
+			return inc > cur
+		}
+	}
+	return len([]rune(strings.TrimSpace(incoming))) > len([]rune(strings.TrimSpace(current)))
+}
+
+// mergeProduct mevcut kayıt ile gelen veriyi alan bazlı politikaya göre birleştirir
+func mergeProduct(ctx context.Context, existing, incoming core.Product, source string) core.Product {
+	m := newFieldMerger(ctx, incoming.Barcode, source)
+	merged := existing
+	merged.Barcode = incoming.Barcode
+
+	if m.accept(ctx, "product_name", existing.ProductName, incoming.ProductName, incoming.ProductName != "") {
+		merged.ProductName = incoming.ProductName
+	}
+	if m.accept(ctx, "brand", existing.Brand, incoming.Brand, incoming.Brand != "") {
+		merged.Brand = incoming.Brand
+	}
+	if m.accept(ctx, "category_name", existing.CategoryName, incoming.CategoryName, incoming.CategoryName != "") {
+		merged.CategoryName = incoming.CategoryName
+	}
+	if m.accept(ctx, "description", existing.Description, incoming.Description, incoming.Description != "") {
+		merged.Description = incoming.Description
+	}
+	if m.accept(ctx, "images", existing.Images, incoming.Images, incoming.Images != "") {
+		merged.Images = incoming.Images
+	}
+	// Pazaryeri fiyatı marjlıdır; master fiyatın o platformdaki karşılığıyla karşılaştırılır,
+	// kabul edilirse marj düşülerek yazılır (yoksa watcher marjı ikinci kez uygular)
+	markup := platformMarkup(existing, source)
+	if m.accept(ctx, "price", formatPrice(existing.Price*markup), formatPrice(incoming.Price), incoming.Price > 0) {
+		merged.Price = unmarkPrice(incoming.Price, markup)
+	}
+	// Stok her kaynakta dolu gelir; 0 da geçerli bir stoktur
+	if m.accept(ctx, "stock", strconv.Itoa(existing.Stock), strconv.Itoa(incoming.Stock), true) {
+		merged.Stock = incoming.Stock
+	}
+	if m.accept(ctx, "vat_rate", strconv.Itoa(existing.VatRate), strconv.Itoa(incoming.VatRate), incoming.VatRate > 0) {
+		merged.VatRate = incoming.VatRate
+	}
+	if m.accept(ctx, "delivery_time", strconv.Itoa(existing.DeliveryTime), strconv.Itoa(incoming.DeliveryTime), incoming.DeliveryTime > 0) {
+		merged.DeliveryTime = incoming.DeliveryTime
+	}
+	return merged
+}
+
+// platformMarkup kaynağın pazaryeri olması durumunda ürünün o platformdaki marj çarpanı
+func platformMarkup(p core.Product, source string) float64 {
+	markup := 0.0
+	switch source {
+	case "hb":
+		markup = p.HbMarkup
+	case "pazarama":
+		markup = p.PazaramaMarkup
+	case "ptt":
+		markup = p.PttMarkup
+	}
+	if markup <= 0 {
+		return 1.0
+	}
+	return markup
+}
+
+// unmarkPrice marjlı fiyattan master fiyatı kuruşa yuvarlayarak çıkarır (110 / 1.1 = 99.99999... olmasın)
+func unmarkPrice(price, markup float64) float64 {
+	return math.Round(price/markup*100) / 100
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+func saveFieldConflict(ctx context.Context, barcode, field, source, current, incoming string) {
+	query := `
+		INSERT INTO field_conflicts (barcode, field, source, current_value, incoming_value)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(barcode, field, source) DO UPDATE SET
+			current_value = excluded.current_value,
+			incoming_value = excluded.incoming_value,
+			seen_count = CASE WHEN field_conflicts.resolved = 1 THEN 1 ELSE field_conflicts.seen_count + 1 END,
+			last_seen_at = CURRENT_TIMESTAMP,
+			resolved = 0`
+
+	if _, err := DB.ExecContext(ctx, query, barcode, field, source, current, incoming); err != nil {
+		log.Printf("[DB-HATA] Çakışma kaydedilemedi (%s/%s): %v", barcode, field, err)
+		return
+	}
+	fmt.Printf("[ÇAKIŞMA] %s | %s: %s '%s' gönderdi, mevcut '%s' korundu.\n", barcode, field, source, incoming, current)
+}
+
+func resolveFieldConflicts(ctx context.Context, barcode, field string) {
+	DB.ExecContext(ctx, "UPDATE field_conflicts SET resolved = 1 WHERE barcode = ? AND field = ? AND resolved = 0", barcode, field)
+}
+
+// GetOpenFieldConflicts çözülmemiş çakışmaları döndürür
+func GetOpenFieldConflicts(ctx context.Context) ([]core.FieldConflict, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT barcode, field, source, COALESCE(current_value, ''), COALESCE(incoming_value, ''),
+			seen_count, datetime(last_seen_at)
+		FROM field_conflicts WHERE resolved = 0
+		ORDER BY last_seen_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []core.FieldConflict
+	for rows.Next() {
+		var c core.FieldConflict
+		if err := rows.Scan(&c.Barcode, &c.Field, &c.Source, &c.CurrentValue, &c.IncomingValue, &c.SeenCount, &c.LastSeenAt); err == nil {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts, nil
+}
+
+// AcceptFieldConflict çakışmadaki gelen değeri master veriye yazar; alan sahibi değişmez
+func AcceptFieldConflict(ctx context.Context, c core.FieldConflict) error {
+	if !mergeColumns[c.Field] {
+		return fmt.Errorf("bilinmeyen alan: %s", c.Field)
+	}
+
+	var value interface{} = c.IncomingValue
+	if c.Field == "price" {
+		// Çakışmadaki fiyat kaynağın marjlı fiyatıdır; master'a marj düşülerek yazılır
+		price, err := strconv.ParseFloat(c.IncomingValue, 64)
+		if err != nil {
+			return fmt.Errorf("geçersiz fiyat: %s", c.IncomingValue)
+		}
+		rows, err := queryProducts(ctx, "SELECT "+productColumns+" FROM products WHERE barcode = ?", c.Barcode)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			price = unmarkPrice(price, platformMarkup(rows[0], c.Source))
+		}
+		value = price
+	}
+
+	query := fmt.Sprintf("UPDATE products SET %s = ? WHERE barcode = ?", c.Field)
+	if _, err := DB.ExecContext(ctx, query, value, c.Barcode); err != nil {
+		return err
+	}
+
+	_, err := DB.ExecContext(ctx, "UPDATE field_conflicts SET resolved = 1 WHERE barcode = ? AND field = ? AND source = ?", c.Barcode, c.Field, c.Source)
+	return err
+}
+
+// DismissFieldConflicts çakışmaları değer uygulamadan kapatır
+func DismissFieldConflicts(ctx context.Context) {
+	if _, err := DB.ExecContext(ctx, "UPDATE field_conflicts SET resolved = 1 WHERE resolved = 0"); err != nil {
+		log.Printf("[DB-HATA] Çakışmalar kapatılamadı: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+)
+
+func InitOrderTables() {
+	// 1. Tüm kanallardan gelen sipariş satırları
+	sqlOrders := `
+	CREATE TABLE IF NOT EXISTS orders (
+		platform TEXT,                       -- 'hb', 'pazarama', 'ptt'
+		order_number TEXT,                   -- Platformun sipariş numarası
+		barcode TEXT,                        -- Master barkod
+		quantity INTEGER DEFAULT 0,
+		unit_price REAL DEFAULT 0.0,
+		order_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(platform, order_number, barcode)
+	);`
+
+	// 2. Ürün bazlı tedarikçi bilgileri (Satın alma planlaması için)
+	sqlSuppliers := `
+	CREATE TABLE IF NOT EXISTS product_suppliers (
+		barcode TEXT PRIMARY KEY,            -- Master barkod
+		supplier_name TEXT NOT NULL,
+		supplier_sku TEXT,
+		unit_cost REAL DEFAULT 0.0,
+		min_order_qty INTEGER DEFAULT 1,
+		lead_time_days INTEGER DEFAULT 0
+	);`
+
+	if _, err := DB.Exec(sqlOrders); err != nil {
+		log.Printf("Tablo oluşturma hatası (orders): %v", err)
+	}
+	if _, err := DB.Exec(sqlSuppliers); err != nil {
+		log.Printf("Tablo oluşturma hatası (product_suppliers): %v", err)
+	}
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_orders_barcode_date ON orders(barcode, order_date)")
+}
+
+// SaveOrderLine sipariş satırını kaydeder; satır daha önce yoksa true döner
+func SaveOrderLine(ctx context.Context, o core.OrderLine) bool {
+	query := `
+		INSERT INTO orders (platform, order_number, barcode, quantity, unit_price, order_date)
+		VALUES (?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), CURRENT_TIMESTAMP))
+		ON CONFLICT(platform, order_number, barcode) DO NOTHING`
+
+	result, err := DB.ExecContext(ctx, query, o.Platform, o.OrderNumber, o.Barcode, o.Quantity, o.UnitPrice, o.OrderDate)
+	if err != nil {
+		log.Printf("[DB-HATA] Sipariş kaydedilemedi (%s/%s): %v", o.Platform, o.OrderNumber, err)
+		return false
+	}
+
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return true
+	}
+
+	// Sipariş zaten kayıtlı: sadece adet/fiyat güncellenir, stok tekrar düşülmez
+	DB.ExecContext(ctx, "UPDATE orders SET quantity = ?, unit_price = ? WHERE platform = ? AND order_number = ? AND barcode = ?",
+		o.Quantity, o.UnitPrice, o.Platform, o.OrderNumber, o.Barcode)
+	return false
+}
+
+func SaveSupplierInfo(ctx context.Context, s core.SupplierInfo) {
+	if s.MinOrderQty <= 0 {
+		s.MinOrderQty = 1
+	}
+	query := `
+		INSERT INTO product_suppliers (barcode, supplier_name, supplier_sku, unit_cost, min_order_qty, lead_time_days)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(barcode) DO UPDATE SET
+			supplier_name = excluded.supplier_name,
+			supplier_sku = excluded.supplier_sku,
+			unit_cost = excluded.unit_cost,
+			min_order_qty = excluded.min_order_qty,
+			lead_time_days = excluded.lead_time_days`
+
+	_, err := DB.ExecContext(ctx, query, s.Barcode, s.SupplierName, s.SupplierSku, s.UnitCost, s.MinOrderQty, s.LeadTimeDays)
+	if err != nil {
+		log.Printf("[DB-HATA] Tedarikçi bilgisi kaydedilemedi (%s): %v", s.Barcode, err)
+	}
+}
+
+// GetSalesVelocity son 'days' gün içindeki tüm kanal satışlarından barkod başına günlük ortalama satışı döndürür
+func GetSalesVelocity(ctx context.Context, days int) (map[string]float64, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("geçersiz gün sayısı: %d", days)
+	}
+
+	// Sipariş akışı olmayan platformlar için stok düşüşünden tahmin edilen satışlar da dahil
+	query := `
+		SELECT barcode, SUM(quantity) FROM (
+			SELECT barcode, quantity FROM orders
+			WHERE order_date >= datetime('now', ?)
+			UNION ALL
+			SELECT barcode, quantity FROM estimated_sales
+			WHERE period_end >= datetime('now', ?)
+		)
+		GROUP BY barcode`
+
+	window := fmt.Sprintf("-%d days", days)
+	rows, err := DB.QueryContext(ctx, query, window, window)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	velocity := make(map[string]float64)
+	for rows.Next() {
+		var barcode string
+		var total int
+		if err := rows.Scan(&barcode, &total); err != nil {
+			continue
+		}
+		velocity[barcode] = float64(total) / float64(days)
+	}
+	return velocity, nil
+}
+
+// ReorderCandidate: Tedarikçisi tanımlı bir ürünün stok ve satın alma koşulları
+type ReorderCandidate struct {
+	core.SupplierInfo
+	ProductName string
+	Stock       int
+}
+
+// GetReorderCandidates tedarikçisi tanımlı tüm ürünleri güncel master stoklarıyla döndürür
+func GetReorderCandidates(ctx context.Context) ([]ReorderCandidate, error) {
+	query := `
+		SELECT
+			s.barcode,
+			s.supplier_name,
+			COALESCE(s.supplier_sku, ''),
+			s.unit_cost,
+			s.min_order_qty,
+			s.lead_time_days,
+			COALESCE(p.product_name, ''),
+			COALESCE(p.stock, 0)
+		FROM product_suppliers s
+		LEFT JOIN products p ON p.barcode = s.barcode
+		ORDER BY s.supplier_name, s.barcode`
+
+	rows, err := DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ReorderCandidate
+	for rows.Next() {
+		var c ReorderCandidate
+		err := rows.Scan(
+			&c.Barcode, &c.SupplierName, &c.SupplierSku,
+			&c.UnitCost, &c.MinOrderQty, &c.LeadTimeDays,
+			&c.ProductName, &c.Stock,
+		)
+		if err != nil {
+			fmt.Printf("[HATA] Tedarikçi satırı okunamadı: %v\n", err)
+			continue
+		}
+		results = append(results, c)
+	}
+	return results, nil
+}
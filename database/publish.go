@@ -0,0 +1,138 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"log"
+)
+
+func InitPublishTables() {
+	// Pazaryerine gönderilen yükleme paketleri ve içindeki ürünler (HB trackingId, Pazarama batchRequestId...)
+	sqlBatches := `
+	CREATE TABLE IF NOT EXISTS publish_batches (
+		platform TEXT,
+		batch_id TEXT,                       -- Platformun verdiği takip numarası
+		barcode TEXT,                        -- Paketteki master barkod
+		status TEXT DEFAULT 'OPEN',          -- OPEN: sonuç bekleniyor, CLOSED: sonuç işlendi
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(platform, batch_id, barcode)
+	);`
+
+	if _, err := DB.Exec(sqlBatches); err != nil {
+		log.Printf("Tablo oluşturma hatası (publish_batches): %v", err)
+	}
+}
+
+func SavePublishBatch(ctx context.Context, platform, batchID string, barcodes []string) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[DB-HATA] Paket kaydı başlatılamadı (%s): %v", batchID, err)
+		return
+	}
+	for _, b := range barcodes {
+		tx.ExecContext(ctx, "INSERT OR IGNORE INTO publish_batches (platform, batch_id, barcode) VALUES (?, ?, ?)", platform, batchID, b)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[DB-HATA] Paket kaydedilemedi (%s): %v", batchID, err)
+	}
+}
+
+// GetOpenPublishBatches sonucu henüz işlenmemiş paket numaralarını döndürür
+func GetOpenPublishBatches(ctx context.Context, platform string) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT DISTINCT batch_id FROM publish_batches WHERE platform = ? AND status = 'OPEN' ORDER BY created_at", platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func GetPublishBatchBarcodes(ctx context.Context, platform, batchID string) []string {
+	rows, err := DB.QueryContext(ctx, "SELECT barcode FROM publish_batches WHERE platform = ? AND batch_id = ?", platform, batchID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var barcodes []string
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err == nil {
+			barcodes = append(barcodes, b)
+		}
+	}
+	return barcodes
+}
+
+func ClosePublishBatch(ctx context.Context, platform, batchID string) {
+	_, err := DB.ExecContext(ctx, "UPDATE publish_batches SET status = 'CLOSED' WHERE platform = ? AND batch_id = ?", platform, batchID)
+	if err != nil {
+		log.Printf("[DB-HATA] Paket kapatılamadı (%s): %v", batchID, err)
+	}
+}
+
+func InitRejectionTable() {
+	sqlRejections := `
+	CREATE TABLE IF NOT EXISTS publish_rejections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,
+		batch_id TEXT,
+		barcode TEXT,                        -- Master barkod
+		platform_code TEXT,                  -- Platforma gönderilen ürün kodu
+		reason TEXT,
+		resolved INTEGER DEFAULT 0,          -- 1: Ürün sonradan başarıyla yüklendi
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(sqlRejections); err != nil {
+		log.Printf("Tablo oluşturma hatası (publish_rejections): %v", err)
+	}
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_rejections_lookup ON publish_rejections(platform, barcode, resolved)")
+}
+
+func SaveRejection(ctx context.Context, r core.PublishRejection) {
+	_, err := DB.ExecContext(ctx, `INSERT INTO publish_rejections (platform, batch_id, barcode, platform_code, reason) VALUES (?, ?, ?, ?, ?)`,
+		r.Platform, r.BatchID, r.Barcode, r.PlatformCode, r.Reason)
+	if err != nil {
+		log.Printf("[DB-HATA] Red kaydı yazılamadı (%s): %v", r.Barcode, err)
+	}
+}
+
+// ResolveRejections ürün başarıyla yüklendiğinde eski red kayıtlarını kapatır
+func ResolveRejections(ctx context.Context, platform, barcode string) {
+	DB.ExecContext(ctx, "UPDATE publish_rejections SET resolved = 1 WHERE platform = ? AND barcode = ? AND resolved = 0", platform, barcode)
+}
+
+// GetOpenRejections çözülmemiş redlerin her barkod için en güncelini döndürür
+func GetOpenRejections(ctx context.Context, platform string) ([]core.PublishRejection, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT platform, COALESCE(batch_id, ''), barcode, COALESCE(platform_code, ''), COALESCE(reason, ''), datetime(created_at)
+		FROM publish_rejections
+		WHERE id IN (
+			SELECT MAX(id) FROM publish_rejections
+			WHERE platform = ? AND resolved = 0
+			GROUP BY barcode
+		)
+		ORDER BY created_at DESC`, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []core.PublishRejection
+	for rows.Next() {
+		var r core.PublishRejection
+		if err := rows.Scan(&r.Platform, &r.BatchID, &r.Barcode, &r.PlatformCode, &r.Reason, &r.CreatedAt); err == nil {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"log"
+)
+
+func InitPushFingerprintTable() {
+	sqlFingerprints := `
+	CREATE TABLE IF NOT EXISTS push_fingerprints (
+		platform TEXT,
+		external_id TEXT,
+		barcode TEXT,
+		hash TEXT,                           -- Son başarılı gönderimin (fiyat, stok, içerik) özeti
+		price REAL,
+		stock INTEGER,
+		sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(platform, external_id)
+	);`
+
+	if _, err := DB.Exec(sqlFingerprints); err != nil {
+		log.Printf("Tablo oluşturma hatası (push_fingerprints): %v", err)
+	}
+}
+
+// IsPushUnchanged ilana son başarıyla gönderilen içerik aynıysa true döner
+func IsPushUnchanged(ctx context.Context, platform, externalID, hash string) bool {
+	var n int
+	DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM push_fingerprints WHERE platform = ? AND external_id = ? AND hash = ?",
+		platform, externalID, hash).Scan(&n)
+	return n > 0
+}
+
+// SavePushFingerprint başarılı gönderimin özetini saklar
+func SavePushFingerprint(ctx context.Context, platform, externalID, barcode, hash string, price float64, stock int) {
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO push_fingerprints (platform, external_id, barcode, hash, price, stock) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(platform, external_id) DO UPDATE SET
+			barcode = excluded.barcode, hash = excluded.hash, price = excluded.price, stock = excluded.stock,
+			sent_at = CURRENT_TIMESTAMP`,
+		platform, externalID, barcode, hash, price, stock)
+	if err != nil {
+		log.Printf("[DB-HATA] Gönderim özeti kaydedilemedi (%s/%s): %v", platform, externalID, err)
+	}
+}
+
+// ClearPushFingerprint ilanın özetini siler; bir sonraki gönderim atlanmaz
+func ClearPushFingerprint(ctx context.Context, platform, externalID string) {
+	DB.ExecContext(ctx, "DELETE FROM push_fingerprints WHERE platform = ? AND external_id = ?", platform, externalID)
+}
+
+// ClearPushFingerprints tüm özetleri siler (bir sonraki gönderimlerin hepsi zorla yapılır)
+func ClearPushFingerprints(ctx context.Context) int64 {
+	result, err := DB.ExecContext(ctx, "DELETE FROM push_fingerprints")
+	if err != nil {
+		log.Printf("[DB-HATA] Gönderim özetleri silinemedi: %v", err)
+		return 0
+	}
+	n, _ := result.RowsAffected()
+	return n
+}
+
+// invalidateDriftedFingerprint platformda görülen fiyat/stok son gönderimden farklıysa özeti siler
+// (Panelden elle yapılan değişiklik aynı değerin tekrar gönderilmesini engellememeli)
+func invalidateDriftedFingerprint(ctx context.Context, platform, externalID string, price float64, stock int) {
+	DB.ExecContext(ctx, `DELETE FROM push_fingerprints
+		WHERE platform = ? AND external_id = ? AND (ABS(price - ?) > 0.01 OR stock != ?)`,
+		platform, externalID, price, stock)
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"fmt"
+	"log"
+)
+
+func InitStockHistoryTables() {
+	// 1. Her SyncProducts çalışmasında platformdan okunan stok/fiyat fotoğrafı
+	sqlSnapshots := `
+	CREATE TABLE IF NOT EXISTS stock_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,                       -- 'hb', 'pazarama', 'ptt'
+		barcode TEXT,                        -- Master barkod
+		listing_id TEXT,                     -- Platformdaki ID (hb_sku, pazarama kodu, ptt UrunId)
+		stock INTEGER,
+		price REAL,
+		taken_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 2. Bizim platformlara gönderdiğimiz stok değişikliklerinin günlüğü
+	sqlJournal := `
+	CREATE TABLE IF NOT EXISTS stock_change_journal (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,
+		barcode TEXT,
+		new_stock INTEGER,
+		source TEXT,                         -- 'PUSH' (API), 'EXCEL' (toplu güncelleme) vb.
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 3. Sipariş verisi olmayan yerlerde stok düşüşünden tahmin edilen satışlar
+	sqlEstimated := `
+	CREATE TABLE IF NOT EXISTS estimated_sales (
+		platform TEXT,
+		barcode TEXT,
+		quantity INTEGER,
+		unit_price REAL,
+		period_start DATETIME,
+		period_end DATETIME,
+		PRIMARY KEY(platform, barcode, period_end)
+	);`
+
+	for name, stmt := range map[string]string{
+		"stock_snapshots":      sqlSnapshots,
+		"stock_change_journal": sqlJournal,
+		"estimated_sales":      sqlEstimated,
+	} {
+		if _, err := DB.Exec(stmt); err != nil {
+			log.Printf("Tablo oluşturma hatası (%s): %v", name, err)
+		}
+	}
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_snapshots_lookup ON stock_snapshots(platform, barcode, taken_at)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_journal_lookup ON stock_change_journal(platform, barcode, created_at)")
+}
+
+func SaveStockSnapshot(ctx context.Context, platform, barcode, listingID string, stock int, price float64) {
+	_, err := DB.ExecContext(ctx, `INSERT INTO stock_snapshots (platform, barcode, listing_id, stock, price) VALUES (?, ?, ?, ?, ?)`,
+		platform, barcode, listingID, stock, price)
+	if err != nil {
+		log.Printf("[DB-HATA] Stok fotoğrafı kaydedilemedi (%s/%s): %v", platform, barcode, err)
+	}
+}
+
+// RecordStockChange bizim tarafımızdan platforma gönderilen stok değerini günlüğe yazar.
+// Satış tahmini bu kayıtları "açıklanmış" değişiklik olarak kabul eder.
+func RecordStockChange(ctx context.Context, platform, barcode string, newStock int, source string) {
+	_, err := DB.ExecContext(ctx, `INSERT INTO stock_change_journal (platform, barcode, new_stock, source) VALUES (?, ?, ?, ?)`,
+		platform, barcode, newStock, source)
+	if err != nil {
+		log.Printf("[DB-HATA] Stok değişikliği günlüğe yazılamadı (%s/%s): %v", platform, barcode, err)
+	}
+}
+
+// GetBarcodeByPlatformID platform ID'sinden (hb_sku, pazarama_id, ptt_id) master barkodu bulur
+func GetBarcodeByPlatformID(ctx context.Context, platform, platformID string) string {
+	var barcode string
+	err := DB.QueryRowContext(ctx, "SELECT master_barcode FROM listings WHERE platform = ? AND external_id = ?", platform, platformID).Scan(&barcode)
+	if err == nil {
+		return barcode
+	}
+	if !core.IsDefaultAccount(platform) {
+		return ""
+	}
+
+	column := platformIDColumn(platform)
+	err = DB.QueryRowContext(ctx, fmt.Sprintf("SELECT barcode FROM products WHERE %s = ?", column), platformID).Scan(&barcode)
+	if err != nil {
+		return ""
+	}
+	return barcode
+}
+
+// StockMovement: Aynı ürünün ardışık iki fotoğrafı arasındaki değişim (barkodun ilanlarının toplamı)
+type StockMovement struct {
+	Barcode     string
+	PrevStock   int
+	PrevTakenAt string
+	CurStock    int
+	CurPrice    float64
+	CurTakenAt  string
+	Listings    int // Toplama giren ilan sayısı
+}
+
+// GetLatestStockMovements her ilanın son iki fotoğrafını karşılaştırıp barkod bazında toplar.
+// Aynı barkodun birden fazla ilanı olabilir (Örn: X ve X-PZR); fotoğraflar ilan bazında eşleşmezse
+// farklı ilanların stok farkı satış sanılır. Yalnızca barkodun son senkronizasyonunda fotoğrafı alınan
+// ilanlar sayılır; kaldırılan ya da değişmediği için atlanan ilanın eski farkı tekrar sayılmasın.
+func GetLatestStockMovements(ctx context.Context, platform string) ([]StockMovement, error) {
+	query := `
+		WITH ranked AS (
+			SELECT barcode, COALESCE(listing_id, '') AS listing_id, stock, price, taken_at,
+				ROW_NUMBER() OVER (PARTITION BY barcode, COALESCE(listing_id, '') ORDER BY taken_at DESC, id DESC) AS rn,
+				MAX(taken_at) OVER (PARTITION BY barcode) AS latest
+			FROM stock_snapshots
+			WHERE platform = ?
+		)
+		SELECT cur.barcode, SUM(prev.stock), datetime(MIN(prev.taken_at)), SUM(cur.stock), MAX(cur.price),
+			datetime(MAX(cur.taken_at)), COUNT(*)
+		FROM ranked cur
+		JOIN ranked prev ON prev.barcode = cur.barcode AND prev.listing_id = cur.listing_id AND prev.rn = 2
+		WHERE cur.rn = 1 AND cur.taken_at >= datetime(cur.latest, '-1 minute')
+		GROUP BY cur.barcode`
+
+	rows, err := DB.QueryContext(ctx, query, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []StockMovement
+	for rows.Next() {
+		var m StockMovement
+		if err := rows.Scan(&m.Barcode, &m.PrevStock, &m.PrevTakenAt, &m.CurStock, &m.CurPrice, &m.CurTakenAt, &m.Listings); err != nil {
+			continue
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
+
+// GetLastPushedStock iki zaman arasında bizim gönderdiğimiz son stok değerini döndürür
+func GetLastPushedStock(ctx context.Context, platform, barcode, from, to string) (int, bool) {
+	var stock int
+	err := DB.QueryRowContext(ctx, `
+		SELECT new_stock FROM stock_change_journal
+		WHERE platform = ? AND barcode = ? AND created_at > ? AND created_at <= ?
+		ORDER BY created_at DESC, id DESC LIMIT 1`, platform, barcode, from, to).Scan(&stock)
+	if err != nil {
+		return 0, false
+	}
+	return stock, true
+}
+
+// GetOrderedQuantity iki zaman arasında sipariş kaydı olan adedi döndürür
+func GetOrderedQuantity(ctx context.Context, platform, barcode, from, to string) int {
+	var qty int
+	DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM orders
+		WHERE platform = ? AND barcode = ? AND order_date > ? AND order_date <= ?`,
+		platform, barcode, from, to).Scan(&qty)
+	return qty
+}
+
+func HasEstimatedSale(ctx context.Context, platform, barcode, periodEnd string) bool {
+	var exists int
+	err := DB.QueryRowContext(ctx, "SELECT 1 FROM estimated_sales WHERE platform = ? AND barcode = ? AND period_end = ?",
+		platform, barcode, periodEnd).Scan(&exists)
+	return err == nil
+}
+
+func SaveEstimatedSale(ctx context.Context, platform, barcode string, qty int, unitPrice float64, from, to string) {
+	_, err := DB.ExecContext(ctx, `
+		INSERT OR REPLACE INTO estimated_sales (platform, barcode, quantity, unit_price, period_start, period_end)
+		VALUES (?, ?, ?, ?, ?, ?)`, platform, barcode, qty, unitPrice, from, to)
+	if err != nil {
+		log.Printf("[DB-HATA] Tahmini satış kaydedilemedi (%s/%s): %v", platform, barcode, err)
+	}
+}
+
+// GetSalesSummary son 'days' gündeki gerçek ve tahmini satışları barkod bazında toplar
+func GetSalesSummary(ctx context.Context, days int) ([]core.SalesSummary, error) {
+	query := `
+		WITH sales AS (
+			SELECT barcode, quantity, unit_price, 0 AS estimated FROM orders
+			WHERE order_date >= datetime('now', ?)
+			UNION ALL
+			SELECT barcode, quantity, unit_price, 1 AS estimated FROM estimated_sales
+			WHERE period_end >= datetime('now', ?)
+		)
+		SELECT
+			s.barcode,
+			COALESCE(p.product_name, ''),
+			SUM(s.quantity),
+			SUM(CASE WHEN s.estimated = 1 THEN s.quantity ELSE 0 END),
+			SUM(s.quantity * s.unit_price),
+			COALESCE(ps.unit_cost, 0)
+		FROM sales s
+		LEFT JOIN products p ON p.barcode = s.barcode
+		LEFT JOIN product_suppliers ps ON ps.barcode = s.barcode
+		GROUP BY s.barcode
+		ORDER BY SUM(s.quantity * s.unit_price) DESC`
+
+	window := fmt.Sprintf("-%d days", days)
+	rows, err := DB.QueryContext(ctx, query, window, window)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []core.SalesSummary
+	for rows.Next() {
+		var s core.SalesSummary
+		var unitCost float64
+		if err := rows.Scan(&s.Barcode, &s.ProductName, &s.Quantity, &s.EstimatedQty, &s.Revenue, &unitCost); err != nil {
+			continue
+		}
+		s.Cost = float64(s.Quantity) * unitCost
+		s.Profit = s.Revenue - s.Cost
+		results = append(results, s)
+	}
+	return results, nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func InitSyncStateTables() {
+	// 1. Platform bazlı son senkronizasyon noktaları
+	sqlCheckpoints := `
+	CREATE TABLE IF NOT EXISTS sync_checkpoints (
+		platform TEXT PRIMARY KEY,
+		last_sync_at DATETIME,
+		last_full_sync_at DATETIME
+	);`
+
+	// 2. İlan bazlı son görülen ham verinin özeti (Değişmeyen ilan tekrar işlenmez)
+	sqlHashes := `
+	CREATE TABLE IF NOT EXISTS listing_hashes (
+		platform TEXT,
+		listing_id TEXT,                     -- hb_sku, pazarama kodu, ptt UrunId
+		payload_hash TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(platform, listing_id)
+	);`
+
+	if _, err := DB.Exec(sqlCheckpoints); err != nil {
+		log.Printf("Tablo oluşturma hatası (sync_checkpoints): %v", err)
+	}
+	if _, err := DB.Exec(sqlHashes); err != nil {
+		log.Printf("Tablo oluşturma hatası (listing_hashes): %v", err)
+	}
+}
+
+// IsFullSyncDue platformda son tam senkronizasyonun üzerinden 'interval' geçtiyse (ya da hiç yapılmadıysa) true döner
+func IsFullSyncDue(ctx context.Context, platform string, interval time.Duration) bool {
+	var due bool
+	err := DB.QueryRowContext(ctx, `
+		SELECT last_full_sync_at IS NULL OR datetime(last_full_sync_at) <= datetime('now', ?)
+		FROM sync_checkpoints WHERE platform = ?`,
+		fmt.Sprintf("-%d seconds", int(interval.Seconds())), platform).Scan(&due)
+	if err != nil {
+		return true
+	}
+	return due
+}
+
+// SaveSyncCheckpoint başarıyla biten senkronizasyonu kaydeder
+func SaveSyncCheckpoint(ctx context.Context, platform string, full bool) {
+	query := `
+		INSERT INTO sync_checkpoints (platform, last_sync_at, last_full_sync_at)
+		VALUES (?, CURRENT_TIMESTAMP, CASE WHEN ? THEN CURRENT_TIMESTAMP END)
+		ON CONFLICT(platform) DO UPDATE SET
+			last_sync_at = CURRENT_TIMESTAMP,
+			last_full_sync_at = CASE WHEN ? THEN CURRENT_TIMESTAMP ELSE sync_checkpoints.last_full_sync_at END`
+
+	if _, err := DB.ExecContext(ctx, query, platform, full, full); err != nil {
+		log.Printf("[DB-HATA] Sync noktası kaydedilemedi (%s): %v", platform, err)
+	}
+}
+
+// RequestFullSync bir sonraki senkronizasyonun tüm platformlarda tam yapılmasını sağlar
+func RequestFullSync(ctx context.Context) {
+	if _, err := DB.ExecContext(ctx, "UPDATE sync_checkpoints SET last_full_sync_at = NULL"); err != nil {
+		log.Printf("[DB-HATA] Tam senkronizasyon talebi kaydedilemedi: %v", err)
+	}
+}
+
+// GetListingHashes platformdaki ilanların son kaydedilen veri özetlerini döndürür
+func GetListingHashes(ctx context.Context, platform string) map[string]string {
+	hashes := make(map[string]string)
+	rows, err := DB.QueryContext(ctx, "SELECT listing_id, payload_hash FROM listing_hashes WHERE platform = ?", platform)
+	if err != nil {
+		log.Printf("[DB-HATA] İlan özetleri okunamadı (%s): %v", platform, err)
+		return hashes
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err == nil {
+			hashes[id] = hash
+		}
+	}
+	return hashes
+}
+
+func SaveListingHash(ctx context.Context, platform, listingID, hash string) {
+	query := `
+		INSERT INTO listing_hashes (platform, listing_id, payload_hash) VALUES (?, ?, ?)
+		ON CONFLICT(platform, listing_id) DO UPDATE SET
+			payload_hash = excluded.payload_hash,
+			updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := DB.ExecContext(ctx, query, platform, listingID, hash); err != nil {
+		log.Printf("[DB-HATA] İlan özeti kaydedilemedi (%s/%s): %v", platform, listingID, err)
+	}
+}
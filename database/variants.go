@@ -0,0 +1,84 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"log"
+)
+
+func InitVariantTables() {
+	// 1. Ürünün hangi varyant grubuna ait olduğu
+	sqlVariants := `
+	CREATE TABLE IF NOT EXISTS product_variants (
+		barcode TEXT PRIMARY KEY,            -- Master barkod
+		group_code TEXT NOT NULL             -- Aynı gruptaki ürünler pazaryerinde tek ilan altında toplanır
+	);`
+
+	// 2. Grubu ayıran eksenler (Renk, Beden, Hacim...)
+	sqlAttributes := `
+	CREATE TABLE IF NOT EXISTS product_variant_attributes (
+		barcode TEXT,
+		attribute_name TEXT,
+		attribute_value TEXT,
+		PRIMARY KEY(barcode, attribute_name)
+	);`
+
+	if _, err := DB.Exec(sqlVariants); err != nil {
+		log.Printf("Tablo oluşturma hatası (product_variants): %v", err)
+	}
+	if _, err := DB.Exec(sqlAttributes); err != nil {
+		log.Printf("Tablo oluşturma hatası (product_variant_attributes): %v", err)
+	}
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_variants_group ON product_variants(group_code)")
+}
+
+// SaveVariant ürünün varyant grubunu ve eksen değerlerini kaydeder (eski eksenler silinir)
+func SaveVariant(ctx context.Context, v core.VariantInfo) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO product_variants (barcode, group_code) VALUES (?, ?)
+		ON CONFLICT(barcode) DO UPDATE SET group_code = excluded.group_code`, v.Barcode, v.GroupCode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_variant_attributes WHERE barcode = ?", v.Barcode); err != nil {
+		return err
+	}
+	for _, a := range v.Attributes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO product_variant_attributes (barcode, attribute_name, attribute_value) VALUES (?, ?, ?)",
+			v.Barcode, a.Name, a.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetVariant ürün bir varyant grubuna aitse bilgilerini döndürür
+func GetVariant(ctx context.Context, barcode string) (core.VariantInfo, bool) {
+	v := core.VariantInfo{Barcode: barcode}
+	err := DB.QueryRowContext(ctx, "SELECT group_code FROM product_variants WHERE barcode = ?", barcode).Scan(&v.GroupCode)
+	if err != nil {
+		return v, false
+	}
+
+	rows, err := DB.QueryContext(ctx, "SELECT attribute_name, attribute_value FROM product_variant_attributes WHERE barcode = ? ORDER BY attribute_name", barcode)
+	if err != nil {
+		return v, true
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a core.VariantAttribute
+		if err := rows.Scan(&a.Name, &a.Value); err == nil {
+			v.Attributes = append(v.Attributes, a)
+		}
+	}
+	return v, true
+}
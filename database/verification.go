@@ -0,0 +1,103 @@
+package database
+
+import (
+	"arbitraj-bot/core"
+	"context"
+	"log"
+)
+
+func InitVerificationTable() {
+	sqlVerifications := `
+	CREATE TABLE IF NOT EXISTS push_verifications (
+		platform TEXT,
+		external_id TEXT,
+		barcode TEXT,
+		expected_price REAL,                 -- Gönderilen (marjlı) fiyat
+		expected_stock INTEGER,
+		status TEXT DEFAULT 'PENDING',       -- PENDING, VERIFIED, MISMATCH, FAILED
+		repush_count INTEGER DEFAULT 0,      -- Uyuşmazlık sonrası tekrar gönderim sayısı
+		seen_price REAL,                     -- Geri okumada platformda görülen değerler
+		seen_stock INTEGER,
+		message TEXT,
+		pushed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		verified_at DATETIME,
+		PRIMARY KEY(platform, external_id)
+	);`
+
+	if _, err := DB.Exec(sqlVerifications); err != nil {
+		log.Printf("Tablo oluşturma hatası (push_verifications): %v", err)
+	}
+}
+
+// SavePushVerification son gönderilen değerleri doğrulanmak üzere kaydeder; önceki doğrulama sıfırlanır
+func SavePushVerification(ctx context.Context, platform, externalID, barcode string, price float64, stock int) {
+	query := `
+		INSERT INTO push_verifications (platform, external_id, barcode, expected_price, expected_stock)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(platform, external_id) DO UPDATE SET
+			barcode = excluded.barcode,
+			expected_price = excluded.expected_price,
+			expected_stock = excluded.expected_stock,
+			status = 'PENDING',
+			repush_count = 0,
+			message = NULL,
+			pushed_at = CURRENT_TIMESTAMP,
+			verified_at = NULL`
+
+	if _, err := DB.ExecContext(ctx, query, platform, externalID, barcode, price, stock); err != nil {
+		log.Printf("[DB-HATA] Doğrulama kaydı açılamadı (%s/%s): %v", platform, externalID, err)
+	}
+}
+
+func GetPushVerification(ctx context.Context, platform, externalID string) (core.PushVerification, bool) {
+	var v core.PushVerification
+	err := DB.QueryRowContext(ctx, `
+		SELECT platform, external_id, COALESCE(barcode, ''), expected_price, expected_stock, status, repush_count,
+			COALESCE(seen_price, 0), COALESCE(seen_stock, 0), COALESCE(message, ''), datetime(pushed_at)
+		FROM push_verifications WHERE platform = ? AND external_id = ?`, platform, externalID).
+		Scan(&v.Platform, &v.ExternalID, &v.Barcode, &v.ExpectedPrice, &v.ExpectedStock, &v.Status, &v.RepushCount,
+			&v.SeenPrice, &v.SeenStock, &v.Message, &v.PushedAt)
+	if err != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// SetVerificationResult geri okuma sonucunu yazar
+func SetVerificationResult(ctx context.Context, platform, externalID, status string, seenPrice float64, seenStock int, message string) {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE push_verifications SET status = ?, seen_price = ?, seen_stock = ?, message = ?, verified_at = CURRENT_TIMESTAMP
+		WHERE platform = ? AND external_id = ?`, status, seenPrice, seenStock, message, platform, externalID)
+	if err != nil {
+		log.Printf("[DB-HATA] Doğrulama sonucu yazılamadı (%s/%s): %v", platform, externalID, err)
+	}
+}
+
+// IncrementRepush uyuşmazlık sonrası yapılan tekrar gönderimi sayar
+func IncrementRepush(ctx context.Context, platform, externalID string) {
+	DB.ExecContext(ctx, "UPDATE push_verifications SET repush_count = repush_count + 1 WHERE platform = ? AND external_id = ?", platform, externalID)
+}
+
+// GetFailedVerifications tekrar gönderimlere rağmen uygulanmayan güncellemeleri döndürür
+func GetFailedVerifications(ctx context.Context) ([]core.PushVerification, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT platform, external_id, COALESCE(barcode, ''), expected_price, expected_stock, status, repush_count,
+			COALESCE(seen_price, 0), COALESCE(seen_stock, 0), COALESCE(message, ''), datetime(pushed_at)
+		FROM push_verifications WHERE status IN ('MISMATCH', 'FAILED')
+		ORDER BY pushed_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []core.PushVerification
+	for rows.Next() {
+		var v core.PushVerification
+		err := rows.Scan(&v.Platform, &v.ExternalID, &v.Barcode, &v.ExpectedPrice, &v.ExpectedStock, &v.Status, &v.RepushCount,
+			&v.SeenPrice, &v.SeenStock, &v.Message, &v.PushedAt)
+		if err == nil {
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
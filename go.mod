@@ -7,6 +7,7 @@ require (
 	github.com/go-resty/resty/v2 v2.17.1
 	github.com/mattn/go-sqlite3 v1.14.32
 	github.com/xuri/excelize/v2 v2.10.0
+	golang.org/x/term v0.36.0
 )
 
 require (
@@ -17,5 +18,6 @@ require (
 	github.com/xuri/nfp v0.0.2-0.20250530014748-2ddeb826f9a9 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
+	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
 )
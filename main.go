@@ -7,79 +7,165 @@ import (
 	"arbitraj-bot/services"
 	"arbitraj-bot/utils"
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
-
-	"github.com/go-resty/resty/v2"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// Kapanışta elindeki işi bitirmesi için menü işlemine tanınan süre
+const shutdownTimeout = 30 * time.Second
+
+// busy: ana döngü bir menü işlemi yürütürken tutulur, yalnızca kullanıcıdan girdi beklerken bırakılır.
+// Kapanış bu kilidi alarak yarıdaki işlemin (paket gönderimi, DB yazımı) bitmesini bekler.
+var busy sync.Mutex
+
 func main() {
+	// İlk Ctrl+C / SIGTERM ctx'i iptal eder ve düzgün kapanışı başlatır; ikincisi süreci doğrudan sonlandırır
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Standart log çıktısında da secret'lar maskelenir (kayıt config yüklenirken yapılır)
+	log.SetOutput(utils.NewRedactingWriter(os.Stderr))
+
 	clearConsole()
 	database.InitDB()
 
+	// Acil durdurma başka bir terminalden de verilebilir: arbitraj-bot --halt-writes "sebep" / --resume-writes
+	if hasArg("--halt-writes") || hasArg("--resume-writes") {
+		os.Exit(runKillSwitchCommand(ctx))
+	}
+
+	// Şifreli secrets dosyası: arbitraj-bot --secret-set pazarama_client_secret / --secret-list
+	if hasArg("--secret-set") || hasArg("--secret-list") {
+		os.Exit(runSecretsCommand())
+	}
+
 	cfg, err := config.LoadConfig("config/config.json")
 	if err != nil {
 		log.Fatalf("Yapılandırma yüklenemedi: %v", err)
 	}
+	database.SetMergePolicy(cfg.MergePolicy)
+
+	// Dry-run: config'de "dry_run": true ya da --dry-run ile açılır
+	if cfg.DryRun || hasArg("--dry-run") {
+		utils.SetDryRun(true)
+		fmt.Println("[DRY-RUN] Yazma istekleri gönderilmeyecek, dry_run_requests tablosuna kaydedilecek.")
+	}
+
+	client := utils.NewHTTPClient()
+
+	// config'deki her mağaza hesabı için ayrı servis
+	markets := services.NewMarketplaces(client, &cfg)
+
+	// Menüsüz tek seferlik senkronizasyon (cron vb. için): arbitraj-bot --sync [--account pazarama:magaza2];
+	// hata türü çıkış kodundan okunur
+	if hasArg("--sync") {
+		syncAll(ctx, markets, argValue("--account"))
+		database.Close()
+		os.Exit(int(exitCode.Load()))
+	}
 
-	client := resty.New()
+	// Gönderilen paketlerin sonuçları kalıcı iş kuyruğundan arka planda takip edilir
+	jobQueue := services.NewJobQueue(markets)
+	jobQueue.Start(ctx, 2)
 
-	hbSvc := services.NewHBService(client, &cfg)
-	pzrSvc := services.NewPazaramaService(client, &cfg)
-	pttSvc := services.NewPttService(client, &cfg)
+	reader := utils.Stdin
 
-	reader := bufio.NewReader(os.Stdin)
+	// Arka plan görevleri (watcher) kapanışta beklenir; kirli ürünlerin fiyat/stoku her mağazaya itilir
+	var background sync.WaitGroup
+	background.Go(func() { StartWatcher(ctx, markets) })
 
-	// go StartWatcher(hbSvc, pzrSvc, pttSvc)
+	busy.Lock()
+	go func() {
+		<-ctx.Done()
+		stop()
+		shutdown(jobQueue, &background)
+	}()
 
 	for {
-		showMenu()
+		// Kapanış başladıysa menü tekrar gösterilmez; süreci kapanış goroutine'i sonlandırır
+		if ctx.Err() != nil {
+			busy.Unlock()
+			select {}
+		}
+
+		showMenu(ctx)
 		var choice int
 		fmt.Print("\nSeçiminiz: ")
+		busy.Unlock()
 		fmt.Scanln(&choice)
+		busy.Lock()
 
 		switch choice {
 		case 1:
 			fmt.Println("\n[*] Tüm pazar yerleri senkronize ediliyor...")
-			hbSvc.SyncProducts()
-			pzrSvc.SyncProducts()
-			pttSvc.SyncProducts()
-			fmt.Println("[OK] İşlem tamamlandı.")
+			syncAll(ctx, markets, "")
 		case 2:
-			showHbMenu(hbSvc, reader)
+			showHbMenu(ctx, markets.HB, reader)
 		case 3:
-			showPazaramaMenu(pzrSvc, reader)
+			showPazaramaMenu(ctx, markets.Pazarama, reader)
 		case 4:
-			showPttMenu(pttSvc, reader)
+			showPttMenu(ctx, markets.Ptt, reader)
 		case 5:
-			showDatabaseMenu(hbSvc, pzrSvc, pttSvc, reader)
+			showDatabaseMenu(ctx, markets, reader)
+		case 6:
+			showJobStatus(ctx)
+		case 7:
+			handleDryRunRequests(ctx, reader)
+		case 8:
+			handleKillSwitch(ctx, reader)
 		case 0:
 			fmt.Println("Programdan çıkılıyor...")
-			os.Exit(0)
+			// ctx iptal edilir; kapanış sinyaldeki gibi aynı yoldan yürür
+			stop()
 		default:
 			fmt.Println("Geçersiz seçim!")
 		}
 	}
 }
 
-func showMenu() {
-	fmt.Println("\n--- ARBİTRAJ BOT ANA KUMANDA MASASI ---")
+func showMenu(ctx context.Context) {
+	title := "\n--- ARBİTRAJ BOT ANA KUMANDA MASASI"
+	if utils.IsDryRun() {
+		title += " [DRY-RUN]"
+	}
+	if halted, _ := database.IsWritesHalted(ctx); halted {
+		title += " [YAZMA DURDURULDU]"
+	}
+	fmt.Println(title + " ---")
 	fmt.Println("1. Tüm Mağazaları Senkronize Et (Merkezi DB Güncelle)")
 	fmt.Println("2. Hepsiburada İşlemleri")
 	fmt.Println("3. Pazarama İşlemleri")
 	fmt.Println("4. PttAVM İşlemleri")
 	fmt.Println("5. Veritabanı ve Excel İşlemleri")
+	fmt.Println("6. Arka Plan İşleri (Paket Takip Durumu)")
+	fmt.Println("7. Dry-Run Kayıtları (Gönderilmeyen İstekler)")
+	fmt.Println("8. Acil Durdurma (Tüm Pazaryerlerine Yazmayı Durdur / Devam Et)")
 	fmt.Println("0. Çıkış")
 }
 
 // --- PAZARAMA MENÜSÜ ---
-func showPazaramaMenu(pzrSvc *services.PazaramaService, reader *bufio.Reader) {
+// Kategori, marka ve özellik işlemleri ortak katalogdur, seçilen ilk mağazanın bilgileriyle bir kez yapılır;
+// ürün yükleme, yayınlama ve paket işlemleri seçilen her mağazada ayrı çalışır.
+func showPazaramaMenu(ctx context.Context, all []*services.PazaramaService, reader *bufio.Reader) {
+	accounts := selectAccounts("Pazarama", all, pzrKey, reader)
+	if len(accounts) == 0 {
+		return
+	}
+	pzrSvc := accounts[0]
+	each := func(run func(*services.PazaramaService) error) { forAccounts(ctx, accounts, pzrKey, run) }
+
 	for {
 		fmt.Println("\n" + strings.Repeat("-", 45))
-		fmt.Println("            PAZARAMA İŞLEMLERİ")
+		fmt.Println("            PAZARAMA İŞLEMLERİ" + accountsLabel(accounts, all, pzrKey))
 		fmt.Println(strings.Repeat("-", 45))
 		fmt.Println("1- Excel Kategori ID Doldur")
 		fmt.Println("2- Marka Listesini Senkronize Et")
@@ -89,32 +175,49 @@ func showPazaramaMenu(pzrSvc *services.PazaramaService, reader *bufio.Reader) {
 		fmt.Println("6- Panel vs Excel Karşılaştır (Diff)")
 		fmt.Println("7- Eksik Ürünleri Yükle")
 		fmt.Println("8- Kategori Ağacını Güncelle (Yerel DB'ye Kaydet)")
+		fmt.Println("9- Master DB'den Eksik Ürünleri Yayınla")
+		fmt.Println("10- Bekleyen Paket Sonuçlarını Kontrol Et")
+		fmt.Println("11- Reddedilen Ürünler (Listele / Tekrar Dene)")
 		fmt.Println("0- Ana Menüye Dön")
 
 		choice := askInput("\nSeçiminiz: ", reader)
-		token, _ := pzrSvc.GetToken()
 
 		switch choice {
 		case "1":
-			pzrSvc.FillPazaramaCategoryIDs("./storage/pazarama_urun_yukleme.xlsx")
+			report(pzrSvc.FillPazaramaCategoryIDs(ctx, "./storage/pazarama_urun_yukleme.xlsx"))
 		case "2":
-			pzrSvc.SyncPazaramaBrands(token)
+			report(pzrSvc.SyncPazaramaBrands(ctx))
 		case "3":
 			id := askInput("Analiz edilecek Kategori ID: ", reader)
-			pzrSvc.AutoMapMandatoryAttributes(token, id)
+			report(pzrSvc.AutoMapMandatoryAttributes(ctx, id))
 		case "4":
 			rowStr := askInput("Excel satır numarası: ", reader)
 			idx, _ := strconv.Atoi(rowStr)
-			pzrSvc.UploadSingleProductFromExcelPazarama(token, "./storage/pazarama_urun_yukleme.xlsx", idx-1)
+			each(func(s *services.PazaramaService) error {
+				_, _, err := s.UploadSingleProductFromExcelPazarama(ctx, "./storage/pazarama_urun_yukleme.xlsx", idx-1)
+				return err
+			})
 		case "5":
-			pzrSvc.BulkUploadPazarama("./storage/pazarama_urun_yukleme.xlsx")
+			each(func(s *services.PazaramaService) error {
+				return s.BulkUploadPazarama(ctx, "./storage/pazarama_urun_yukleme.xlsx")
+			})
 		case "6":
 			handlePazaramaCompare()
 		case "7":
-			pzrSvc.UploadMissingProductsPazarama("./storage/pazarama_urun_yukleme.xlsx", "./storage/eksik_urunler.xlsx")
+			each(func(s *services.PazaramaService) error {
+				return s.UploadMissingProductsPazarama(ctx, "./storage/pazarama_urun_yukleme.xlsx", "./storage/eksik_urunler.xlsx")
+			})
 		case "8":
 			fmt.Println("\n[*] Pazarama kategori ağacı çekiliyor, bu işlem biraz sürebilir...")
-			pzrSvc.SyncCategories(token)
+			report(pzrSvc.SyncCategories(ctx))
+		case "9":
+			each(func(s *services.PazaramaService) error { return s.PublishProducts(ctx) })
+		case "10":
+			each(func(s *services.PazaramaService) error { return s.RefreshBatchResults(ctx) })
+		case "11":
+			for _, s := range accounts {
+				handlePazaramaRejections(ctx, s, reader)
+			}
 		case "0":
 			return
 		}
@@ -122,21 +225,34 @@ func showPazaramaMenu(pzrSvc *services.PazaramaService, reader *bufio.Reader) {
 }
 
 // --- HEPSİBURADA MENÜSÜ ---
-func showHbMenu(hbSvc *services.HBService, reader *bufio.Reader) {
+func showHbMenu(ctx context.Context, all []*services.HBService, reader *bufio.Reader) {
+	accounts := selectAccounts("Hepsiburada", all, hbKey, reader)
+	if len(accounts) == 0 {
+		return
+	}
+	each := func(run func(*services.HBService) error) { forAccounts(ctx, accounts, hbKey, run) }
+
 	for {
 		fmt.Println("\n" + strings.Repeat("-", 45))
-		fmt.Println("           HEPSİBURADA İŞLEMLERİ")
+		fmt.Println("           HEPSİBURADA İŞLEMLERİ" + accountsLabel(accounts, all, hbKey))
 		fmt.Println(strings.Repeat("-", 45))
 		fmt.Println("1- Ürünleri ve Stokları Güncelle (Merkezi DB Güncelle)")
 		fmt.Println("2- Kategori Listesini Senkronize Et (Merkezi DB Güncelle)")
+		fmt.Println("3- Master DB'den Eksik Ürünleri Yayınla")
+		fmt.Println("4- Bekleyen Yükleme Sonuçlarını Kontrol Et")
 		fmt.Println("0- Ana Menüye Dön")
 
 		choice := askInput("\nSeçiminiz: ", reader)
 		switch choice {
 		case "1":
-			hbSvc.SyncProducts()
+			each(func(s *services.HBService) error { return s.SyncProducts(ctx) })
 		case "2":
-			hbSvc.SyncCategories()
+			// Kategori ağacı ortak; tek mağazanın bilgileriyle çekilir
+			report(accounts[0].SyncCategories(ctx))
+		case "3":
+			each(func(s *services.HBService) error { return s.PublishProducts(ctx) })
+		case "4":
+			each(func(s *services.HBService) error { return s.RefreshImportResults(ctx) })
 		case "0":
 			return
 		}
@@ -144,21 +260,39 @@ func showHbMenu(hbSvc *services.HBService, reader *bufio.Reader) {
 }
 
 // --- PTT MENÜSÜ ---
-func showPttMenu(pttSvc *services.PttService, reader *bufio.Reader) {
+func showPttMenu(ctx context.Context, all []*services.PttService, reader *bufio.Reader) {
+	accounts := selectAccounts("PttAVM", all, pttKey, reader)
+	if len(accounts) == 0 {
+		return
+	}
+	each := func(run func(*services.PttService) error) { forAccounts(ctx, accounts, pttKey, run) }
+
 	for {
 		fmt.Println("\n" + strings.Repeat("-", 45))
-		fmt.Println("            PttAVM İŞLEMLERİ")
+		fmt.Println("            PttAVM İŞLEMLERİ" + accountsLabel(accounts, all, pttKey))
 		fmt.Println(strings.Repeat("-", 45))
 		fmt.Println("1- Ürün Senkronizasyonu 'SOAP' (Merkezi DB Güncelle)")
 		fmt.Println("2- Kategori Ağacını Güncelle (Merkezi DB Güncelle)")
+		fmt.Println("3- Master DB'den Eksik Ürünleri Yayınla")
+		fmt.Println("4- Panel Oturumu Aç (OTP ile Giriş)")
 		fmt.Println("0- Ana Menüye Dön")
 
 		choice := askInput("\nSeçiminiz: ", reader)
 		switch choice {
 		case "1":
-			pttSvc.SyncProducts()
+			each(func(s *services.PttService) error { return s.SyncProducts(ctx) })
 		case "2":
-			pttSvc.ListAllPttCategories()
+			// Kategori ağacı ortak; tek mağazanın bilgileriyle çekilir
+			report(accounts[0].ListAllPttCategories(ctx))
+		case "3":
+			each(func(s *services.PttService) error { return s.PublishProducts(ctx) })
+		case "4":
+			each(func(s *services.PttService) error {
+				if exp := s.Auth.ExpiresAt(); time.Until(exp) > 0 {
+					fmt.Printf("[LOG] Mevcut oturum %s'e kadar geçerli.\n", exp.Format("02.01.2006 15:04"))
+				}
+				return s.Auth.Login(ctx)
+			})
 		case "0":
 			return
 		}
@@ -166,7 +300,7 @@ func showPttMenu(pttSvc *services.PttService, reader *bufio.Reader) {
 }
 
 // --- DATABASE MENÜSÜ ---
-func showDatabaseMenu(hb *services.HBService, pzr *services.PazaramaService, ptt *services.PttService, reader *bufio.Reader) {
+func showDatabaseMenu(ctx context.Context, markets *services.Marketplaces, reader *bufio.Reader) {
 	for {
 		fmt.Println("\n" + strings.Repeat("-", 45))
 		fmt.Println("           VERİTABANI İŞLEMLERİ")
@@ -175,6 +309,18 @@ func showDatabaseMenu(hb *services.HBService, pzr *services.PazaramaService, ptt
 		fmt.Println("2- Pazarama'dan Çek ve Eşleştir")
 		fmt.Println("3- PTT'den Çek ve Eşleştir")
 		fmt.Println("4- Hepsiburada'dan Çek ve Eşleştir")
+		fmt.Println("5- Tedarikçi Listesini Excel'den Yükle")
+		fmt.Println("6- Satın Alma Önerisi Oluştur (Excel)")
+		fmt.Println("7- Satış ve Kâr Raporu (Tahmini Satışlar Dahil)")
+		fmt.Println("8- Paket/Set Tanımlarını Excel'den Yükle")
+		fmt.Println("9- Paket Stok ve Fiyatlarını Yeniden Hesapla")
+		fmt.Println("10- Varyant Gruplarını Excel'den Yükle")
+		fmt.Println("11- Platformdan Kaldırılan İlanlar Raporu")
+		fmt.Println("12- Sonraki Senkronizasyonu Tam Yap (Değişmeyenler Dahil)")
+		fmt.Println("13- Veri Çakışmaları (Listele / Kabul Et)")
+		fmt.Println("14- Mükerrer İlanlar (Kalacak İlanı Seç)")
+		fmt.Println("15- Gönderim Özetlerini Sıfırla (Sonraki Fiyat/Stok Gönderimini Zorla)")
+		fmt.Println("16- Sipariş Listesini Excel'den Yükle (Stoktan Düş)")
 		fmt.Println("0- Ana Menüye Dön")
 
 		choice := askInput("\nSeçiminiz: ", reader)
@@ -183,39 +329,635 @@ func showDatabaseMenu(hb *services.HBService, pzr *services.PazaramaService, ptt
 			filePath := "./storage/urun_listesi.xlsx"
 			products, _ := utils.ReadProductsFromExcel(filePath)
 			for _, p := range products {
-				database.SaveProduct(core.Product{
+				database.SaveProduct(ctx, core.Product{
 					Barcode:     p.Barcode,
 					ProductName: p.Title,
 					Price:       p.Price,
 					Stock:       p.Stock,
-				})
+				}, core.SourceMaster)
 			}
 			fmt.Println("[OK] Excel verileri DB'ye işlendi.")
 		case "2":
-			pzr.SyncProducts()
+			accounts := selectAccounts("Pazarama", markets.Pazarama, pzrKey, reader)
+			forAccounts(ctx, accounts, pzrKey, func(s *services.PazaramaService) error { return s.SyncProducts(ctx) })
 		case "3":
-			ptt.SyncProducts()
+			accounts := selectAccounts("PttAVM", markets.Ptt, pttKey, reader)
+			forAccounts(ctx, accounts, pttKey, func(s *services.PttService) error { return s.SyncProducts(ctx) })
 		case "4":
-			hb.SyncProducts()
+			accounts := selectAccounts("Hepsiburada", markets.HB, hbKey, reader)
+			forAccounts(ctx, accounts, hbKey, func(s *services.HBService) error { return s.SyncProducts(ctx) })
+		case "5":
+			suppliers, err := utils.ReadSuppliersFromExcel("./storage/tedarikci_listesi.xlsx")
+			if err != nil {
+				fmt.Printf("[HATA] Tedarikçi listesi okunamadı: %v\n", err)
+				continue
+			}
+			missing := 0
+			for _, s := range suppliers {
+				database.SaveSupplierInfo(ctx, s)
+				if !s.StockReported {
+					continue
+				}
+				// Master'da olmayan barkod için isimsiz/fiyatsız ürün açılmaz; yayın akışları onu hatalı ürün olarak seçerdi
+				if !database.ProductExists(ctx, s.Barcode) {
+					missing++
+					continue
+				}
+				database.SaveProduct(ctx, core.Product{Barcode: s.Barcode, Stock: s.Stock}, core.SourceSupplier)
+			}
+			fmt.Printf("[OK] %d tedarikçi kaydı DB'ye işlendi.\n", len(suppliers))
+			if missing > 0 {
+				fmt.Printf("[UYARI] %d barkod master'da olmadığı için tedarikçi stoğu uygulanmadı.\n", missing)
+			}
+		case "6":
+			handlePurchaseSuggestions(ctx, reader)
+		case "7":
+			handleProfitReport(ctx, reader)
+		case "8":
+			bundles, err := utils.ReadBundlesFromExcel("./storage/paket_tanimlari.xlsx")
+			if err != nil {
+				fmt.Printf("[HATA] Paket listesi okunamadı: %v\n", err)
+				continue
+			}
+			for _, b := range bundles {
+				if err := database.SaveBundle(ctx, b); err != nil {
+					fmt.Printf("[HATA] Paket kaydedilemedi (%s): %v\n", b.BundleBarcode, err)
+				}
+			}
+			fmt.Printf("[OK] %d paket tanımı DB'ye işlendi.\n", len(bundles))
+			services.RecalculateAllBundles(ctx)
+		case "9":
+			services.RecalculateAllBundles(ctx)
+		case "10":
+			variants, err := utils.ReadVariantsFromExcel("./storage/varyant_gruplari.xlsx")
+			if err != nil {
+				fmt.Printf("[HATA] Varyant listesi okunamadı: %v\n", err)
+				continue
+			}
+			for _, v := range variants {
+				if err := database.SaveVariant(ctx, v); err != nil {
+					fmt.Printf("[HATA] Varyant kaydedilemedi (%s): %v\n", v.Barcode, err)
+				}
+			}
+			fmt.Printf("[OK] %d ürünün varyant bilgisi DB'ye işlendi.\n", len(variants))
+		case "11":
+			handleDelistedReport(ctx)
+		case "12":
+			database.RequestFullSync(ctx)
+			fmt.Println("[OK] Bir sonraki senkronizasyonda tüm ilanlar baştan işlenecek.")
+		case "13":
+			handleFieldConflicts(ctx, reader)
+		case "14":
+			handleDuplicateListings(ctx, reader)
+		case "15":
+			n := database.ClearPushFingerprints(ctx)
+			fmt.Printf("[OK] %d ilanın gönderim özeti silindi; sonraki fiyat/stok gönderimleri atlanmadan yapılacak.\n", n)
+		case "16":
+			orders, err := utils.ReadOrdersFromExcel("./storage/siparis_listesi.xlsx")
+			if err != nil {
+				fmt.Printf("[HATA] Sipariş listesi okunamadı: %v\n", err)
+				continue
+			}
+			applied := 0
+			for _, o := range orders {
+				if services.RecordOrder(ctx, o) {
+					applied++
+				}
+			}
+			fmt.Printf("[OK] %d sipariş satırı DB'ye işlendi, %d yeni satır stoktan düşüldü.\n", len(orders), applied)
 		case "0":
 			return
 		}
 	}
 }
 
+// shutdown worker'ları ve arka plan görevlerini durdurur, yarıdaki menü işleminin
+// bitmesini (en fazla shutdownTimeout) bekler, iş durumunu diske yazıp son hatanın koduyla çıkar
+func shutdown(jobQueue *services.JobQueue, background *sync.WaitGroup) {
+	fmt.Println("\n[LOG] Kapanış başladı; yarıdaki işlemler tamamlanıyor (hemen çıkmak için tekrar Ctrl+C)...")
+	jobQueue.Stop()
+	background.Wait()
+
+	idle := make(chan struct{})
+	go func() {
+		busy.Lock()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-time.After(shutdownTimeout):
+		fmt.Println("[UYARI] Menü işlemi zamanında bitmedi, yine de kapatılıyor.")
+	}
+
+	database.Close()
+	fmt.Println("[OK] Program kapatıldı.")
+	os.Exit(int(exitCode.Load()))
+}
+
 // --- YARDIMCI FONKSİYONLAR ---
 func askInput(prompt string, reader *bufio.Reader) string {
 	fmt.Print(prompt)
+	// Girdi beklenirken yarıda iş yoktur; kapanış beklemeden ilerleyebilir
+	busy.Unlock()
 	input, _ := reader.ReadString('\n')
+	busy.Lock()
 	return strings.TrimSpace(input)
 }
 
+func handlePazaramaRejections(ctx context.Context, pzrSvc *services.PazaramaService, reader *bufio.Reader) {
+	rejections, err := database.GetOpenRejections(ctx, pzrSvc.Key)
+	if err != nil {
+		fmt.Printf("[HATA] Red listesi okunamadı: %v\n", err)
+		return
+	}
+	if len(rejections) == 0 {
+		fmt.Printf("[OK] %s: reddedilmiş ürün yok.\n", pzrSvc.Key)
+		return
+	}
+
+	fmt.Printf("\n%-20s | %-19s | %s\n", "BARKOD", "TARİH", "SEBEP")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, r := range rejections {
+		fmt.Printf("%-20s | %-19s | %s\n", r.Barcode, r.CreatedAt, r.Reason)
+	}
+	fmt.Printf("\n%s: toplam %d reddedilmiş ürün.\n", pzrSvc.Key, len(rejections))
+
+	choice := askInput("Tekrar denensin mi? (barkod(lar) virgülle / 'hepsi' / boş: vazgeç): ", reader)
+	if choice == "" {
+		return
+	}
+
+	var barcodes []string
+	if strings.EqualFold(choice, "hepsi") {
+		for _, r := range rejections {
+			barcodes = append(barcodes, r.Barcode)
+		}
+	} else {
+		for _, b := range strings.Split(choice, ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				barcodes = append(barcodes, b)
+			}
+		}
+	}
+
+	report(pzrSvc.RetryRejected(ctx, barcodes))
+}
+
 func handlePazaramaCompare() {
 	origFile := "./storage/pazarama_urun_yukleme.xlsx"
 	panelFile := "./storage/panel_envanter.xlsx"
 	utils.CompareExcelBarcodes(origFile, panelFile)
 }
 
+func handlePurchaseSuggestions(ctx context.Context, reader *bufio.Reader) {
+	lookback, err := strconv.Atoi(askInput("Satış hızı için kaç günlük geçmiş? (Örn: 30): ", reader))
+	if err != nil || lookback <= 0 {
+		lookback = 30
+	}
+	cover, err := strconv.Atoi(askInput("Hedef stok yeterlilik süresi (gün, Örn: 45): ", reader))
+	if err != nil || cover <= 0 {
+		cover = 45
+	}
+
+	suggestions, err := services.BuildReorderSuggestions(ctx, lookback, cover)
+	if err != nil {
+		report(err)
+		return
+	}
+	if len(suggestions) == 0 {
+		fmt.Println("[OK] Sipariş verilmesi gereken ürün yok.")
+		return
+	}
+
+	path, err := utils.SavePurchaseOrdersToExcel(suggestions)
+	if err != nil {
+		fmt.Printf("[HATA] Excel kaydedilemedi: %v\n", err)
+		return
+	}
+	fmt.Printf("[OK] %d öneri '%s' dosyasına kaydedildi.\n", len(suggestions), path)
+}
+
+func handleProfitReport(ctx context.Context, reader *bufio.Reader) {
+	days, err := strconv.Atoi(askInput("Kaç günlük rapor? (Örn: 30): ", reader))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	summaries, err := database.GetSalesSummary(ctx, days)
+	if err != nil {
+		fmt.Printf("[HATA] Satış özeti alınamadı: %v\n", err)
+		return
+	}
+
+	path, err := utils.SaveProfitReportToExcel(summaries)
+	if err != nil {
+		fmt.Printf("[HATA] Excel kaydedilemedi: %v\n", err)
+		return
+	}
+	fmt.Printf("[OK] %d ürünlük kâr raporu '%s' dosyasına kaydedildi.\n", len(summaries), path)
+}
+
+// syncAll her mağazayı sırayla senkronize eder; biri yarıda kalsa da diğerleri çalışır.
+// account boş değilse yalnızca o hesap (Örn: "pazarama:magaza2") senkronize edilir.
+func syncAll(ctx context.Context, markets *services.Marketplaces, account string) {
+	type syncTarget struct {
+		name string
+		sync func(context.Context) error
+	}
+	var platforms []syncTarget
+	for _, s := range markets.HB {
+		platforms = append(platforms, syncTarget{"Hepsiburada " + s.Key, s.SyncProducts})
+	}
+	for _, s := range markets.Pazarama {
+		platforms = append(platforms, syncTarget{"Pazarama " + s.Key, s.SyncProducts})
+	}
+	for _, s := range markets.Ptt {
+		platforms = append(platforms, syncTarget{"PttAVM " + s.Key, s.SyncProducts})
+	}
+
+	if account != "" {
+		keys := markets.Keys()
+		idx := slices.Index(keys, account)
+		if idx < 0 {
+			report(fmt.Errorf("%s hesabı tanımlı değil (tanımlı hesaplar: %s)", account, strings.Join(keys, ", ")))
+			return
+		}
+		platforms = platforms[idx : idx+1]
+	}
+
+	failed := 0
+	for _, p := range platforms {
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] Senkronizasyon kapanış nedeniyle kesildi.")
+			return
+		}
+		if err := p.sync(ctx); err != nil {
+			fmt.Printf("[!] %s senkronizasyonu tamamlanamadı:\n", p.name)
+			report(err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("[UYARI] %d mağaza senkronize edilemedi, verileri eksik olabilir.\n", failed)
+		return
+	}
+	fmt.Println("[OK] İşlem tamamlandı.")
+}
+
+// showJobStatus iş kuyruğundaki son paket takip işlerini listeler
+func showJobStatus(ctx context.Context) {
+	counts := database.CountJobsByStatus(ctx)
+	fmt.Println("\n" + strings.Repeat("-", 45))
+	fmt.Printf("Bekleyen: %d | Çalışan: %d | Biten: %d | Zaman Aşımı: %d | Hatalı: %d\n",
+		counts["QUEUED"], counts["RUNNING"], counts["DONE"], counts["TIMEOUT"], counts["FAILED"])
+	fmt.Println(strings.Repeat("-", 45))
+
+	jobs, err := database.ListJobs(ctx, 30)
+	if err != nil {
+		fmt.Printf("[HATA] İşler okunamadı: %v\n", err)
+		return
+	}
+	if len(jobs) == 0 {
+		fmt.Println("[OK] Takip edilen paket yok.")
+		return
+	}
+
+	for _, j := range jobs {
+		fmt.Printf("#%d %-15s %-18s %-40s %-8s deneme:%d sonraki:%s son:%s\n",
+			j.ID, j.Kind, j.Platform, j.ExternalID, j.Status, j.Attempts, j.NextRunAt, j.DeadlineAt)
+		if j.LastError != "" {
+			fmt.Printf("    └─ %s\n", j.LastError)
+		}
+	}
+
+	mismatches, err := database.GetFailedVerifications(ctx)
+	if err != nil || len(mismatches) == 0 {
+		return
+	}
+	fmt.Printf("\n[UYARI] Platformda uygulanmamış görünen %d fiyat/stok güncellemesi:\n", len(mismatches))
+	for _, v := range mismatches {
+		fmt.Printf("%-9s %-20s %-20s %-8s tekrar:%d %s\n", v.Platform, v.ExternalID, v.Barcode, v.Status, v.RepushCount, v.Message)
+	}
+}
+
+// runKillSwitchCommand komut satırından verilen durdurma/devam komutunu uygular ve çıkış kodunu döndürür
+func runKillSwitchCommand(ctx context.Context) int {
+	var err error
+	if hasArg("--resume-writes") {
+		err = database.SetWritesHalted(ctx, false, "")
+	} else {
+		reason := argValue("--halt-writes")
+		if reason == "" {
+			reason = "komut satırından durduruldu"
+		}
+		err = database.SetWritesHalted(ctx, true, reason)
+	}
+	if err != nil {
+		fmt.Printf("[HATA] Acil durdurma anahtarı yazılamadı: %v\n", err)
+		return 1
+	}
+
+	halted, reason := database.IsWritesHalted(ctx)
+	if halted {
+		fmt.Printf("[DURDURULDU] Tüm pazaryerlerine yazma durduruldu: %s\n", reason)
+	} else {
+		fmt.Println("[OK] Pazaryerlerine yazma yeniden açıldı.")
+	}
+	return 0
+}
+
+// runSecretsCommand config/secrets.enc'e secret ekler, siler ya da kayıtlı adları listeler; değerler ekrana yazılmaz
+func runSecretsCommand() int {
+	path := config.SecretsPath("config/config.json")
+	_, statErr := os.Stat(path)
+
+	passphrase, err := config.Passphrase()
+	if err != nil {
+		fmt.Printf("[HATA] %v\n", err)
+		return 1
+	}
+	// Dosya ilk kez oluşturuluyorsa yanlış yazılan parolayla kilitlenmesin
+	if os.IsNotExist(statErr) && os.Getenv(config.PassphraseEnv) == "" {
+		fmt.Print("[?] Parolayı tekrar girin: ")
+		if config.ReadPassword() != passphrase {
+			fmt.Println("[HATA] Parolalar eşleşmiyor.")
+			return 1
+		}
+	}
+
+	secrets, err := config.LoadSecrets(path, passphrase)
+	if err != nil {
+		fmt.Printf("[HATA] %v\n", err)
+		return 1
+	}
+
+	if hasArg("--secret-list") {
+		names := config.SecretNames(secrets)
+		if len(names) == 0 {
+			fmt.Println("[OK] Secrets dosyası boş.")
+		}
+		for _, name := range names {
+			fmt.Printf("- %s (config.json: \"secret:%s\")\n", name, name)
+		}
+		return 0
+	}
+
+	name := argValue("--secret-set")
+	if name == "" {
+		fmt.Println("[HATA] Kullanım: arbitraj-bot --secret-set <ad>")
+		return 1
+	}
+	fmt.Printf("[?] %s değeri (boş bırakılırsa silinir): ", name)
+	value := config.ReadPassword()
+	if value == "" {
+		delete(secrets, name)
+	} else {
+		secrets[name] = value
+	}
+
+	if err := config.SaveSecrets(path, passphrase, secrets); err != nil {
+		fmt.Printf("[HATA] Secrets dosyası yazılamadı: %v\n", err)
+		return 1
+	}
+	if value == "" {
+		fmt.Printf("[OK] %s silindi.\n", name)
+	} else {
+		fmt.Printf("[OK] %s kaydedildi. config.json'da \"secret:%s\" olarak kullanın.\n", name, name)
+	}
+	return 0
+}
+
+// handleKillSwitch acil durdurma anahtarını ve engellenen istek kuyruğunu yönetir
+func handleKillSwitch(ctx context.Context, reader *bufio.Reader) {
+	halted, reason := database.IsWritesHalted(ctx)
+	if halted {
+		fmt.Printf("\n[DURDURULDU] Yazmalar durdurulmuş: %s\n", reason)
+	} else {
+		fmt.Println("\n[OK] Yazmalar açık.")
+	}
+	fmt.Printf("Engellenip incelemeyi bekleyen istek: %d\n", database.CountBlockedWrites(ctx))
+
+	fmt.Println("1- Tüm Yazmaları Durdur")
+	fmt.Println("2- Yazmaları Yeniden Aç")
+	fmt.Println("3- Engellenen İstekleri Listele")
+	fmt.Println("4- Engellenen İstekleri İncelendi Olarak Kapat")
+	fmt.Println("0- Geri")
+
+	switch askInput("Seçim: ", reader) {
+	case "1":
+		reason := askInput("Sebep: ", reader)
+		if reason == "" {
+			reason = "menüden durduruldu"
+		}
+		if err := database.SetWritesHalted(ctx, true, reason); err != nil {
+			fmt.Printf("[HATA] Anahtar yazılamadı: %v\n", err)
+			return
+		}
+		fmt.Println("[DURDURULDU] Tüm pazaryerlerine yazma durduruldu. Okumalar devam eder.")
+	case "2":
+		if !strings.EqualFold(askInput("Yazmalar yeniden açılsın mı? (e/h): ", reader), "e") {
+			return
+		}
+		if err := database.SetWritesHalted(ctx, false, ""); err != nil {
+			fmt.Printf("[HATA] Anahtar yazılamadı: %v\n", err)
+			return
+		}
+		fmt.Println("[OK] Yazmalar açıldı. Kirli kalan ürünler watcher ile tekrar gönderilir.")
+	case "3":
+		writes, err := database.GetBlockedWrites(ctx, 30)
+		if err != nil {
+			fmt.Printf("[HATA] Engellenen istekler okunamadı: %v\n", err)
+			return
+		}
+		for _, w := range writes {
+			fmt.Printf("#%d %-19s %-9s %-22s %s %s\n", w.ID, w.CreatedAt, w.Platform, w.Operation, w.Method, w.URL)
+			fmt.Printf("    └─ %s\n", truncate(w.Payload, 200))
+		}
+	case "4":
+		database.MarkBlockedWritesReviewed(ctx)
+		fmt.Println("[OK] Engellenen istekler incelendi olarak kapatıldı.")
+	}
+}
+
+// handleDryRunRequests dry-run modunda gönderilmeyen istekleri gösterir
+func handleDryRunRequests(ctx context.Context, reader *bufio.Reader) {
+	requests, err := database.GetDryRunRequests(ctx, 30)
+	if err != nil {
+		fmt.Printf("[HATA] Dry-run kayıtları okunamadı: %v\n", err)
+		return
+	}
+	if len(requests) == 0 {
+		fmt.Println("[OK] Kayıtlı dry-run isteği yok.")
+		return
+	}
+
+	fmt.Printf("\n%-5s | %-9s | %-22s | %-5s | %-19s | %s\n", "ID", "PLATFORM", "İŞLEM", "ADET", "SON GÖRÜLME", "URL")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, r := range requests {
+		fmt.Printf("%-5d | %-9s | %-22s | %-5d | %-19s | %s %s\n", r.ID, r.Platform, r.Operation, r.SeenCount, r.LastSeenAt, r.Method, r.URL)
+	}
+
+	choice := askInput("Gövdesini görmek için ID / 'temizle' / boş: vazgeç: ", reader)
+	if choice == "" {
+		return
+	}
+	if strings.EqualFold(choice, "temizle") {
+		database.ClearDryRunRequests(ctx)
+		fmt.Println("[OK] Dry-run kayıtları temizlendi.")
+		return
+	}
+
+	id, _ := strconv.Atoi(choice)
+	for _, r := range requests {
+		if r.ID == id {
+			fmt.Printf("\n%s %s\n%s\n", r.Method, r.URL, r.Payload)
+			return
+		}
+	}
+	fmt.Println("[UYARI] Bu ID listede yok.")
+}
+
+// handleFieldConflicts alan sahibi olmayan kaynaklardan gelip uygulanmayan değerleri listeler
+func handleFieldConflicts(ctx context.Context, reader *bufio.Reader) {
+	conflicts, err := database.GetOpenFieldConflicts(ctx)
+	if err != nil {
+		fmt.Printf("[HATA] Çakışmalar okunamadı: %v\n", err)
+		return
+	}
+	if len(conflicts) == 0 {
+		fmt.Println("[OK] Açık veri çakışması yok.")
+		return
+	}
+
+	for i, c := range conflicts {
+		fmt.Printf("%d) %-20s %-14s %-9s mevcut: %-15s gelen: %-15s (%d kez, son: %s)\n",
+			i+1, c.Barcode, c.Field, c.Source, c.CurrentValue, c.IncomingValue, c.SeenCount, c.LastSeenAt)
+	}
+
+	input := askInput("\nKabul edilecek satır numaraları (virgülle), 'yoksay' ile hepsini kapat, boş bırak çık: ", reader)
+	if input == "" {
+		return
+	}
+	if strings.EqualFold(input, "yoksay") {
+		database.DismissFieldConflicts(ctx)
+		fmt.Println("[OK] Tüm çakışmalar mevcut değerler korunarak kapatıldı.")
+		return
+	}
+
+	for _, part := range strings.Split(input, ",") {
+		idx, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || idx < 1 || idx > len(conflicts) {
+			fmt.Printf("[!] Geçersiz satır: %s\n", part)
+			continue
+		}
+		c := conflicts[idx-1]
+		if err := database.AcceptFieldConflict(ctx, c); err != nil {
+			fmt.Printf("[HATA] %s/%s uygulanamadı: %v\n", c.Barcode, c.Field, err)
+			continue
+		}
+		fmt.Printf("[OK] %s | %s = %s\n", c.Barcode, c.Field, c.IncomingValue)
+	}
+}
+
+// handleDuplicateListings açık mükerrer ilan çiftleri için kalacak ilanı seçtirir;
+// karar kaydedilir ve sonraki senkronizasyonlarda diğer ilan yok sayılır
+func handleDuplicateListings(ctx context.Context, reader *bufio.Reader) {
+	duplicates, err := database.GetDuplicateListings(ctx, "OPEN")
+	if err != nil {
+		fmt.Printf("[HATA] Mükerrer ilanlar okunamadı: %v\n", err)
+		return
+	}
+
+	if len(duplicates) == 0 {
+		fmt.Println("[OK] Çözüm bekleyen mükerrer ilan yok.")
+	}
+
+	for i, d := range duplicates {
+		fmt.Printf("\n%d/%d) %s | Barkod: %s (%d kez görüldü, son: %s)\n", i+1, len(duplicates), d.Platform, d.Barcode, d.SeenCount, d.LastSeenAt)
+		fmt.Printf("   1- %s (%.2f TL, %d adet)\n", d.ListingA, d.PriceA, d.StockA)
+		fmt.Printf("   2- %s (%.2f TL, %d adet)\n", d.ListingB, d.PriceB, d.StockB)
+
+		choice := askInput("Kalacak ilan (1/2, boş: atla, q: çık): ", reader)
+		var canonical string
+		switch choice {
+		case "1":
+			canonical = d.ListingA
+		case "2":
+			canonical = d.ListingB
+		case "q":
+			return
+		default:
+			continue
+		}
+
+		if err := database.ResolveDuplicate(ctx, d, canonical); err != nil {
+			fmt.Printf("[HATA] Karar kaydedilemedi: %v\n", err)
+			continue
+		}
+		fmt.Printf("[OK] %s kalacak, diğer ilan kapatılacak olarak işaretlendi.\n", canonical)
+	}
+
+	resolved, err := database.GetDuplicateListings(ctx, "RESOLVED")
+	if err != nil || len(resolved) == 0 {
+		return
+	}
+	fmt.Println("\n--- PAZARYERİNDE KAPATILMASI GEREKEN İLANLAR ---")
+	for _, d := range resolved {
+		fmt.Printf("%-9s %-20s kapat: %-25s (kalan: %s)\n", d.Platform, d.Barcode, d.DeactivateID, d.CanonicalID)
+	}
+}
+
+func handleDelistedReport(ctx context.Context) {
+	listings, err := database.GetDelistedListings(ctx)
+	if err != nil {
+		fmt.Printf("[HATA] Kaldırılan ilanlar okunamadı: %v\n", err)
+		return
+	}
+	if len(listings) == 0 {
+		fmt.Println("[OK] Platformdan kaldırılmış ilan yok.")
+		return
+	}
+
+	for _, l := range listings {
+		fmt.Printf("[DELISTED] %-9s %-20s %-40s (%s)\n", l.Platform, l.Barcode, l.ProductName, l.DelistedAt)
+	}
+
+	path, err := utils.SaveDelistedReportToExcel(listings)
+	if err != nil {
+		fmt.Printf("[HATA] Excel kaydedilemedi: %v\n", err)
+		return
+	}
+	fmt.Printf("[OK] %d kaldırılan ilan '%s' dosyasına kaydedildi.\n", len(listings), path)
+}
+
+func hasArg(name string) bool {
+	for _, a := range os.Args[1:] {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// argValue bayraktan sonra gelen değeri döndürür (Örn: --halt-writes "fiyat hatası")
+func argValue(name string) string {
+	for i, a := range os.Args[1:] {
+		if a == name && i+2 < len(os.Args) && !strings.HasPrefix(os.Args[i+2], "--") {
+			return os.Args[i+2]
+		}
+	}
+	return ""
+}
+
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= max {
+		return s
+	}
+	return string([]rune(s)[:max]) + "..."
+}
+
 func clearConsole() {
 	fmt.Print("\033[H\033[2J")
 }
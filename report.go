@@ -0,0 +1,85 @@
+package main
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Çıkış kodları: betikler hatanın türünü buradan ayırt eder
+const (
+	exitOK          = 0
+	exitFailed      = 1
+	exitAuth        = 2
+	exitRateLimited = 3
+	exitValidation  = 4
+	exitRejected    = 5
+	exitPartial     = 6
+	exitInterrupted = 130
+)
+
+// exitCode oturumda hata veren son işlemin kodu; program kapanırken bununla çıkar
+var exitCode atomic.Int32
+
+// report servislerden dönen hatayı türüne göre tek biçimde yazar ve çıkış kodunu kaydeder.
+// nil ya da dry-run/acil durdurma nedeniyle gönderilmeyen istekler hata sayılmaz.
+func report(err error) {
+	if err == nil {
+		return
+	}
+	if utils.IsInterceptedWrite(err) {
+		fmt.Println(utils.Redact(fmt.Sprintf("[DURDURULDU] %v", err)))
+		return
+	}
+
+	text, code := renderError(err)
+	fmt.Println(utils.Redact(text))
+	exitCode.Store(int32(code))
+}
+
+// renderError hatayı ekrana yazılacak metne ve çıkış koduna çevirir
+func renderError(err error) (string, int) {
+	var (
+		auth      *core.AuthError
+		limited   *core.RateLimited
+		invalid   *core.ValidationError
+		rejected  *core.RemoteRejected
+		partial   *core.PartialResult
+		lineBreak = "\n    └─ "
+	)
+
+	switch {
+	case errors.As(err, &partial):
+		text := fmt.Sprintf("[KISMİ] %s %s yarım kaldı: %d tamamlandı, %d başarısız.", partial.Platform, partial.Operation, partial.Done, partial.Failed)
+		if partial.Err != nil {
+			cause, _ := renderError(partial.Err)
+			text += lineBreak + strings.ReplaceAll(cause, "\n", "\n       ")
+		}
+		return text, exitPartial
+	case errors.As(err, &auth):
+		return fmt.Sprintf("[YETKİ] %v (config/config.json'daki bilgileri kontrol edin)", auth), exitAuth
+	case errors.As(err, &limited):
+		return fmt.Sprintf("[LİMİT] %v", limited), exitRateLimited
+	case errors.As(err, &invalid):
+		subject := "ürün verisi"
+		if invalid.Subject != "" {
+			subject = invalid.Subject + " barkodlu ürün"
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "[DOĞRULAMA] %s: %s gönderilmeye uygun değil:", invalid.Platform, subject)
+		for _, f := range invalid.Fields {
+			fmt.Fprintf(&b, "\n    - %s: %s", f.Field, f.Message)
+		}
+		return b.String(), exitValidation
+	case errors.As(err, &rejected):
+		return fmt.Sprintf("[RED] %v", rejected), exitRejected
+	case errors.Is(err, context.Canceled):
+		return "[DURDURULDU] İşlem kapanış nedeniyle yarıda kaldı.", exitInterrupted
+	default:
+		return fmt.Sprintf("[HATA] %v", err), exitFailed
+	}
+}
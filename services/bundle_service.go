@@ -0,0 +1,64 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"context"
+	"fmt"
+)
+
+// RecalculateAllBundles tüm paketlerin stok ve fiyatını bileşenlerden yeniden türetir
+func RecalculateAllBundles(ctx context.Context) {
+	barcodes, err := database.GetAllBundleBarcodes(ctx)
+	if err != nil {
+		fmt.Printf("[HATA] Paket listesi okunamadı: %v\n", err)
+		return
+	}
+
+	for _, b := range barcodes {
+		stock, price, err := database.RecalculateBundle(ctx, b)
+		if err != nil {
+			fmt.Printf("[HATA] Paket hesaplanamadı (%s): %v\n", b, err)
+			continue
+		}
+		fmt.Printf("[PAKET] %s -> Stok: %d | Fiyat: %.2f\n", b, stock, price)
+	}
+}
+
+// ApplySale bir satışı master stoğa yansıtır.
+// Paket satılırsa bileşenleri düşülür, bileşen satılırsa onu içeren paketler yeniden hesaplanır.
+func ApplySale(ctx context.Context, barcode string, qty int) {
+	if qty <= 0 {
+		return
+	}
+
+	touched := map[string]bool{}
+	if bundle, ok := database.GetBundle(ctx, barcode); ok {
+		for _, c := range bundle.Components {
+			database.DecrementStock(ctx, c.Barcode, qty*c.Quantity)
+			for _, b := range database.GetBundlesContaining(ctx, c.Barcode) {
+				touched[b] = true
+			}
+		}
+	} else {
+		database.DecrementStock(ctx, barcode, qty)
+		for _, b := range database.GetBundlesContaining(ctx, barcode) {
+			touched[b] = true
+		}
+	}
+
+	for b := range touched {
+		if _, _, err := database.RecalculateBundle(ctx, b); err != nil {
+			fmt.Printf("[HATA] Paket hesaplanamadı (%s): %v\n", b, err)
+		}
+	}
+}
+
+// RecordOrder sipariş satırını kaydeder; satır ilk kez geliyorsa stoğa yansıtır ve true döner
+func RecordOrder(ctx context.Context, o core.OrderLine) bool {
+	if !database.SaveOrderLine(ctx, o) {
+		return false
+	}
+	ApplySale(ctx, o.Barcode, o.Quantity)
+	return true
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"arbitraj-bot/database"
+	"context"
+	"fmt"
+)
+
+// Tek bir eksik/bozuk sayfa yüzünden ilanı kaldırılmış saymamak için gereken art arda eksik sync sayısı
+const delistConfirmThreshold = 3
+
+// reconcileDelisted tam sync'te gelen barkodları platformda ID'si olan ürünlerle karşılaştırır
+func reconcileDelisted(ctx context.Context, platform string, seen map[string]bool) {
+	if len(seen) == 0 {
+		fmt.Printf("[UYARI] %s listesi boş geldi, kaldırılan ilan kontrolü atlandı.\n", platform)
+		return
+	}
+
+	delisted, err := database.ReconcileListings(ctx, platform, seen, delistConfirmThreshold)
+	if err != nil {
+		fmt.Printf("[HATA] %s kaldırılan ilan kontrolü yapılamadı: %v\n", platform, err)
+		return
+	}
+
+	for _, barcode := range delisted {
+		fmt.Printf("[DELISTED] %s | %s platformda bulunamadı, gönderimler durduruldu.\n", platform, barcode)
+	}
+	if len(delisted) > 0 {
+		fmt.Printf("[UYARI] %s için %d ilan DELISTED işaretlendi.\n", platform, len(delisted))
+	}
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const hbPublishBatchSize = 100
+
+// PublishProducts master DB'de HB ilanı olmayan ürünleri HB kategori/özellik yapısına çevirir,
+// paketler halinde yükler ve trackingId'leri hb_sync_status/hb_sync_message alanlarına yazar
+func (s *HBService) PublishProducts(ctx context.Context) error {
+	products, err := database.GetProductsMissingPlatform(ctx, s.Key)
+	if err != nil {
+		return fmt.Errorf("ürünler okunamadı: %v", err)
+	}
+
+	fmt.Printf("\n[HB-YAYIN] HB'de ilanı olmayan %d ürün inceleniyor...\n", len(products))
+
+	categoryAttrs := make(map[int][]core.HBAttribute)
+	var batch []core.HBImportProduct
+	var batchBarcodes []string
+	var firstErr error
+	queued, skipped, failed := 0, 0, 0
+	flush := func() {
+		if err := s.sendImportBatch(ctx, batch, batchBarcodes); err != nil {
+			failed += len(batch)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			queued += len(batch)
+		}
+		batch, batchBarcodes = nil, nil
+	}
+
+	for _, p := range products {
+		// Kapanışta yeni paket hazırlanmaz; gönderilmeyen ürünler bir sonraki yayında tekrar seçilir
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] HB yayını kesildi; gönderilmeyen ürünler sonraki yayında tekrar seçilecek.")
+			return ctx.Err()
+		}
+
+		// Sonucu beklenen ya da kabul edilip hbSku'su henüz senkronizasyonla bağlanmamış ürün tekrar gönderilmez
+		if status := syncStatus(ctx, s.Key, p); status == "PENDING_IMPORT" || status == "SYNCED" {
+			continue
+		}
+
+		item, err := s.buildImportProduct(ctx, p, categoryAttrs)
+		if err != nil {
+			fmt.Printf("[!] %s atlandı: %v\n", p.Barcode, err)
+			database.SetSyncStatus(ctx, p.Barcode, s.Key, "PUBLISH_ERROR", err.Error())
+			skipped++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		batch = append(batch, item)
+		batchBarcodes = append(batchBarcodes, p.Barcode)
+
+		if len(batch) == hbPublishBatchSize {
+			flush()
+			pause(ctx, 2*time.Second)
+		}
+	}
+
+	if len(batch) > 0 {
+		flush()
+	}
+
+	fmt.Printf("[OK] HB yayın akışı tamamlandı. Kuyruğa alınan: %d, Atlanan: %d, Gönderilemeyen: %d\n", queued, skipped, failed)
+	if skipped+failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "PublishProducts", Done: queued, Failed: skipped + failed, Err: firstErr}
+	}
+	return nil
+}
+
+// sendImportBatch paketi yükleyip takibe alır; yalnızca paket HB'ye ulaşmadıysa hata döner
+// (dry-run, acil durdurma ve kapanışta kesilen paket hata sayılmaz)
+func (s *HBService) sendImportBatch(ctx context.Context, batch []core.HBImportProduct, barcodes []string) error {
+	fmt.Printf("[>] HB paketi gönderiliyor (%d ürün)...\n", len(batch))
+
+	trackingID, err := s.UploadProductsBulk(ctx, batch)
+	if err == nil && trackingID == "" {
+		err = &core.RemoteRejected{Platform: s.Key, Operation: "UploadProductsBulk", Message: "trackingId döndürmedi"}
+	}
+	// Kapanışta kesilen paket hata sayılmaz; ürünler sonraki yayında tekrar gönderilir
+	if utils.IsInterceptedWrite(err) || (err != nil && ctx.Err() != nil) {
+		return nil
+	}
+	if err != nil {
+		for _, b := range barcodes {
+			database.SetSyncStatus(ctx, b, s.Key, "PUBLISH_ERROR", err.Error())
+		}
+		return err
+	}
+
+	// Paket HB'ye ulaştı; takibi kapanış başlamış olsa da kaydedilmeli
+	bg := context.WithoutCancel(ctx)
+	database.SavePublishBatch(bg, s.Key, trackingID, barcodes)
+	for _, b := range barcodes {
+		database.SetSyncStatus(bg, b, s.Key, "PENDING_IMPORT", "trackingId: "+trackingID)
+	}
+	EnqueueBatchJob(bg, JobHBImport, s.Key, trackingID)
+	fmt.Printf("[OK] Paket kuyruğa alındı. trackingId: %s\n", trackingID)
+	return nil
+}
+
+// buildImportProduct master ürünü HB import formatına çevirir, zorunlu özellikleri tamamlar
+func (s *HBService) buildImportProduct(ctx context.Context, p core.Product, categoryAttrs map[int][]core.HBAttribute) (core.HBImportProduct, error) {
+	catID, err := s.resolveCategory(ctx, p.CategoryName)
+	if err != nil {
+		return core.HBImportProduct{}, err
+	}
+
+	attrs, cached := categoryAttrs[catID]
+	if !cached {
+		attrs, err = s.GetCategoryAttributes(ctx, strconv.Itoa(catID))
+		if err != nil {
+			return core.HBImportProduct{}, fmt.Errorf("kategori özellikleri alınamadı (%d): %v", catID, err)
+		}
+		categoryAttrs[catID] = attrs
+	}
+
+	markup := s.Markup(p)
+	vat := p.VatRate
+	if vat == 0 {
+		vat = 20
+	}
+
+	values := map[string]interface{}{
+		"merchantSku":    p.Barcode,
+		"Barcode":        p.Barcode,
+		"UrunAdi":        p.ProductName,
+		"UrunAciklamasi": p.Description,
+		"Marka":          p.Brand,
+		"GarantiSuresi":  0,
+		"kg":             "1",
+		"tax_vat_rate":   strconv.Itoa(vat),
+		"price":          utils.FormatHBPrice(p.Price * markup),
+		"stock":          strconv.Itoa(p.Stock),
+	}
+
+	imgIdx := 1
+	for _, img := range strings.Split(p.Images, "|") {
+		if img = strings.TrimSpace(img); img != "" && imgIdx <= 5 {
+			values[fmt.Sprintf("Image%d", imgIdx)] = img
+			imgIdx++
+		}
+	}
+
+	catKey := strconv.Itoa(catID)
+	missing := s.fillMandatoryAttributes(values, attrs, database.GetCategoryDefaults(ctx, "hb", catKey))
+	if len(missing) > 0 {
+		// Kategori ilk kez görülüyorsa zorunlu alanlar için varsayılan değerleri hafızaya alıp tekrar deniyoruz
+		s.AutoMapMandatoryAttributes(ctx, catKey, attrs)
+		missing = s.fillMandatoryAttributes(values, attrs, database.GetCategoryDefaults(ctx, "hb", catKey))
+	}
+	if len(missing) > 0 {
+		verr := &core.ValidationError{Platform: s.Key, Subject: p.Barcode}
+		for _, name := range missing {
+			verr.Fields = append(verr.Fields, core.FieldError{Field: name, Message: "zorunlu özellik eksik"})
+		}
+		return core.HBImportProduct{}, verr
+	}
+
+	return core.HBImportProduct{
+		Merchant:   s.Account.MerchantID,
+		CategoryID: catID,
+		Attributes: values,
+	}, nil
+}
+
+// fillMandatoryAttributes boş kalan zorunlu alanları varsayılanlarla doldurur, hâlâ eksik olanları döndürür
+func (s *HBService) fillMandatoryAttributes(values map[string]interface{}, attrs []core.HBAttribute, defaults map[string]core.CategoryDefault) []string {
+	var missing []string
+	for _, a := range attrs {
+		if !a.Mandatory || a.IsVariant {
+			continue
+		}
+		if v, ok := values[a.ID]; ok && fmt.Sprintf("%v", v) != "" {
+			continue
+		}
+		if d, ok := defaults[a.ID]; ok && d.ValueName != "" {
+			values[a.ID] = d.ValueName
+			continue
+		}
+		missing = append(missing, a.Name)
+	}
+	return missing
+}
+
+// resolveCategory master kategori adını HB kategori ID'sine çevirir (category_mappings.hb_id)
+func (s *HBService) resolveCategory(ctx context.Context, categoryName string) (int, error) {
+	if strings.TrimSpace(categoryName) == "" {
+		return 0, core.NewValidationError(s.Key, "Kategori", "ürünün kategorisi boş")
+	}
+
+	if m, ok := database.GetCategoryMapping(ctx, categoryName); ok && m.HbID != "" {
+		id, err := strconv.Atoi(m.HbID)
+		if err != nil {
+			return 0, fmt.Errorf("geçersiz HB kategori ID: %s", m.HbID)
+		}
+		return id, nil
+	}
+
+	matches := utils.FindTopCategoryMatches(ctx, categoryName, "hb")
+	if len(matches) > 0 && matches[0].Score >= 0.95 {
+		fmt.Printf("[LOG] HB Otomatik Eşleşti (%%%.0f): %s -> %s\n", matches[0].Score*100, categoryName, matches[0].Name)
+		database.SaveCategoryMappingID(ctx, categoryName, "hb", matches[0].ID)
+		return strconv.Atoi(matches[0].ID)
+	}
+
+	if len(matches) > 0 {
+		return 0, core.NewValidationError(s.Key, "Kategori", fmt.Sprintf("HB kategori eşleşmesi yok (en yakın: %s %%%.0f)", matches[0].Name, matches[0].Score*100))
+	}
+	return 0, core.NewValidationError(s.Key, "Kategori", "HB kategori eşleşmesi yok: "+categoryName)
+
+}
+
+// RefreshImportResults açık trackingId'lerin sonuçlarını çekip ürün bazında DB'ye yazar
+func (s *HBService) RefreshImportResults(ctx context.Context) error {
+	batches, err := database.GetOpenPublishBatches(ctx, s.Key)
+	if err != nil {
+		return err
+	}
+	if len(batches) == 0 {
+		fmt.Println("[OK] Sonucu beklenen HB paketi yok.")
+		return nil
+	}
+
+	var firstErr error
+	failed := 0
+	for _, trackingID := range batches {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		done, err := s.CheckImportJob(ctx, trackingID)
+		if err != nil {
+			fmt.Printf("[HATA] %s sorgulanamadı: %v\n", trackingID, err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !done {
+			fmt.Printf("[WAIT] %s paketinde işlenen ürünler var.\n", trackingID)
+		}
+	}
+	if failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "RefreshImportResults", Done: len(batches) - failed, Failed: failed, Err: firstErr}
+	}
+	return nil
+
+}
+
+// CheckImportJob trackingId'nin sonucunu bir kez sorgular ve kesinleşen ürünleri DB'ye yazar.
+// Paketteki tüm ürünler kesinleştiyse paketi kapatır ve done=true döner.
+func (s *HBService) CheckImportJob(ctx context.Context, trackingID string) (bool, error) {
+	if !database.IsPublishBatchOpen(ctx, s.Key, trackingID) {
+		return true, nil
+	}
+
+	results, err := s.CheckImportStatus(ctx, trackingID)
+	if err != nil {
+		return false, err
+	}
+
+	pending := 0
+	seen := make(map[string]bool)
+	for _, r := range results {
+		seen[r.MerchantSku] = true
+		status, message, final := hbImportOutcome(r)
+		if !final {
+			pending++
+			continue
+		}
+		database.SetSyncStatus(ctx, r.MerchantSku, s.Key, status, message)
+		if r.HbSku != "" {
+			database.SetPlatformID(ctx, r.MerchantSku, s.Key, r.HbSku)
+		}
+		utils.WriteToLogFile(fmt.Sprintf("[HB-SONUÇ] %s -> %s %s", r.MerchantSku, status, message))
+	}
+
+	// Yanıtta hiç görünmeyen ürün de henüz işlenmemiştir
+	for _, b := range database.GetPublishBatchBarcodes(ctx, s.Key, trackingID) {
+		if !seen[b] {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return false, nil
+	}
+	database.ClosePublishBatch(ctx, s.Key, trackingID)
+	return true, nil
+}
+
+// hbImportOutcome HB ürün durumunu bizim sync durumumuza çevirir; final=false ise sonuç henüz belli değil
+func hbImportOutcome(r core.HBImportStatus) (string, string, bool) {
+	var messages []string
+	for _, v := range r.ValidationResults {
+		messages = append(messages, fmt.Sprintf("%s: %s", v.AttributeName, v.Message))
+	}
+	message := strings.Join(messages, "; ")
+
+	status := strings.ToUpper(strings.TrimSpace(r.ProductStatus))
+	switch {
+	case len(messages) > 0 || strings.Contains(status, "REJECT") || strings.Contains(status, "MISSING"):
+		if message == "" {
+			message = r.ProductStatus
+		}
+		return "REJECTED", message, true
+	case status == "" || status == "WAITING" || status == "PENDING" || status == "PROCESSING" || status == "IN_PROGRESS":
+		return "", "", false
+	default:
+		return "SYNCED", r.ProductStatus, true
+	}
+}
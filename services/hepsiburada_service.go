@@ -3,43 +3,63 @@ package services
 import (
 	"arbitraj-bot/core"
 	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-resty/resty/v2"
 )
 
 // HBService Hepsiburada operasyonlarını yöneten ana yapı
 type HBService struct {
-	Client *resty.Client
-	Cfg    *core.Config
+	Client  *resty.Client
+	Cfg     *core.Config
+	Account core.HepsiburadaConfig // servisin çalıştığı mağaza
+	Key     string                 // hesabın DB'deki platform anahtarı (Örn: "hb", "hb:magaza2")
 }
 
-// NewHBService servisi gerekli bağımlılıklarla başlatır
-func NewHBService(client *resty.Client, cfg *core.Config) *HBService {
+// NewHBService servisi gerekli bağımlılıklarla ve verilen mağaza hesabıyla başlatır
+func NewHBService(client *resty.Client, cfg *core.Config, account core.HepsiburadaConfig) *HBService {
 	return &HBService{
-		Client: client,
-		Cfg:    cfg,
+		Client:  client,
+		Cfg:     cfg,
+		Account: account,
+		Key:     account.Key(),
 	}
 }
 
 // --- ANA FONKSİYONLAR ---
 
 // SyncProducts Hepsiburada'dan ürünleri çeker ve merkezi DB'ye kaydeder
-func (s *HBService) SyncProducts() error {
+func (s *HBService) SyncProducts(ctx context.Context) error {
 	// 1. Senin yazdığın fetchFromAPI ile 39 ürünü (stok/fiyat) çekiyoruz
-	listings, err := s.fetchFromAPI()
+	listings, err := s.fetchFromAPI(ctx)
 	if err != nil {
 		return err
 	}
 
+	sync := beginSync(ctx, s.Key)
+	seen := make(map[string]bool)
 	for _, hbProd := range listings {
+		if ctx.Err() != nil {
+			return sync.interrupt(ctx)
+		}
+		seen[hbProd.MerchantSku] = true
+
+		// Fiyat/stok değişmediyse detay isteğine de gerek yok
+		hash, changed := sync.changed(hbProd.HepsiburadaSku, hbProd)
+		if !changed {
+			continue
+		}
+
 		// 2. KRİTİK ADIM: Her ürün için isim ve resim detayını ayrıca soruyoruz
 		// Bu fonksiyonu az önce hazırladığımız V1/V2 denemeli yapı olarak düşün
-		name, imageURL := s.fetchProductDetails(hbProd.HepsiburadaSku)
+		name, imageURL := s.fetchProductDetails(ctx, hbProd.HepsiburadaSku)
 
 		// 3. Veritabanına "Dolu" veriyi gönderiyoruz
 		p := core.Product{
@@ -51,12 +71,29 @@ func (s *HBService) SyncProducts() error {
 			Images:       imageURL, // Katalogdan gelen resim
 			HbSyncStatus: "SYNCED",
 		}
-		database.SaveProduct(p)
+		saveSyncedProduct(ctx, s.Key, s.Account.Markup, p)
+		database.UpsertListing(ctx, core.Listing{
+			Platform:        s.Key,
+			ExternalID:      hbProd.HepsiburadaSku,
+			PlatformBarcode: hbProd.MerchantSku,
+			MasterBarcode:   hbProd.MerchantSku,
+			LastPrice:       hbProd.Price,
+			LastStock:       hbProd.AvailableStock,
+		})
+		database.SaveStockSnapshot(ctx, s.Key, hbProd.MerchantSku, hbProd.HepsiburadaSku, hbProd.AvailableStock, hbProd.Price)
+		sync.commit(ctx, hbProd.HepsiburadaSku, hash)
 	}
+	sync.finish(ctx)
+
+	reconcileDelisted(ctx, s.Key, seen)
+	InferSalesFromSnapshots(ctx, s.Key)
+
+	// Platformdan gelen paket stokları yerine bileşenlerden türetilen değer geçerli
+	RecalculateAllBundles(ctx)
 	return nil
 }
 
-func (s *HBService) fetchProductDetails(hbSku string) (string, string) {
+func (s *HBService) fetchProductDetails(ctx context.Context, hbSku string) (string, string) {
 	// 1. DENEME: Katalog API (V1)
 	urlV1 := fmt.Sprintf("https://catalog-external-sit.hepsiburada.com/products/%s", hbSku)
 
@@ -65,10 +102,10 @@ func (s *HBService) fetchProductDetails(hbSku string) (string, string) {
 		Images []string `json:"images"`
 	}
 
-	resp, err := s.Client.R().
+	resp, err := s.Client.R().SetContext(ctx).
 		SetHeader("accept", "application/json").
-		SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-		SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 		SetResult(&resultV1).
 		Get(urlV1)
 
@@ -92,10 +129,10 @@ func (s *HBService) fetchProductDetails(hbSku string) (string, string) {
 		} `json:"data"`
 	}
 
-	resp, err = s.Client.R().
+	resp, err = s.Client.R().SetContext(ctx).
 		SetHeader("accept", "application/json").
-		SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-		SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 		SetResult(&resultV2).
 		Get(urlV2)
 
@@ -110,13 +147,35 @@ func (s *HBService) fetchProductDetails(hbSku string) (string, string) {
 	return "", ""
 }
 
-// UpdatePriceStock Hepsiburada Fiyat/Stok güncellemesi yapar
-func (s *HBService) UpdatePriceStock(sku string, price float64, stock int) error {
+// UpdatePriceStock Hepsiburada Fiyat/Stok güncellemesi yapar; 200/202 sadece kabul demektir,
+// değerler gecikmeli geri okunarak doğrulanır. force=false iken son gönderimle aynı değerler ErrUnchanged ile atlanır.
+func (s *HBService) UpdatePriceStock(ctx context.Context, sku string, price float64, stock int, force bool) error {
+	hash := utils.PushFingerprint(price, stock, "")
+	if skipUnchanged(ctx, s.Key, sku, hash, force) {
+		return ErrUnchanged
+	}
+
+	if err := s.sendPriceStock(ctx, sku, price, stock); err != nil {
+		return err
+	}
+
+	// Gönderim yapıldı; kayıtlar kapanış başlamış olsa da tutulmalı
+	bg := context.WithoutCancel(ctx)
+	barcode := database.GetBarcodeByPlatformID(bg, s.Key, sku)
+	if barcode != "" {
+		database.RecordStockChange(bg, s.Key, barcode, stock, "PUSH")
+	}
+	database.SavePushFingerprint(bg, s.Key, sku, barcode, hash, price, stock)
+	scheduleVerification(bg, s.Cfg, s.Key, sku, barcode, price, stock)
+	return nil
+}
+
+func (s *HBService) sendPriceStock(ctx context.Context, sku string, price float64, stock int) error {
 	url := "https://listing-external-sit.hepsiburada.com/listings/bulk"
 
 	payload := []map[string]interface{}{
 		{
-			"merchantid":     s.Cfg.Hepsiburada.MerchantID,
+			"merchantid":     s.Account.MerchantID,
 			"hepsiburadasku": sku,
 			"price":          price,
 			"availableStock": stock,
@@ -125,10 +184,14 @@ func (s *HBService) UpdatePriceStock(sku string, price float64, stock int) error
 
 	fmt.Printf("[LOG] HB Fiyat/Stok Güncelleniyor: SKU: %s, Fiyat: %.2f\n", sku, price)
 
-	resp, err := s.Client.R().
+	if err := utils.GuardWrite(ctx, s.Key, "UpdatePriceStock", http.MethodPost, url, payload); err != nil {
+		return err
+	}
+
+	resp, err := s.Client.R().SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
-		SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-		SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 		SetBody(payload).
 		Post(url)
 
@@ -137,13 +200,40 @@ func (s *HBService) UpdatePriceStock(sku string, price float64, stock int) error
 	}
 
 	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusAccepted {
-		return fmt.Errorf("HB güncelleme hatası (%d): %s", resp.StatusCode(), resp.String())
+		return utils.ResponseError(s.Key, "UpdatePriceStock", resp)
 	}
-
 	return nil
 }
 
-func (s *HBService) SyncCategories() error {
+// GetListing tek bir ilanın platformdaki güncel fiyat ve stoğunu okur
+func (s *HBService) GetListing(ctx context.Context, sku string) (core.HBProduct, error) {
+	url := fmt.Sprintf("https://listing-external-sit.hepsiburada.com/listings/merchantid/%s", s.Account.MerchantID)
+	var apiResponse core.HBListingResponse
+
+	resp, err := s.Client.R().SetContext(ctx).
+		SetHeader("accept", "application/json").
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetQueryParams(map[string]string{"hepsiburadaSkuList": sku, "offset": "0", "limit": "10"}).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
+		SetResult(&apiResponse).
+		Get(url)
+
+	if err != nil {
+		return core.HBProduct{}, fmt.Errorf("HB API bağlantı hatası: %v", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return core.HBProduct{}, utils.ResponseError(s.Key, "GetListing", resp)
+	}
+
+	for _, l := range apiResponse.Listings {
+		if l.HepsiburadaSku == sku {
+			return l, nil
+		}
+	}
+	return core.HBProduct{}, fmt.Errorf("HB ilanı bulunamadı: %s", sku)
+}
+
+func (s *HBService) SyncCategories(ctx context.Context) error {
 	fmt.Println("[HB] Tüm kategoriler sayfa sayfa çekiliyor...")
 
 	page := 0
@@ -151,14 +241,18 @@ func (s *HBService) SyncCategories() error {
 	totalSaved := 0
 
 	for {
+		if ctx.Err() != nil {
+			return fmt.Errorf("kategori çekimi durduruldu (%d kategori kaydedildi): %w", totalSaved, ctx.Err())
+		}
+
 		var result struct {
 			Data []core.HBCategory `json:"data"`
 		}
 
-		resp, err := s.Client.R().
+		resp, err := s.Client.R().SetContext(ctx).
 			SetHeader("accept", "application/json").
-			SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-			SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+			SetHeader("User-Agent", s.Account.UserAgent).
+			SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 			SetQueryParams(map[string]string{
 				"leaf":      "true",
 				"status":    "ACTIVE",
@@ -175,7 +269,12 @@ func (s *HBService) SyncCategories() error {
 		}
 
 		if resp.StatusCode() != 200 {
-			break // Hata veya boş sayfa gelirse döngüden çık
+			// Yarım kalan kategori listesi tam liste gibi görünmesin
+			err := utils.ResponseError(s.Key, "SyncCategories", resp)
+			if totalSaved > 0 {
+				return &core.PartialResult{Platform: s.Key, Operation: "SyncCategories", Done: totalSaved, Err: err}
+			}
+			return err
 		}
 
 		// Eğer o sayfadan veri gelmediyse işlem bitmiştir
@@ -184,7 +283,7 @@ func (s *HBService) SyncCategories() error {
 		}
 
 		for _, cat := range result.Data {
-			database.SavePlatformCategory("hb", "0", "Root", fmt.Sprintf("%d", cat.CategoryID), cat.Name, true)
+			database.SavePlatformCategory(ctx, "hb", "0", "Root", fmt.Sprintf("%d", cat.CategoryID), cat.Name, true)
 			totalSaved++
 		}
 
@@ -204,29 +303,33 @@ func (s *HBService) SyncCategories() error {
 
 // --- YARDIMCI METODLAR ---
 
-func (s *HBService) fetchFromAPI() ([]core.HBProduct, error) {
+func (s *HBService) fetchFromAPI(ctx context.Context) ([]core.HBProduct, error) {
 	var allListings []core.HBProduct
 	offset := 0
 	limit := 100
 
 	for {
-		url := fmt.Sprintf("https://listing-external-sit.hepsiburada.com/listings/merchantid/%s", s.Cfg.Hepsiburada.MerchantID)
+		url := fmt.Sprintf("https://listing-external-sit.hepsiburada.com/listings/merchantid/%s", s.Account.MerchantID)
 		var apiResponse core.HBListingResponse
 
-		resp, err := s.Client.R().
+		resp, err := s.Client.R().SetContext(ctx).
 			SetHeader("accept", "application/json").
-			SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
+			SetHeader("User-Agent", s.Account.UserAgent).
 			SetQueryParam("offset", strconv.Itoa(offset)).
 			SetQueryParam("limit", strconv.Itoa(limit)).
-			SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+			SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 			SetResult(&apiResponse).
 			Get(url)
 
 		if err != nil {
-			return nil, fmt.Errorf("HB API bağlantı hatası: %v", err)
+			return nil, partialFetch(s.Key, len(allListings), fmt.Errorf("HB API bağlantı hatası: %w", err))
 		}
 
-		if resp.StatusCode() != 200 || len(apiResponse.Listings) == 0 {
+		if resp.StatusCode() != 200 {
+			// Eksik liste ile devam edersek gelmeyen ürünler satılmış/silinmiş gibi görünür
+			return nil, partialFetch(s.Key, len(allListings), utils.ResponseError(s.Key, "SyncProducts", resp))
+		}
+		if len(apiResponse.Listings) == 0 {
 			break
 		}
 
@@ -243,7 +346,7 @@ func (s *HBService) fetchFromAPI() ([]core.HBProduct, error) {
 	return allListings, nil
 }
 
-func (s *HBService) GetCategoryAttributes(catID string) ([]core.HBAttribute, error) {
+func (s *HBService) GetCategoryAttributes(ctx context.Context, catID string) ([]core.HBAttribute, error) {
 	url := fmt.Sprintf("https://mpop-sit.hepsiburada.com/product/api/categories/%s/attributes", catID)
 
 	var result struct {
@@ -254,10 +357,10 @@ func (s *HBService) GetCategoryAttributes(catID string) ([]core.HBAttribute, err
 		} `json:"data"`
 	}
 
-	_, err := s.Client.R().
+	_, err := s.Client.R().SetContext(ctx).
 		SetHeader("accept", "application/json").
-		SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-		SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 		SetQueryParam("version", "1").
 		SetResult(&result).
 		Get(url)
@@ -269,29 +372,91 @@ func (s *HBService) GetCategoryAttributes(catID string) ([]core.HBAttribute, err
 	var all []core.HBAttribute
 	all = append(all, result.Data.BaseAttributes...)
 	all = append(all, result.Data.Attributes...)
-	all = append(all, result.Data.VariantAttributes...)
+	for _, v := range result.Data.VariantAttributes {
+		v.IsVariant = true
+		all = append(all, v)
+	}
 
 	return all, nil
 }
 
-func (s *HBService) UploadProductsBulk(products []core.HBImportProduct) (string, error) {
+// GetAttributeValues enum tipindeki bir özelliğin HB'deki olası değerlerini döndürür
+func (s *HBService) GetAttributeValues(ctx context.Context, catID string, attrID string) ([]core.HBAttributeValue, error) {
+	url := fmt.Sprintf("https://mpop-sit.hepsiburada.com/product/api/categories/%s/attribute/%s/values", catID, attrID)
+
+	var result struct {
+		Data []core.HBAttributeValue `json:"data"`
+	}
+
+	resp, err := s.Client.R().SetContext(ctx).
+		SetHeader("accept", "application/json").
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
+		SetQueryParams(map[string]string{"version": "1", "page": "0", "size": "100"}).
+		SetResult(&result).
+		Get(url)
+
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, utils.ResponseError(s.Key, "GetAttributeValues", resp)
+	}
+	return result.Data, nil
+}
+
+// AutoMapMandatoryAttributes varsayılanı olmayan zorunlu enum özellikler için ilk değeri hafızaya alır
+func (s *HBService) AutoMapMandatoryAttributes(ctx context.Context, catID string, attrs []core.HBAttribute) {
+	defaults := database.GetCategoryDefaults(ctx, "hb", catID)
+
+	for _, a := range attrs {
+		if !a.Mandatory || a.IsVariant || !strings.EqualFold(a.Type, "enum") {
+			continue
+		}
+		if _, ok := defaults[a.ID]; ok {
+			continue
+		}
+
+		values, err := s.GetAttributeValues(ctx, catID, a.ID)
+		if err != nil || len(values) == 0 {
+			continue
+		}
+
+		// Pazarama'daki gibi ilk değeri varsayılan seçiyoruz
+		d := core.CategoryDefault{AttributeID: a.ID, AttributeName: a.Name, ValueID: values[0].ID, ValueName: values[0].Value}
+		if err := database.SaveCategoryDefault(ctx, "hb", catID, d); err == nil {
+			fmt.Printf("[OK] HB Zorunlu Alan Eşlendi: %s -> %s\n", a.Name, d.ValueName)
+		}
+	}
+}
+
+func (s *HBService) UploadProductsBulk(ctx context.Context, products []core.HBImportProduct) (string, error) {
 	url := "https://mpop-sit.hepsiburada.com/product/api/products/import"
 
+	s.applyVariants(ctx, products)
+
 	jsonData, err := json.Marshal(products)
 	if err != nil {
 		return "", fmt.Errorf("JSON hatası: %v", err)
 	}
 
-	resp, err := s.Client.R().
+	if err := utils.GuardWrite(ctx, s.Key, "UploadProductsBulk", http.MethodPost, url, jsonData); err != nil {
+		return "", err
+	}
+
+	resp, err := s.Client.R().SetContext(ctx).
 		SetHeader("accept", "application/json").
-		SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-		SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
 		SetFileReader("file", "bulk.json", bytes.NewReader(jsonData)).
 		Post(url)
 
 	if err != nil {
 		return "", err
 	}
+	if !resp.IsSuccess() {
+		return "", utils.ResponseError(s.Key, "UploadProductsBulk", resp)
+	}
 
 	var result struct {
 		Data struct {
@@ -307,19 +472,70 @@ func (s *HBService) UploadProductsBulk(products []core.HBImportProduct) (string,
 	return result.Data.TrackingId, nil
 }
 
-func (s *HBService) CheckImportStatus(trackingId string) {
+// applyVariants master DB'de varyant grubu olan ürünlere VaryantGroupID ve
+// kategorinin variantAttributes listesindeki eksen değerlerini ekler
+func (s *HBService) applyVariants(ctx context.Context, products []core.HBImportProduct) {
+	categoryAttrs := make(map[int][]core.HBAttribute)
+
+	for i := range products {
+		p := &products[i]
+		barcode, _ := p.Attributes["merchantSku"].(string)
+		if barcode == "" {
+			continue
+		}
+
+		variant, ok := database.GetVariant(ctx, barcode)
+		if !ok {
+			continue
+		}
+		p.Attributes["VaryantGroupID"] = variant.GroupCode
+
+		attrs, cached := categoryAttrs[p.CategoryID]
+		if !cached {
+			var err error
+			attrs, err = s.GetCategoryAttributes(ctx, strconv.Itoa(p.CategoryID))
+			if err != nil {
+				fmt.Printf("[UYARI] HB kategori özellikleri alınamadı (%d): %v\n", p.CategoryID, err)
+			}
+			categoryAttrs[p.CategoryID] = attrs
+		}
+
+		for _, va := range variant.Attributes {
+			for _, attr := range attrs {
+				if attr.IsVariant && strings.EqualFold(strings.TrimSpace(attr.Name), strings.TrimSpace(va.Name)) {
+					p.Attributes[attr.ID] = va.Value
+					break
+				}
+			}
+		}
+	}
+}
+
+// CheckImportStatus trackingId'ye ait ürün bazlı yükleme sonuçlarını döndürür
+func (s *HBService) CheckImportStatus(ctx context.Context, trackingId string) ([]core.HBImportStatus, error) {
 	url := fmt.Sprintf("https://mpop-sit.hepsiburada.com/product/api/products/status/%s", trackingId)
 
-	resp, err := s.Client.R().
+	var result struct {
+		Data    []core.HBImportStatus `json:"data"`
+		Success bool                  `json:"success"`
+	}
+
+	resp, err := s.Client.R().SetContext(ctx).
 		SetHeader("accept", "application/json").
-		SetHeader("User-Agent", s.Cfg.Hepsiburada.UserAgent).
-		SetBasicAuth(s.Cfg.Hepsiburada.MerchantID, s.Cfg.Hepsiburada.ApiSecret).
+		SetHeader("User-Agent", s.Account.UserAgent).
+		SetBasicAuth(s.Account.MerchantID, s.Account.ApiSecret).
+		SetResult(&result).
 		Get(url)
 
 	if err != nil {
-		fmt.Printf("[HATA] Sorgulama başarısız: %v\n", err)
-		return
+		return nil, fmt.Errorf("sorgulama başarısız: %v", err)
+	}
+
+	if !resp.IsSuccess() {
+		return nil, utils.ResponseError(s.Key, "CheckImportStatus", resp)
+
 	}
 
-	fmt.Printf("[HB] Durum Yanıtı: %s\n", resp.String())
+	fmt.Printf("[HB] %s için %d ürün sonucu alındı.\n", trackingId, len(result.Data))
+	return result.Data, nil
 }
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Özet karşılaştırmasıyla kaçabilecek farkları (elle DB düzeltmeleri, HB detayları) toparlamak için tam sync aralığı
+const fullReconcileInterval = 24 * time.Hour
+
+// incrementalSync bir senkronizasyon turunda hangi ilanların değiştiğini takip eder.
+// Tam turda her ilan işlenir; artımlı turda yalnızca ham verisi değişen ilanlar işlenir.
+type incrementalSync struct {
+	platform  string
+	full      bool
+	hashes    map[string]string
+	processed int
+	skipped   int
+}
+
+func beginSync(ctx context.Context, platform string) *incrementalSync {
+	full := database.IsFullSyncDue(ctx, platform, fullReconcileInterval)
+	if full {
+		fmt.Printf("[SYNC] %s için tam senkronizasyon yapılıyor.\n", platform)
+	} else {
+		fmt.Printf("[SYNC] %s için artımlı senkronizasyon (yalnızca değişen ilanlar).\n", platform)
+	}
+
+	return &incrementalSync{
+		platform: platform,
+		full:     full,
+		hashes:   database.GetListingHashes(ctx, platform),
+	}
+}
+
+// changed ilanın ham verisinin özetini çıkarır; ilanın işlenmesi gerekiyorsa true döner
+func (s *incrementalSync) changed(listingID string, payload interface{}) (string, bool) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", true
+	}
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	if !s.full && s.hashes[listingID] == hash {
+		s.skipped++
+		return hash, false
+	}
+	return hash, true
+}
+
+// commit ilan DB'ye işlendikten sonra özetini kaydeder
+func (s *incrementalSync) commit(ctx context.Context, listingID, hash string) {
+	s.processed++
+	if hash != "" && s.hashes[listingID] != hash {
+		database.SaveListingHash(ctx, s.platform, listingID, hash)
+	}
+}
+
+// finish senkronizasyon noktasını kaydeder
+func (s *incrementalSync) finish(ctx context.Context) {
+	database.SaveSyncCheckpoint(ctx, s.platform, s.full)
+	fmt.Printf("[SYNC] %s: %d ilan işlendi, %d değişmeyen ilan atlandı.\n", s.platform, s.processed, s.skipped)
+}
+
+// interrupt kapanışta turu yarıda bırakır; işlenen ilanların özetleri zaten kaydedildiğinden
+// sonraki tur kaldığı yerden devam eder. Tur tamamlanmadığı için senkronizasyon noktası yazılmaz.
+func (s *incrementalSync) interrupt(ctx context.Context) error {
+	fmt.Printf("[SYNC] %s: kapanış nedeniyle durduruldu (%d ilan işlendi).\n", s.platform, s.processed)
+	return ctx.Err()
+}
+
+// partialFetch sayfalı çekimde hata alındığında döner; önceki sayfalar alınmışsa hata PartialResult olarak sarılır.
+// Eksik liste işlenmez, gelmeyen ilanlar satılmış/silinmiş gibi görünürdü.
+func partialFetch(platform string, fetched int, err error) error {
+	if fetched == 0 {
+		return err
+	}
+	return &core.PartialResult{Platform: platform, Operation: "SyncProducts", Done: fetched, Err: err}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	JobPazaramaBatch = "pazarama_batch"
+	JobHBImport      = "hb_import"
+	JobPttBatch      = "ptt_batch"
+	JobVerifyPush    = "verify_push"
+)
+
+// jobPolicy: İş türüne göre ilk bekleme, geri çekilme ve zaman aşımı kuralları
+type jobPolicy struct {
+	InitialDelay time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+}
+
+var jobPolicies = map[string]jobPolicy{
+	// Pazarama paketleri genelde birkaç dakikada sonuçlanır
+	JobPazaramaBatch: {InitialDelay: 15 * time.Second, BaseBackoff: 15 * time.Second, MaxBackoff: 5 * time.Minute, Timeout: 2 * time.Hour},
+	// HB import onayı saatler sürebilir
+	JobHBImport: {InitialDelay: time.Minute, BaseBackoff: time.Minute, MaxBackoff: 30 * time.Minute, Timeout: 48 * time.Hour},
+	// PTT kuyruğa aldığı ürünleri genelde bir saat içinde işler, yoğunlukta bir güne uzayabilir
+	JobPttBatch: {InitialDelay: 2 * time.Minute, BaseBackoff: 2 * time.Minute, MaxBackoff: 30 * time.Minute, Timeout: 24 * time.Hour},
+	// Fiyat/stok geri okuması; ilk bekleme config'deki verify_delay_seconds ile belirlenir
+	JobVerifyPush: {InitialDelay: defaultVerifyDelay, BaseBackoff: 2 * time.Minute, MaxBackoff: 15 * time.Minute, Timeout: 2 * time.Hour},
+}
+
+// JobHandler işi bir kez dener; done=true ise iş tamamlanmıştır
+type JobHandler func(ctx context.Context, job core.Job) (bool, error)
+
+// JobQueue: jobs tablosundaki zamanı gelmiş işleri işleyen worker havuzu
+type JobQueue struct {
+	handlers     map[string]JobHandler
+	pollInterval time.Duration
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewJobQueue işleri kaydındaki platform anahtarına göre ilgili mağazanın servisine yönlendirir
+func NewJobQueue(m *Marketplaces) *JobQueue {
+	return &JobQueue{
+		handlers: map[string]JobHandler{
+			JobPazaramaBatch: func(ctx context.Context, j core.Job) (bool, error) {
+				pzr := m.PazaramaFor(j.Platform)
+				if pzr == nil {
+					return false, unknownAccount(j.Platform)
+				}
+				return pzr.CheckBatchJob(ctx, j.ExternalID)
+			},
+			JobHBImport: func(ctx context.Context, j core.Job) (bool, error) {
+				hb := m.HBFor(j.Platform)
+				if hb == nil {
+					return false, unknownAccount(j.Platform)
+				}
+				return hb.CheckImportJob(ctx, j.ExternalID)
+			},
+			JobPttBatch: func(ctx context.Context, j core.Job) (bool, error) {
+				ptt := m.PttFor(j.Platform)
+				if ptt == nil {
+					return false, unknownAccount(j.Platform)
+				}
+				return ptt.CheckBatchJob(ctx, j.ExternalID)
+			},
+			JobVerifyPush: func(ctx context.Context, j core.Job) (bool, error) { return verifyPush(ctx, j, m) },
+		},
+		pollInterval: 5 * time.Second,
+	}
+}
+
+// unknownAccount config'den çıkarılmış hesabın işi; iş zaman aşımına kadar bekler, hesap geri eklenirse devam eder
+func unknownAccount(key string) error {
+	return fmt.Errorf("%s hesabı config'de tanımlı değil", key)
+}
+
+// EnqueueBatchJob gönderilen paketi sonuçlanana kadar takip edilmek üzere kuyruğa ekler
+func EnqueueBatchJob(ctx context.Context, kind, platform, externalID string) {
+	policy := jobPolicies[kind]
+	database.EnqueueJob(ctx, kind, platform, externalID, policy.InitialDelay, policy.Timeout)
+}
+
+// Start önceki çalışmadan yarım kalan işleri kuyruğa geri alır ve worker'ları başlatır.
+// ctx iptal edildiğinde (ya da Stop çağrıldığında) worker'lar yeni iş almaz.
+func (q *JobQueue) Start(ctx context.Context, workers int) {
+	if n := database.ResetRunningJobs(ctx); n > 0 {
+		utils.WriteToLogFile(fmt.Sprintf("[JOB] Yarım kalan %d iş tekrar kuyruğa alındı.", n))
+	}
+
+	ctx, q.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+}
+
+// Stop worker'ları durdurur ve elindeki işi bitirmesini ya da kuyruğa bırakmasını bekler
+func (q *JobQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+func (q *JobQueue) worker(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, ok := database.ClaimDueJob(ctx)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(q.pollInterval):
+			}
+			continue
+		}
+		q.run(ctx, job)
+	}
+}
+
+func (q *JobQueue) run(ctx context.Context, job core.Job) {
+	// İşin sonucu kapanış başlamış olsa da yazılmalı
+	bg := context.WithoutCancel(ctx)
+
+	handler, ok := q.handlers[job.Kind]
+	if !ok {
+		database.FinishJob(bg, job.ID, "FAILED", "bilinmeyen iş türü: "+job.Kind)
+		return
+	}
+
+	done, err := handler(ctx, job)
+	if done {
+		database.FinishJob(bg, job.ID, "DONE", "")
+		utils.WriteToLogFile(fmt.Sprintf("[JOB] %s %s tamamlandı (%d deneme).", job.Kind, job.ExternalID, job.Attempts+1))
+		return
+	}
+
+	// Kapanış yüzünden yarıda kalan iş deneme sayılmaz; bir sonraki açılışta hemen tekrar alınır
+	if ctx.Err() != nil {
+		database.ReleaseJob(bg, job.ID)
+		return
+	}
+
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+	}
+
+	// Ürünler PENDING_IMPORT kalır; panelden kontrol edilip elle yenilenebilir
+	if database.IsJobExpired(bg, job.ID) {
+		database.FinishJob(bg, job.ID, "TIMEOUT", lastError)
+		utils.WriteToLogFile(fmt.Sprintf("[TIMEOUT] %s %s için süre doldu.", job.Kind, job.ExternalID))
+		return
+	}
+
+	database.RescheduleJob(bg, job.ID, jobPolicies[job.Kind].backoff(job.Attempts), lastError)
+}
+
+// backoff deneme sayısına göre katlanarak artan, üst sınırlı bekleme süresi
+func (p jobPolicy) backoff(attempts int) time.Duration {
+	delay := p.BaseBackoff
+	for i := 0; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Marketplaces config'deki her mağaza hesabı için bir servis tutar; menü, watcher ve iş kuyruğu
+// işi hangi hesaba ait olduğunu platform anahtarından (Örn: "pazarama:magaza2") bulur.
+type Marketplaces struct {
+	HB       []*HBService
+	Pazarama []*PazaramaService
+	Ptt      []*PttService
+}
+
+func NewMarketplaces(client *resty.Client, cfg *core.Config) *Marketplaces {
+	m := &Marketplaces{}
+	for _, a := range cfg.Hepsiburada {
+		m.HB = append(m.HB, NewHBService(client, cfg, a))
+	}
+	for _, a := range cfg.Pazarama {
+		m.Pazarama = append(m.Pazarama, NewPazaramaService(client, cfg, a))
+	}
+	for _, a := range cfg.Ptt {
+		m.Ptt = append(m.Ptt, NewPttService(client, cfg, a))
+	}
+	return m
+}
+
+// HBFor hesap anahtarına ait HB servisini döndürür
+func (m *Marketplaces) HBFor(key string) *HBService {
+	for _, s := range m.HB {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// PazaramaFor hesap anahtarına ait Pazarama servisini döndürür
+func (m *Marketplaces) PazaramaFor(key string) *PazaramaService {
+	for _, s := range m.Pazarama {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// PttFor hesap anahtarına ait PTT servisini döndürür
+func (m *Marketplaces) PttFor(key string) *PttService {
+	for _, s := range m.Ptt {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// Keys tüm hesap anahtarları (HB, Pazarama, PTT sırasıyla)
+func (m *Marketplaces) Keys() []string {
+	var keys []string
+	for _, s := range m.HB {
+		keys = append(keys, s.Key)
+	}
+	for _, s := range m.Pazarama {
+		keys = append(keys, s.Key)
+	}
+	for _, s := range m.Ptt {
+		keys = append(keys, s.Key)
+	}
+	return keys
+}
+
+// Markup ürünün HB marjı ile mağazanın marjının çarpımı
+func (s *HBService) Markup(p core.Product) float64 {
+	return effectiveMarkup(p.HbMarkup, s.Account.Markup)
+}
+
+// Markup ürünün Pazarama marjı ile mağazanın marjının çarpımı
+func (s *PazaramaService) Markup(p core.Product) float64 {
+	return effectiveMarkup(p.PazaramaMarkup, s.Account.Markup)
+}
+
+// Markup ürünün PTT marjı ile mağazanın marjının çarpımı
+func (s *PttService) Markup(p core.Product) float64 {
+	return effectiveMarkup(p.PttMarkup, s.Account.Markup)
+}
+
+// pause paketler arası bekler; kapanış başlarsa beklemeyi keser (döngü başındaki ctx kontrolü çıkışı yapar)
+func pause(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+func effectiveMarkup(productMarkup, accountMarkup float64) float64 {
+	if productMarkup <= 0 {
+		productMarkup = 1.0
+	}
+	if accountMarkup <= 0 {
+		accountMarkup = 1.0
+	}
+	return productMarkup * accountMarkup
+}
+
+// syncStatus ürünün hesaptaki son durumu; varsayılan hesap products'taki sütunu, ek mağazalar account_sync_status'u okur
+func syncStatus(ctx context.Context, key string, p core.Product) string {
+	if !core.IsDefaultAccount(key) {
+		return database.GetAccountSyncStatus(ctx, key, p.Barcode)
+	}
+	switch core.PlatformOf(key) {
+	case "hb":
+		return p.HbSyncStatus
+	case "pazarama":
+		return p.PazaramaSyncStatus
+	case "ptt":
+		return p.PttSyncStatus
+	}
+	return ""
+}
+
+// saveSyncedProduct senkronizasyonda gelen ürünü master kayda işler. Fiyat mağaza marjından arındırılır
+// (ürün marjını mergeProduct düşer). Ek mağazalar master veriyi ezmez; ürün master'da yoksa platform ID'leri
+// olmadan açılır, ilan listings'te tutulur.
+func saveSyncedProduct(ctx context.Context, key string, accountMarkup float64, p core.Product) {
+	p.Price = math.Round(p.Price/effectiveMarkup(0, accountMarkup)*100) / 100
+	if core.IsDefaultAccount(key) {
+		database.SaveProduct(ctx, p, key)
+		return
+	}
+	if database.ProductExists(ctx, p.Barcode) {
+		return
+	}
+	p.HbSku, p.HbSyncStatus = "", ""
+	p.PazaramaId, p.PttId = "", ""
+	database.SaveProduct(ctx, p, core.PlatformOf(key))
+}
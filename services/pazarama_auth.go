@@ -0,0 +1,92 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const pazaramaTokenURL = "https://isortagimgiris.pazarama.com/connect/token"
+
+// PazaramaAuth client_credentials token'ını bitiş zamanıyla önbellekte tutar ve süresi dolmadan yeniler.
+// Refresh token yoktur; yenileme aynı bilgilerle yeni token istemektir.
+type PazaramaAuth struct {
+	Client  *resty.Client
+	Account core.PazaramaConfig
+
+	mu    sync.Mutex
+	token core.AuthToken
+}
+
+func NewPazaramaAuth(client *resty.Client, account core.PazaramaConfig) *PazaramaAuth {
+	return &PazaramaAuth{Client: client, Account: account}
+}
+
+// Token geçerli access token'ı döndürür; yoksa ya da bitmek üzereyse yenisini alır
+func (a *PazaramaAuth) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token.AccessToken != "" && time.Until(a.token.ExpiresAt) > tokenSkew {
+		return a.token.AccessToken, nil
+	}
+
+	// Token isteği yan etkisiz sayılır; dry-run ve acil durdurmada da okuma yapılabilmeli
+	var authRes core.PazaramaAuthResponse
+	resp, err := utils.Retryable(a.Client.R().SetContext(ctx)).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetBasicAuth(a.Account.ClientID, a.Account.ClientSecret).
+		SetFormData(map[string]string{"grant_type": "client_credentials"}).
+		SetResult(&authRes).
+		Post(pazaramaTokenURL)
+
+	if err != nil {
+		return "", fmt.Errorf("Pazarama token alınamadı: %w", err)
+	}
+	if !resp.IsSuccess() || authRes.Data.AccessToken == "" {
+		return "", &core.AuthError{Platform: a.Account.Key(), Message: fmt.Sprintf("token alınamadı (%s)", resp.Status())}
+	}
+
+	a.token = core.AuthToken{
+		AccessToken: authRes.Data.AccessToken,
+		ExpiresAt:   tokenExpiry(authRes.Data.AccessToken, authRes.Data.ExpiresIn),
+	}
+	utils.RegisterSecret(a.token.AccessToken)
+	utils.WriteToLogFile(fmt.Sprintf("[LOG] %s token'ı alındı (geçerlilik: %s).", a.Account.Key(), a.token.ExpiresAt.Format("15:04")))
+
+	return a.token.AccessToken, nil
+}
+
+// Invalidate 401 alınan token'ı geçersiz sayar; aynı anda başka bir istek token'ı yenilediyse dokunmaz
+func (a *PazaramaAuth) Invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token.AccessToken == token {
+		a.token = core.AuthToken{}
+	}
+}
+
+// authorized isteği geçerli token ile gönderir; 401 alınırsa token yenilenip istek bir kez tekrarlanır.
+// send her denemede yeni bir istek alır, gövde ve parametreleri kendisi eklemelidir.
+func (s *PazaramaService) authorized(ctx context.Context, send func(req *resty.Request) (*resty.Response, error)) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := s.Auth.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := send(s.Client.R().SetContext(ctx).SetAuthToken(token))
+		if err != nil || resp.StatusCode() != http.StatusUnauthorized || attempt > 0 {
+			return resp, err
+		}
+
+		fmt.Println("[!] Pazarama token'ı reddedildi, yenileniyor...")
+		s.Auth.Invalidate(token)
+	}
+}
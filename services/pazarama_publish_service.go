@@ -0,0 +1,211 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const pazaramaPublishBatchSize = 100
+
+// PublishProducts pazarama_id'si olmayan master ürünleri Excel'e ihtiyaç duymadan Pazarama'ya yükler.
+// Marka ve kategori mevcut önbelleklerden (platform_brands, category_mappings) çözülür,
+// varsayılan özellikler platform_category_defaults'tan eklenir.
+func (s *PazaramaService) PublishProducts(ctx context.Context) error {
+	products, err := database.GetProductsMissingPlatform(ctx, s.Key)
+	if err != nil {
+		return fmt.Errorf("ürünler okunamadı: %v", err)
+	}
+
+	// Sonucu belirsiz kalan ürünler panelden kontrol edilene kadar tekrar gönderilmez (red listesinden denenir)
+	var candidates []core.Product
+	for _, p := range products {
+		if syncStatus(ctx, s.Key, p) != "UNVERIFIED" {
+			candidates = append(candidates, p)
+		}
+	}
+
+	fmt.Printf("\n[PZR-YAYIN] Pazarama'da ilanı olmayan %d ürün inceleniyor...\n", len(candidates))
+	return s.publish(ctx, candidates)
+}
+
+// RetryRejected reddedilmiş ürünleri master DB'deki güncel verileriyle tekrar gönderir
+func (s *PazaramaService) RetryRejected(ctx context.Context, barcodes []string) error {
+	products, err := database.GetProductsByBarcodes(ctx, barcodes)
+	if err != nil {
+		return fmt.Errorf("ürünler okunamadı: %v", err)
+	}
+
+	fmt.Printf("\n[PZR-YAYIN] %d reddedilmiş ürün tekrar deneniyor...\n", len(products))
+	return s.publish(ctx, products)
+}
+
+func (s *PazaramaService) publish(ctx context.Context, products []core.Product) error {
+	// Kimlik bilgileri hatalıysa ürün ürün denemeden hemen dön
+	if _, err := s.Auth.Token(ctx); err != nil {
+		return err
+	}
+
+	checkedCategories := make(map[string]bool)
+	var batch []core.PazaramaProductItem
+	var firstErr error
+	queued, skipped, failed := 0, 0, 0
+	flush := func() {
+		if err := s.sendPublishBatch(ctx, batch); err != nil {
+			failed += len(batch)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			queued += len(batch)
+		}
+		batch = nil
+	}
+
+	for _, p := range products {
+		// Kapanışta yeni paket hazırlanmaz; gönderilmeyen ürünler bir sonraki yayında tekrar seçilir
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] Pazarama yayını kesildi; gönderilmeyen ürünler sonraki yayında tekrar seçilecek.")
+			return ctx.Err()
+		}
+
+		if syncStatus(ctx, s.Key, p) == "PENDING_IMPORT" {
+			continue
+		}
+
+		item, err := s.buildProductItem(ctx, p, checkedCategories)
+		if err != nil {
+			fmt.Printf("[!] %s atlandı: %v\n", p.Barcode, err)
+			database.SetSyncStatus(ctx, p.Barcode, s.Key, "PUBLISH_ERROR", err.Error())
+			skipped++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		batch = append(batch, item)
+
+		if len(batch) == pazaramaPublishBatchSize {
+			flush()
+			pause(ctx, 2*time.Second)
+		}
+	}
+
+	if len(batch) > 0 {
+		flush()
+	}
+
+	fmt.Printf("[OK] Pazarama yayın akışı tamamlandı. Kuyruğa alınan: %d, Atlanan: %d, Gönderilemeyen: %d\n", queued, skipped, failed)
+	if skipped+failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "PublishProducts", Done: queued, Failed: skipped + failed, Err: firstErr}
+	}
+	return nil
+}
+
+// sendPublishBatch paketi gönderip takibe alır; yalnızca paket Pazarama'ya ulaşmadıysa hata döner
+func (s *PazaramaService) sendPublishBatch(ctx context.Context, batch []core.PazaramaProductItem) error {
+	fmt.Printf("[>] Pazarama paketi gönderiliyor (%d ürün)...\n", len(batch))
+
+	batchID, err := s.SendBatchToPazarama(ctx, batch)
+	// Kapanışta kesilen paket hata sayılmaz; ürünler sonraki yayında tekrar gönderilir
+	if utils.IsInterceptedWrite(err) || (err != nil && ctx.Err() != nil) {
+		return nil
+	}
+	if err != nil {
+		utils.WriteToLogFile(fmt.Sprintf("[HATA] Paket gönderilemedi: %v", err))
+		for _, item := range batch {
+			database.SetSyncStatus(ctx, item.Code, s.Key, "PUBLISH_ERROR", err.Error())
+		}
+		return err
+	}
+
+	utils.WriteToLogFile(fmt.Sprintf("[OK] Paket kuyruğa alındı: %s", batchID))
+	// Paket Pazarama'ya ulaştı; takibi kapanış başlamış olsa da kaydedilmeli
+	s.trackBatch(context.WithoutCancel(ctx), batchID, batch)
+	return nil
+}
+
+func (s *PazaramaService) buildProductItem(ctx context.Context, p core.Product, checkedCategories map[string]bool) (core.PazaramaProductItem, error) {
+	categoryID, err := s.resolveCategory(ctx, p.CategoryName)
+	if err != nil {
+		return core.PazaramaProductItem{}, err
+	}
+
+	brandID, err := s.GetBrandIDByName(ctx, p.Brand)
+	if err != nil {
+		return core.PazaramaProductItem{}, fmt.Errorf("marka çözülemedi (%s): %w", p.Brand, err)
+	}
+
+	defaultAttrs := s.GetDefaultAttributesFromDB(ctx, categoryID)
+	if len(defaultAttrs) == 0 && !checkedCategories[categoryID] {
+		fmt.Printf("\n[LOG] %s kategorisi analiz ediliyor...\n", categoryID)
+		s.AutoMapMandatoryAttributes(ctx, categoryID)
+		checkedCategories[categoryID] = true
+		defaultAttrs = s.GetDefaultAttributesFromDB(ctx, categoryID)
+	}
+
+	var images []core.PazaramaImage
+	for _, img := range strings.Split(p.Images, "|") {
+		if img = strings.TrimSpace(img); img != "" {
+			images = append(images, core.PazaramaImage{Imageurl: img})
+		}
+	}
+	if len(images) == 0 {
+		return core.PazaramaProductItem{}, &core.ValidationError{Platform: s.Key, Subject: p.Barcode, Fields: []core.FieldError{{Field: "Görseller", Message: "görsel yok"}}}
+	}
+
+	price := p.Price * s.Markup(p)
+
+	vat := p.VatRate
+	if vat == 0 {
+		vat = 20
+	}
+
+	item := core.PazaramaProductItem{
+		Code:         p.Barcode,
+		Name:         p.ProductName,
+		DisplayName:  p.ProductName,
+		Description:  p.Description,
+		BrandId:      brandID,
+		Desi:         1,
+		StockCount:   p.Stock,
+		StockCode:    p.Barcode,
+		CurrencyType: "TRY",
+		ListPrice:    price,
+		SalePrice:    price,
+		VatRate:      vat,
+		CategoryId:   categoryID,
+		Images:       images,
+		Attributes:   defaultAttrs,
+	}
+	s.applyVariant(ctx, &item)
+	return item, nil
+}
+
+// resolveCategory master kategori adını Pazarama kategori ID'sine çevirir (category_mappings.pazarama_id)
+func (s *PazaramaService) resolveCategory(ctx context.Context, categoryName string) (string, error) {
+	if strings.TrimSpace(categoryName) == "" {
+		return "", core.NewValidationError(s.Key, "Kategori", "ürünün kategorisi boş")
+	}
+
+	if m, ok := database.GetCategoryMapping(ctx, categoryName); ok && m.PazaramaID != "" {
+		return m.PazaramaID, nil
+	}
+
+	matches := utils.FindTopCategoryMatches(ctx, categoryName, "pazarama")
+	if len(matches) > 0 && matches[0].Score >= 0.95 {
+		fmt.Printf("[LOG] Otomatik Eşleşti (%%%.0f): %s -> %s\n", matches[0].Score*100, categoryName, matches[0].Name)
+		database.SaveCategoryMappingID(ctx, categoryName, "pazarama", matches[0].ID)
+		return matches[0].ID, nil
+	}
+
+	if len(matches) > 0 {
+		return "", core.NewValidationError(s.Key, "Kategori", fmt.Sprintf("Pazarama kategori eşleşmesi yok (en yakın: %s %%%.0f)", matches[0].Name, matches[0].Score*100))
+	}
+	return "", core.NewValidationError(s.Key, "Kategori", "Pazarama kategori eşleşmesi yok: "+categoryName)
+
+}
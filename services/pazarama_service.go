@@ -4,9 +4,11 @@ import (
 	"arbitraj-bot/core"
 	"arbitraj-bot/database"
 	"arbitraj-bot/utils"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -16,43 +18,68 @@ import (
 
 // PazaramaService Pazarama operasyonlarını yöneten ana yapı
 type PazaramaService struct {
-	Client *resty.Client
-	Cfg    *core.Config
+	Client  *resty.Client
+	Cfg     *core.Config
+	Account core.PazaramaConfig // servisin çalıştığı mağaza
+	Key     string              // hesabın DB'deki platform anahtarı (Örn: "pazarama", "pazarama:magaza2")
+	Auth    *PazaramaAuth
+
+	// Kategori özellik tanımları (varyant eşleştirmesi için), kategori ID -> özellikler
+	categoryAttrCache map[string][]pazaramaCategoryAttribute
 }
 
-// NewPazaramaService servisi gerekli bağımlılıklarla başlatır
-func NewPazaramaService(client *resty.Client, cfg *core.Config) *PazaramaService {
+type pazaramaCategoryAttribute struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	IsRequired      bool   `json:"isRequired"`
+	AttributeValues []struct {
+		ID    string `json:"id"`
+		Value string `json:"value"`
+	} `json:"attributeValues"`
+}
+
+// NewPazaramaService servisi gerekli bağımlılıklarla ve verilen mağaza hesabıyla başlatır
+func NewPazaramaService(client *resty.Client, cfg *core.Config, account core.PazaramaConfig) *PazaramaService {
 	return &PazaramaService{
-		Client: client,
-		Cfg:    cfg,
+		Client:  client,
+		Cfg:     cfg,
+		Account: account,
+		Key:     account.Key(),
+		Auth:    NewPazaramaAuth(client, account),
+
+		categoryAttrCache: make(map[string][]pazaramaCategoryAttribute),
 	}
 }
 
-func (s *PazaramaService) SyncCategories(token string) error {
+func (s *PazaramaService) SyncCategories(ctx context.Context) error {
 	var result core.PazaramaCategoryResponse
 	log.Println("[LOG] Pazarama kategori ağacı çekiliyor...")
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetResult(&result).
-		Get("https://isortagimapi.pazarama.com/category/getCategoryTree")
-	if err != nil || !resp.IsSuccess() || !result.Success {
-		return fmt.Errorf("Kategori çekilemedi")
-	}
-	s.saveCategoryRecursive(result.Data, "0", "ROOT")
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetResult(&result).
+			Get("https://isortagimapi.pazarama.com/category/getCategoryTree")
+	})
+	if err != nil {
+		return fmt.Errorf("Kategori çekilemedi: %w", err)
+	}
+	if !resp.IsSuccess() || !result.Success {
+		return utils.ResponseError(s.Key, "SyncCategories", resp)
+	}
+	s.saveCategoryRecursive(ctx, result.Data, "0", "ROOT")
 	log.Printf("[LOG] Pazarama'dan toplam %d kategori çekildi.", len(result.Data))
 	return nil
 }
 
-func (s *PazaramaService) saveCategoryRecursive(categories []core.PazaramaCategory, parentID string, parentName string) {
+func (s *PazaramaService) saveCategoryRecursive(ctx context.Context, categories []core.PazaramaCategory, parentID string, parentName string) {
 	for _, cat := range categories {
-		database.SavePlatformCategory("pazarama", parentID, parentName, cat.ID, cat.Name, cat.IsLeaf)
+		database.SavePlatformCategory(ctx, "pazarama", parentID, parentName, cat.ID, cat.Name, cat.IsLeaf)
 		if len(cat.Children) > 0 {
-			s.saveCategoryRecursive(cat.Children, cat.ID, cat.Name)
+			s.saveCategoryRecursive(ctx, cat.Children, cat.ID, cat.Name)
 		}
 	}
 }
 
-func (s *PazaramaService) CreateProductPazarama(token string, product core.PazaramaProductItem) (string, error) {
+func (s *PazaramaService) CreateProductPazarama(ctx context.Context, product core.PazaramaProductItem) (string, error) {
 	request := core.PazaramaCreateProductRequest{
 		Products: []core.PazaramaProductItem{product},
 	}
@@ -65,42 +92,47 @@ func (s *PazaramaService) CreateProductPazarama(token string, product core.Pazar
 		Success bool `json:"success"`
 	}
 
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetBody(request).
-		SetResult(&apiResult). // Burası sonucu apiResult'a doldurur
-		Post("https://isortagimapi.pazarama.com/product/create")
-
-	if !apiResult.Success || resp.StatusCode() != 200 {
-		fmt.Printf("[DEBUG] Gönderilen Ham JSON: %+v\n", request)
-		fmt.Printf("[DEBUG] Pazarama Hata Yanıtı: %s\n", resp.String())
+	if err := utils.GuardWrite(ctx, s.Key, "CreateProduct", http.MethodPost, "https://isortagimapi.pazarama.com/product/create", request); err != nil {
+		return "", err
 	}
 
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetBody(request).
+			SetResult(&apiResult). // Burası sonucu apiResult'a doldurur
+			Post("https://isortagimapi.pazarama.com/product/create")
+	})
+
 	if err != nil {
 		return "", err
 	}
 
+	if !apiResult.Success || resp.StatusCode() != 200 {
+		fmt.Println(utils.Redact(fmt.Sprintf("[DEBUG] Gönderilen Ham JSON: %+v", request)))
+		fmt.Println(utils.Redact("[DEBUG] Pazarama Hata Yanıtı: " + resp.String()))
+	}
+
 	// Log kuralımız: Detayları bas
-	fmt.Printf("[LOG] HTTP %d | Yanıt: %s\n", resp.StatusCode(), resp.String())
+	fmt.Println(utils.Redact(fmt.Sprintf("[LOG] HTTP %d | Yanıt: %s", resp.StatusCode(), resp.String())))
 
 	if !apiResult.Success {
-		return "", fmt.Errorf("Pazarama hatası: %s", resp.String())
+		return "", utils.ResponseError(s.Key, "CreateProduct", resp)
 	}
 
 	return apiResult.Data.BatchRequestId, nil
 }
 
-func (s *PazaramaService) GetBrandIDByName(token string, brandName string) (string, error) {
+func (s *PazaramaService) GetBrandIDByName(ctx context.Context, brandName string) (string, error) {
 	// 1. Temizlik ve Normalizasyon (Tüm sorguları büyük harf üzerinden yapacağız)
 	brandName = strings.TrimSpace(brandName)
 	normalizedName := strings.ToUpper(brandName)
 
 	// 2. Önce lokal DB'ye sor (UPPER fonksiyonu ile case-insensitive kontrol)
 	var brandID string
-	err := database.DB.QueryRow("SELECT brand_id FROM platform_brands WHERE platform = 'pazarama' AND UPPER(brand_name) = ?", normalizedName).Scan(&brandID)
+	err := database.DB.QueryRowContext(ctx, "SELECT brand_id FROM platform_brands WHERE platform = 'pazarama' AND UPPER(brand_name) = ?", normalizedName).Scan(&brandID)
 	if err == nil {
 		if brandID == "NOT_FOUND" {
-			return "", fmt.Errorf("MARKA PAZARAMADA YOK (KARA LISTE)")
+			return "", core.NewValidationError(s.Key, "Marka", "marka Pazarama'da yok (kara liste)")
 		}
 		return brandID, nil
 	}
@@ -108,28 +140,30 @@ func (s *PazaramaService) GetBrandIDByName(token string, brandName string) (stri
 	fmt.Printf("[LOG] Marka API'den aranıyor: '%s'\n", brandName)
 
 	var result core.PazaramaBrandResponse
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetQueryParam("Page", "1").
-		SetQueryParam("Size", "50").
-		SetQueryParam("name", brandName).
-		SetResult(&result).
-		Get("https://isortagimapi.pazarama.com/brand/getBrands")
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetQueryParam("Page", "1").
+			SetQueryParam("Size", "50").
+			SetQueryParam("name", brandName).
+			SetResult(&result).
+			Get("https://isortagimapi.pazarama.com/brand/getBrands")
+	})
 
-	if resp.StatusCode() != 200 {
-		fmt.Printf("[DEBUG] Pazarama Hata Yanıtı: %s\n", resp.String())
+	if err != nil {
+		return "", fmt.Errorf("Bağlantı hatası: %w", err)
 	}
 
-	if err != nil {
-		return "", fmt.Errorf("Bağlantı hatası: %v", err)
+	// Hatalı yanıt boş sonuç gibi değerlendirilirse marka yanlışlıkla kara listeye girer
+	if resp.StatusCode() != 200 {
+		return "", utils.ResponseError(s.Key, "GetBrands", resp)
 	}
 
 	// 3. API'de hiç sonuç yoksa kara listeye al
 	if len(result.Data) == 0 {
 		fmt.Printf("[UYARI] Pazarama '%s' ismiyle sonuç döndürmedi. Kara listeye alınıyor.\n", brandName)
-		database.DB.Exec("INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', 'NOT_FOUND', ?)", normalizedName)
+		database.DB.ExecContext(ctx, "INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', 'NOT_FOUND', ?)", normalizedName)
 		utils.WriteToLogFile(fmt.Sprintf("[BRAND_ERROR] %s markası bulunamadı, kara listeye alındı.", brandName))
-		return "", fmt.Errorf("Marka bulunamadı")
+		return "", core.NewValidationError(s.Key, "Marka", "marka Pazarama'da bulunamadı")
 	}
 
 	// 4. Eşleştirme denemesi
@@ -137,47 +171,62 @@ func (s *PazaramaService) GetBrandIDByName(token string, brandName string) (stri
 		if strings.EqualFold(strings.TrimSpace(b.Name), brandName) {
 			fmt.Printf("[OK] Tam eşleşme sağlandı: %s\n", b.Name)
 			// DB'ye her zaman BÜYÜK HARF kaydedelim ki bir sonraki SELECT yakalasın
-			database.DB.Exec("INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', ?, ?)", b.ID, strings.ToUpper(b.Name))
+			database.DB.ExecContext(ctx, "INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', ?, ?)", b.ID, strings.ToUpper(b.Name))
 			return b.ID, nil
 		}
 	}
 
 	// 5. Sonuç döndü ama tam isim uymuyorsa yine kara listeye alalım
-	database.DB.Exec("INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', 'NOT_FOUND', ?)", normalizedName)
-	return "", fmt.Errorf("Tam eşleşme sağlanamadı")
+	database.DB.ExecContext(ctx, "INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', 'NOT_FOUND', ?)", normalizedName)
+	return "", core.NewValidationError(s.Key, "Marka", "Pazarama'da tam eşleşen marka yok")
 }
 
-func (s *PazaramaService) SyncPazaramaBrands(token string) error {
+func (s *PazaramaService) SyncPazaramaBrands(ctx context.Context) error {
 	fmt.Println("\n[LOG] --- PAZARAMA MARKA SENKRONİZASYONU BAŞLADI ---")
 	page := 1
 	pageSize := 100
 	totalSaved := 0
 
 	for {
+		if ctx.Err() != nil {
+			fmt.Printf("[DURDURULDU] Marka senkronizasyonu %d. sayfada kesildi (%d marka kaydedildi).\n", page, totalSaved)
+			return ctx.Err()
+		}
+
 		fmt.Printf("[LOG] Sayfa %d çekiliyor...\n", page)
 		var result core.PazaramaBrandResponse
-		resp, err := s.Client.R().
-			SetAuthToken(token).
-			SetQueryParam("Page", strconv.Itoa(page)).
-			SetQueryParam("Size", strconv.Itoa(pageSize)).
-			SetResult(&result).
-			Get("https://isortagimapi.pazarama.com/brand/getBrands")
+		resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+			return req.
+				SetQueryParam("Page", strconv.Itoa(page)).
+				SetQueryParam("Size", strconv.Itoa(pageSize)).
+				SetResult(&result).
+				Get("https://isortagimapi.pazarama.com/brand/getBrands")
+		})
 
 		if err != nil {
-			return fmt.Errorf("API bağlantı hatası: %v", err)
+			return fmt.Errorf("API bağlantı hatası: %w", err)
 		}
 
 		if !resp.IsSuccess() {
-			return fmt.Errorf("API hata döndürdü: %d - %s", resp.StatusCode(), resp.String())
+			err := utils.ResponseError(s.Key, "SyncBrands", resp)
+			if totalSaved > 0 {
+				return &core.PartialResult{Platform: s.Key, Operation: "SyncBrands", Done: totalSaved, Err: err}
+			}
+			return err
 		}
 
 		if len(result.Data) == 0 {
 			break
 		}
 
-		tx, _ := database.DB.Begin()
+		// Çekilen sayfa kapanış başlamış olsa da bütün olarak yazılır
+		bg := context.WithoutCancel(ctx)
+		tx, err := database.DB.BeginTx(bg, nil)
+		if err != nil {
+			return fmt.Errorf("marka sayfası yazılamadı: %v", err)
+		}
 		for _, b := range result.Data {
-			_, err := tx.Exec("INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', ?, ?)", b.ID, b.Name)
+			_, err := tx.ExecContext(bg, "INSERT OR REPLACE INTO platform_brands (platform, brand_id, brand_name) VALUES ('pazarama', ?, ?)", b.ID, b.Name)
 			if err != nil {
 				fmt.Printf("[!] Marka kaydedilemedi (%s): %v\n", b.Name, err)
 			}
@@ -200,96 +249,197 @@ func (s *PazaramaService) SyncPazaramaBrands(token string) error {
 	return nil
 }
 
-func (s *PazaramaService) CheckPazaramaBatchStatus(token string, batchID string) {
+// CheckPazaramaBatchStatus paketin ham yanıtını loglar; sonuçları işlemek için RefreshBatchResults kullanılır
+func (s *PazaramaService) CheckPazaramaBatchStatus(ctx context.Context, batchID string) error {
 	fmt.Printf("\n[LOG] --- BATCH SORGULANIYOR: %s ---\n", batchID)
 
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetQueryParam("BatchRequestId", batchID).
-		Get("https://isortagimapi.pazarama.com/product/getProductBatchResult")
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetQueryParam("BatchRequestId", batchID).
+			Get("https://isortagimapi.pazarama.com/product/getProductBatchResult")
+	})
 
 	if err != nil {
-		fmt.Printf("[HATA] Sorgulama yapılamadı: %v\n", err)
-		return
+		return fmt.Errorf("Bağlantı hatası: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return utils.ResponseError(s.Key, "CheckPazaramaBatchStatus", resp)
 	}
 
 	fmt.Printf("[LOG] HTTP: %d | Yanıt: %s\n", resp.StatusCode(), resp.String())
+	return nil
 }
 
-func (s *PazaramaService) WatchBatchStatus(token string, batchID string, items []core.PazaramaProductItem) {
-	startTime := time.Now()
+// CheckBatchJob iş kuyruğundan çağrılır: paketi bir kez sorgular, tamamlandıysa sonucu DB'ye işler.
+// done=false dönerse iş geri çekilme süresi sonunda tekrar denenir.
+func (s *PazaramaService) CheckBatchJob(ctx context.Context, batchID string) (bool, error) {
+	// Paket elle (RefreshBatchResults) işlenmiş olabilir
+	if !database.IsPublishBatchOpen(ctx, s.Key, batchID) {
+		return true, nil
+	}
 
-	// Paket özeti hazırlayalım
-	var displayInfo string
-	if len(items) == 1 {
-		displayInfo = fmt.Sprintf("%s (%s)", items[0].Name, items[0].Code)
-	} else {
-		displayInfo = fmt.Sprintf("%d ürünlük paket (Örn: %s)", len(items), items[0].Name)
+	result, err := s.FetchBatchResult(ctx, batchID)
+	if err != nil {
+		return false, err
+	}
+	if result.Data.Status != 2 {
+		return false, nil
 	}
 
-	for {
-		if time.Since(startTime) > 15*time.Minute {
-			utils.WriteToLogFile(fmt.Sprintf("[TIMEOUT] %s için süre doldu.", displayInfo))
-			return
-		}
-
-		var result struct {
-			Data struct {
-				Status      int `json:"status"`
-				FailedCount int `json:"failedCount"`
-				BatchResult []struct {
-					Reason string `json:"reason"`
-					Code   string `json:"code"`
-				} `json:"batchResult"`
-			} `json:"data"`
-			Success bool `json:"success"`
-		}
-
-		s.Client.R().SetAuthToken(token).SetQueryParam("BatchRequestId", batchID).SetResult(&result).Get("https://isortagimapi.pazarama.com/product/getProductBatchResult")
-
-		if result.Success {
-			if result.Data.Status == 1 {
-				// BATCH ID YERİNE ÜRÜN ADI BASILIYOR
-				fmt.Printf("\r[WAIT] %s işleniyor...", displayInfo)
-			} else if result.Data.Status == 2 {
-				if result.Data.FailedCount > 0 {
-					fmt.Printf("\n[HATA] %s paketinde %d ürün reddedildi!\n", displayInfo, result.Data.FailedCount)
-					// Hata detayları log dosyasına barkodlu şekilde yazılmaya devam eder
-					for i, res := range result.Data.BatchResult {
-						if res.Reason != "" && i < len(items) {
-							utils.WriteToLogFile(fmt.Sprintf("[RED] %s (%s) -> %s", items[i].Code, items[i].Name, res.Reason))
-						}
-					}
-				} else {
-					fmt.Printf("\n[OK] %s başarıyla yüklendi ve yayına alındı.\n", displayInfo)
-					utils.WriteToLogFile(fmt.Sprintf("[SUCCESS] %s onaylandı.", displayInfo))
-				}
-				return
+	okCount, failCount := s.applyBatchResult(ctx, batchID, result, database.GetPublishBatchBarcodes(ctx, s.Key, batchID))
+	utils.WriteToLogFile(fmt.Sprintf("[BATCH] %s tamamlandı. Onaylanan: %d | Reddedilen: %d", batchID, okCount, failCount))
+	return true, nil
+}
+
+// FetchBatchResult paketin güncel durumunu çeker
+func (s *PazaramaService) FetchBatchResult(ctx context.Context, batchID string) (core.PazaramaBatchResult, error) {
+	var result core.PazaramaBatchResult
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetQueryParam("BatchRequestId", batchID).
+			SetResult(&result).
+			Get("https://isortagimapi.pazarama.com/product/getProductBatchResult")
+	})
+
+	if err != nil {
+		return result, fmt.Errorf("Bağlantı hatası: %w", err)
+	}
+	if !resp.IsSuccess() || !result.Success {
+		return result, utils.ResponseError(s.Key, "FetchBatchResult", resp)
+	}
+	return result, nil
+}
+
+// applyBatchResult tamamlanan paketin sonucunu ürün kodu üzerinden eşleştirip
+// pazarama_sync_status/pazarama_sync_message alanlarına ve publish_rejections tablosuna yazar
+func (s *PazaramaService) applyBatchResult(ctx context.Context, batchID string, result core.PazaramaBatchResult, codes []string) (int, int) {
+	rejected := make(map[string][]string)
+	unattributed := 0
+	for _, res := range result.Data.BatchResult {
+		if res.Reason == "" {
+			continue
+		}
+		if res.Code == "" {
+			unattributed++
+			continue
+		}
+		rejected[res.Code] = append(rejected[res.Code], res.Reason)
+	}
+
+	okCount, failCount := 0, 0
+	for _, code := range codes {
+		barcode := strings.TrimSuffix(code, "-PZR")
+
+		if reasons, ok := rejected[code]; ok {
+			reason := strings.Join(reasons, "; ")
+			database.SetSyncStatus(ctx, barcode, s.Key, "REJECTED", reason)
+			database.SaveRejection(ctx, core.PublishRejection{
+				Platform:     s.Key,
+				BatchID:      batchID,
+				Barcode:      barcode,
+				PlatformCode: code,
+				Reason:       reason,
+			})
+			utils.WriteToLogFile(fmt.Sprintf("[RED] %s -> %s", code, reason))
+			failCount++
+			continue
+		}
+
+		if unattributed > 0 {
+			// Kodsuz red varsa hangi ürünün reddedildiğini bilemeyiz, onaylandı demiyoruz. Otomatik yayın bu ürünleri
+			// tekrar göndermez (kabul edilmişse mükerrer ilan açılırdı); panelde yoksa red listesinden tekrar denenir.
+			reason := fmt.Sprintf("Pakette ürün kodu olmayan %d red var, panelden kontrol edin", unattributed)
+			database.SetSyncStatus(ctx, barcode, s.Key, "UNVERIFIED", reason)
+			database.SaveRejection(ctx, core.PublishRejection{
+				Platform:     s.Key,
+				BatchID:      batchID,
+				Barcode:      barcode,
+				PlatformCode: code,
+				Reason:       reason,
+			})
+			continue
+		}
+
+		database.SetSyncStatus(ctx, barcode, s.Key, "SYNCED", "Pazarama onayladı")
+		database.SetPlatformID(ctx, barcode, s.Key, code)
+		database.ResolveRejections(ctx, s.Key, barcode)
+		okCount++
+	}
+
+	database.ClosePublishBatch(ctx, s.Key, batchID)
+	return okCount, failCount + unattributed
+}
+
+// RefreshBatchResults açık paketlerin sonuçlarını iş kuyruğunu beklemeden hemen işler
+func (s *PazaramaService) RefreshBatchResults(ctx context.Context) error {
+	batches, err := database.GetOpenPublishBatches(ctx, s.Key)
+	if err != nil {
+		return err
+	}
+	if len(batches) == 0 {
+		fmt.Println("[OK] Sonucu beklenen Pazarama paketi yok.")
+		return nil
+	}
+
+	var firstErr error
+	failed := 0
+	for _, batchID := range batches {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result, err := s.FetchBatchResult(ctx, batchID)
+		if err != nil {
+			fmt.Printf("[HATA] %s sorgulanamadı: %v\n", batchID, err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
 			}
+			continue
+		}
+		if result.Data.Status != 2 {
+			fmt.Printf("[WAIT] %s hâlâ işleniyor.\n", batchID)
+			continue
 		}
-		time.Sleep(15 * time.Second)
+		okCount, failCount := s.applyBatchResult(ctx, batchID, result, database.GetPublishBatchBarcodes(ctx, s.Key, batchID))
+		fmt.Printf("[OK] %s işlendi. Onaylanan: %d | Reddedilen: %d\n", batchID, okCount, failCount)
 	}
+	if failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "RefreshBatchResults", Done: len(batches) - failed, Failed: failed, Err: firstErr}
+	}
+	return nil
+}
+
+// trackBatch gönderilen paketi publish_batches'e kaydeder, ürünleri PENDING_IMPORT yapar
+// ve sonucun takibi için kalıcı iş kuyruğuna ekler
+func (s *PazaramaService) trackBatch(ctx context.Context, batchID string, items []core.PazaramaProductItem) {
+	codes := make([]string, len(items))
+	for i, item := range items {
+		codes[i] = item.Code
+		database.SetSyncStatus(ctx, strings.TrimSuffix(item.Code, "-PZR"), s.Key, "PENDING_IMPORT", "batchRequestId: "+batchID)
+	}
+	database.SavePublishBatch(ctx, s.Key, batchID, codes)
+	EnqueueBatchJob(ctx, JobPazaramaBatch, s.Key, batchID)
 }
 
-func (s *PazaramaService) GetCategoryAttributes(token string, categoryID string) error {
+func (s *PazaramaService) GetCategoryAttributes(ctx context.Context, categoryID string) error {
 	fmt.Printf("\n[LOG] %s kategorisi için özellikler çekiliyor...\n", categoryID)
 
 	// DİKKAT: Endpoint ve Parametre ismi (Id) güncellendi!
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetQueryParam("Id", categoryID). // "categoryId" değil, "Id"
-		Get("https://isortagimapi.pazarama.com/category/getCategoryWithAttributes")
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetQueryParam("Id", categoryID). // "categoryId" değil, "Id"
+			Get("https://isortagimapi.pazarama.com/category/getCategoryWithAttributes")
+	})
 
 	if err != nil {
-		return fmt.Errorf("Bağlantı hatası: %v", err)
+		return fmt.Errorf("Bağlantı hatası: %w", err)
 	}
 
 	// Senin sevdiğin detaylı loglama: HTTP kodunu mutlaka görelim
 	fmt.Printf("[LOG] HTTP Durum Kodu: %d\n", resp.StatusCode())
 
-	if resp.StatusCode() == 404 {
-		fmt.Println("[HATA] Endpoint bulunamadı (404). Lütfen URL'yi kontrol et.")
-		return nil
+	if !resp.IsSuccess() {
+		return utils.ResponseError(s.Key, "GetCategoryAttributes", resp)
 	}
 
 	if resp.String() == "" || resp.String() == "null" {
@@ -301,46 +451,22 @@ func (s *PazaramaService) GetCategoryAttributes(token string, categoryID string)
 	return nil
 }
 
-func (s *PazaramaService) AutoMapMandatoryAttributes(token string, categoryID string) error {
+func (s *PazaramaService) AutoMapMandatoryAttributes(ctx context.Context, categoryID string) error {
 	fmt.Printf("\n[LOG] %s kategorisi için zorunlu özellikler analiz ediliyor...\n", categoryID)
 
-	// Daha önce yazdığımız endpoint ve Parametre (Id)
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetQueryParam("Id", categoryID).
-		Get("https://isortagimapi.pazarama.com/category/getCategoryWithAttributes")
-
+	attributes, err := s.fetchCategoryAttributes(ctx, categoryID)
 	if err != nil {
 		return err
 	}
 
-	// JSON parse için geçici struct
-	var result struct {
-		Data struct {
-			Attributes []struct {
-				ID              string `json:"id"`
-				Name            string `json:"name"`
-				IsRequired      bool   `json:"isRequired"`
-				AttributeValues []struct {
-					ID    string `json:"id"`
-					Value string `json:"value"`
-				} `json:"attributeValues"`
-			} `json:"attributes"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return err
-	}
-
 	foundCount := 0
-	for _, attr := range result.Data.Attributes {
+	for _, attr := range attributes {
 		if attr.IsRequired {
 			if len(attr.AttributeValues) > 0 {
 				// İlk değeri varsayılan seçiyoruz (Örn: Sade, Krom, 1gr vb.)
 				defVal := attr.AttributeValues[0]
 
-				_, err := database.DB.Exec(`
+				_, err := database.DB.ExecContext(ctx, `
 					INSERT OR REPLACE INTO platform_category_defaults 
 					(platform, category_id, attribute_id, attribute_name, value_id, value_name) 
 					VALUES ('pazarama', ?, ?, ?, ?, ?)`,
@@ -363,7 +489,96 @@ func (s *PazaramaService) AutoMapMandatoryAttributes(token string, categoryID st
 	return nil
 }
 
-func (s *PazaramaService) SendBatchToPazarama(token string, products []core.PazaramaProductItem) (string, error) {
+// fetchCategoryAttributes kategorinin özellik tanımlarını çeker (servis ömrü boyunca önbellekte tutulur)
+func (s *PazaramaService) fetchCategoryAttributes(ctx context.Context, categoryID string) ([]pazaramaCategoryAttribute, error) {
+	if cached, ok := s.categoryAttrCache[categoryID]; ok {
+		return cached, nil
+	}
+
+	// Daha önce yazdığımız endpoint ve Parametre (Id)
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetQueryParam("Id", categoryID).
+			Get("https://isortagimapi.pazarama.com/category/getCategoryWithAttributes")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, utils.ResponseError(s.Key, "GetCategoryAttributes", resp)
+	}
+
+	var result struct {
+		Data struct {
+			Attributes []pazaramaCategoryAttribute `json:"attributes"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, err
+	}
+
+	s.categoryAttrCache[categoryID] = result.Data.Attributes
+	return result.Data.Attributes, nil
+}
+
+// applyVariant ürünü master DB'deki varyant grubuna bağlar: GroupCode'u doldurur ve
+// varyant eksenlerini (Renk, Beden...) kategorinin özellik ID'lerine çevirip varsayılanların üzerine yazar
+func (s *PazaramaService) applyVariant(ctx context.Context, item *core.PazaramaProductItem) {
+	variant, ok := database.GetVariant(ctx, strings.TrimSuffix(item.Code, "-PZR"))
+	if !ok {
+		// Gruba ait olmayan ürün kendi başına bir gruptur
+		if item.GroupCode == "" {
+			item.GroupCode = item.Code
+		}
+		return
+	}
+
+	item.GroupCode = variant.GroupCode
+	if len(variant.Attributes) == 0 || item.CategoryId == "" {
+		return
+	}
+
+	attributes, err := s.fetchCategoryAttributes(ctx, item.CategoryId)
+	if err != nil {
+		fmt.Printf("[UYARI] %s kategorisinin özellikleri alınamadı, varyant eksenleri eklenmedi: %v\n", item.CategoryId, err)
+		return
+	}
+
+	for _, va := range variant.Attributes {
+		matched := false
+		for _, attr := range attributes {
+			if !strings.EqualFold(strings.TrimSpace(attr.Name), strings.TrimSpace(va.Name)) {
+				continue
+			}
+			for _, val := range attr.AttributeValues {
+				if strings.EqualFold(strings.TrimSpace(val.Value), strings.TrimSpace(va.Value)) {
+					item.Attributes = setPazaramaAttribute(item.Attributes, attr.ID, val.ID)
+					matched = true
+					break
+				}
+			}
+			break
+		}
+		if !matched {
+			utils.WriteToLogFile(fmt.Sprintf("[VARYANT] %s: '%s = %s' Pazarama kategorisinde (%s) bulunamadı.", item.Code, va.Name, va.Value, item.CategoryId))
+		}
+	}
+}
+
+// setPazaramaAttribute aynı özellik varsa değerini değiştirir, yoksa ekler
+func setPazaramaAttribute(attrs []core.PazaramaAttribute, attributeID, valueID string) []core.PazaramaAttribute {
+	for i := range attrs {
+		if attrs[i].AttributeId == attributeID {
+			attrs[i].AttributeValueId = valueID
+			return attrs
+		}
+	}
+	return append(attrs, core.PazaramaAttribute{AttributeId: attributeID, AttributeValueId: valueID})
+}
+
+func (s *PazaramaService) SendBatchToPazarama(ctx context.Context, products []core.PazaramaProductItem) (string, error) {
 	request := core.PazaramaCreateProductRequest{
 		Products: products,
 	}
@@ -375,60 +590,138 @@ func (s *PazaramaService) SendBatchToPazarama(token string, products []core.Paza
 		Success bool `json:"success"`
 	}
 
-	resp, err := s.Client.R().
-		SetAuthToken(token).
-		SetBody(request).
-		SetResult(&apiResp).
-		Post("https://isortagimapi.pazarama.com/product/create")
+	if err := utils.GuardWrite(ctx, s.Key, "SendBatch", http.MethodPost, "https://isortagimapi.pazarama.com/product/create", request); err != nil {
+		return "", err
+	}
+
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetBody(request).
+			SetResult(&apiResp).
+			Post("https://isortagimapi.pazarama.com/product/create")
+	})
 
 	if err != nil {
-		return "", fmt.Errorf("HTTP Hatası: %v", err)
+		return "", fmt.Errorf("HTTP Hatası: %w", err)
 	}
 
 	if !apiResp.Success {
-		return "", fmt.Errorf("Pazarama API Hatası: %s", resp.String())
+		return "", utils.ResponseError(s.Key, "SendBatch", resp)
 	}
 
 	return apiResp.Data.BatchRequestId, nil
 }
 
-// Pazarama API erişimi için token alır
-func (s *PazaramaService) GetToken() (string, error) {
-	var authRes core.PazaramaAuthResponse
-	resp, err := s.Client.R().
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		SetBasicAuth(s.Cfg.Pazarama.ClientID, s.Cfg.Pazarama.ClientSecret).
-		SetFormData(map[string]string{"grant_type": "client_credentials"}).
-		SetResult(&authRes).
-		Post("https://isortagimgiris.pazarama.com/connect/token")
+// UpdatePriceStock tek bir ilanın fiyat ve stoğunu updatePriceAndInventory-v2 ile günceller;
+// yanıt sadece kabul bildirir, değerler gecikmeli geri okunarak doğrulanır.
+// force=false iken son gönderimle aynı değerler ErrUnchanged ile atlanır.
+func (s *PazaramaService) UpdatePriceStock(ctx context.Context, code string, price float64, stock int, force bool) error {
+	// Liste fiyatı satış fiyatıyla aynı gönderiliyor; Excel akışı farklı liste fiyatını içerik olarak ekler
+	hash := utils.PushFingerprint(price, stock, fmt.Sprintf("listPrice=%.2f", price))
+	if skipUnchanged(ctx, s.Key, code, hash, force) {
+		return ErrUnchanged
+	}
+
+	if err := s.sendPriceStock(ctx, code, price, stock); err != nil {
+		return err
+	}
+
+	// Gönderim yapıldı; kayıtlar kapanış başlamış olsa da tutulmalı
+	bg := context.WithoutCancel(ctx)
+	barcode := strings.TrimSuffix(code, "-PZR")
+	database.RecordStockChange(bg, s.Key, barcode, stock, "PUSH")
+	database.SavePushFingerprint(bg, s.Key, code, barcode, hash, price, stock)
+	scheduleVerification(bg, s.Cfg, s.Key, code, barcode, price, stock)
+	return nil
+}
+
+func (s *PazaramaService) sendPriceStock(ctx context.Context, code string, price float64, stock int) error {
+	url := "https://isortagimapi.pazarama.com/product/updatePriceAndInventory-v2"
+	body := map[string]interface{}{
+		"items": []map[string]interface{}{{
+			"code":       code,
+			"salePrice":  price,
+			"listPrice":  price,
+			"stockCount": stock,
+		}},
+	}
+
+	fmt.Printf("[LOG] Pazarama Fiyat/Stok Güncelleniyor: %s, Fiyat: %.2f\n", code, price)
 
-	if err != nil || !resp.IsSuccess() {
-		return "", fmt.Errorf("[HATA] Pazarama Auth Hatası: %v", err)
+	if err := utils.GuardWrite(ctx, s.Key, "UpdatePriceStock", http.MethodPost, url, body); err != nil {
+		return err
 	}
-	return authRes.Data.AccessToken, nil
+
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetHeader("Content-Type", "application/json").
+			SetHeader("x-platform", "1").
+			SetBody(body).
+			Post(url)
+	})
+
+	if err != nil {
+		return fmt.Errorf("bağlantı hatası: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return utils.ResponseError(s.Key, "UpdatePriceStock", resp)
+	}
+	return nil
 }
 
-// Pazarama'dan ürünleri çeker ve merkezi DB'ye kaydeder
-func (s *PazaramaService) SyncProducts() error {
-	token, err := s.GetToken()
+// GetProductByCode tek bir ürünün platformdaki güncel fiyat ve stoğunu okur
+func (s *PazaramaService) GetProductByCode(ctx context.Context, code string) (core.PazaramaProduct, error) {
+	var result core.PazaramaProductResponse
+	resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.
+			SetQueryParams(map[string]string{"Code": code, "Page": "1", "Size": "10"}).
+			SetResult(&result).
+			Get("https://isortagimapi.pazarama.com/product/products")
+	})
+
 	if err != nil {
-		return err
+		return core.PazaramaProduct{}, fmt.Errorf("bağlantı hatası: %w", err)
 	}
+	if !resp.IsSuccess() || !result.Success {
+		return core.PazaramaProduct{}, utils.ResponseError(s.Key, "GetProductByCode", resp)
+	}
+
+	for _, p := range result.Data {
+		if p.Code == code {
+			return p, nil
+		}
+	}
+	return core.PazaramaProduct{}, fmt.Errorf("Pazarama ürünü bulunamadı: %s", code)
+}
 
+// Pazarama'dan ürünleri çeker ve merkezi DB'ye kaydeder
+func (s *PazaramaService) SyncProducts(ctx context.Context) error {
 	fmt.Println("[PZR] Ürün senkronizasyonu başlatılıyor...")
 
 	// API'den ham ürünleri çekiyoruz (Mevcut döngü mantığını koruyoruz)
-	pzrProducts, err := s.fetchFromAPI(token)
+	pzrProducts, err := s.fetchFromAPI(ctx)
 	if err != nil {
 		return err
 	}
 
+	sync := beginSync(ctx, s.Key)
+	seen := make(map[string]bool)
 	for _, pzr := range pzrProducts {
+		if ctx.Err() != nil {
+			return sync.interrupt(ctx)
+		}
+
 		// Log tutma alışkanlığına uygun akış bilgisi
 		fmt.Printf("[PZR-AKIS] İşleniyor: %s | Fiyat: %.2f\n", pzr.Code, pzr.SalePrice)
 
 		// Barkod temizleme: Senin sync_service içindeki mantığı buraya taşıyoruz
 		cleanBarcode := strings.TrimSuffix(pzr.Code, "-PZR")
+		seen[cleanBarcode] = true
+
+		hash, changed := sync.changed(pzr.Code, pzr)
+		if !changed {
+			continue
+		}
 
 		// Merkezi modele (core.Product) dönüştürme (Mapping)
 		p := core.Product{
@@ -442,15 +735,37 @@ func (s *PazaramaService) SyncProducts() error {
 		}
 
 		// Merkezi kayıt fonksiyonunu çağırıyoruz
-		database.SaveProduct(p)
-	}
+		saveSyncedProduct(ctx, s.Key, s.Account.Markup, p)
+		if database.GetSyncStatus(ctx, cleanBarcode, s.Key) == "UNVERIFIED" {
+			// Sonucu belirsiz kalan ürün Pazarama'da bulundu: kabul edilmiş
+			database.SetSyncStatus(ctx, cleanBarcode, s.Key, "SYNCED", "Pazarama'da bulundu")
+			database.ResolveRejections(ctx, s.Key, cleanBarcode)
+		}
+		database.UpsertListing(ctx, core.Listing{
+			Platform:        s.Key,
+			ExternalID:      pzr.Code,
+			PlatformBarcode: pzr.Code,
+			MasterBarcode:   cleanBarcode,
+			LastPrice:       pzr.SalePrice,
+			LastStock:       pzr.StockCount,
+		})
+		database.SaveStockSnapshot(ctx, s.Key, cleanBarcode, pzr.Code, pzr.StockCount, pzr.SalePrice)
+		sync.commit(ctx, pzr.Code, hash)
+	}
+	sync.finish(ctx)
 
 	fmt.Printf("[OK] %d adet Pazarama ürünü sisteme işlendi.\n", len(pzrProducts))
+
+	reconcileDelisted(ctx, s.Key, seen)
+	InferSalesFromSnapshots(ctx, s.Key)
+
+	// Platformdan gelen paket stokları yerine bileşenlerden türetilen değer geçerli
+	RecalculateAllBundles(ctx)
 	return nil
 }
 
 // Sayfalı yapıda tüm ürünleri getiren yardımcı metod
-func (s *PazaramaService) fetchFromAPI(token string) ([]core.PazaramaProduct, error) {
+func (s *PazaramaService) fetchFromAPI(ctx context.Context) ([]core.PazaramaProduct, error) {
 	var allProducts []core.PazaramaProduct
 	page := 1
 	size := 100
@@ -458,17 +773,25 @@ func (s *PazaramaService) fetchFromAPI(token string) ([]core.PazaramaProduct, er
 	for {
 		fmt.Printf("[LOG] Pazarama Sayfa %d çekiliyor...\n", page)
 		var result core.PazaramaProductResponse
-		resp, err := s.Client.R().
-			SetAuthToken(token).
-			SetQueryParams(map[string]string{
-				"Approved": "true",
-				"Page":     fmt.Sprintf("%d", page),
-				"Size":     fmt.Sprintf("%d", size),
-			}).
-			SetResult(&result).
-			Get("https://isortagimapi.pazarama.com/product/products")
+		resp, err := s.authorized(ctx, func(req *resty.Request) (*resty.Response, error) {
+			return req.
+				SetQueryParams(map[string]string{
+					"Approved": "true",
+					"Page":     fmt.Sprintf("%d", page),
+					"Size":     fmt.Sprintf("%d", size),
+				}).
+				SetResult(&result).
+				Get("https://isortagimapi.pazarama.com/product/products")
+		})
 
-		if err != nil || !resp.IsSuccess() || !result.Success || len(result.Data) == 0 {
+		if err != nil {
+			return nil, partialFetch(s.Key, len(allProducts), fmt.Errorf("Pazarama sayfa %d alınamadı: %w", page, err))
+		}
+		if !resp.IsSuccess() || !result.Success {
+			return nil, partialFetch(s.Key, len(allProducts), utils.ResponseError(s.Key, "SyncProducts", resp))
+		}
+
+		if len(result.Data) == 0 {
 			break
 		}
 
@@ -482,11 +805,11 @@ func (s *PazaramaService) fetchFromAPI(token string) ([]core.PazaramaProduct, er
 	return allProducts, nil
 }
 
-func (s *PazaramaService) GetDefaultAttributesFromDB(categoryID string) []core.PazaramaAttribute {
+func (s *PazaramaService) GetDefaultAttributesFromDB(ctx context.Context, categoryID string) []core.PazaramaAttribute {
 
 	attrs := []core.PazaramaAttribute{}
 
-	rows, err := database.DB.Query(`
+	rows, err := database.DB.QueryContext(ctx, `
 		SELECT attribute_id, value_id 
 		FROM platform_category_defaults 
 		WHERE platform = 'pazarama' AND category_id = ?`, categoryID)
@@ -4,6 +4,7 @@ import (
 	"arbitraj-bot/core"
 	"arbitraj-bot/database"
 	"arbitraj-bot/utils"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -13,7 +14,7 @@ import (
 )
 
 // FillPazaramaCategoryIDs artık PazaramaService metodu
-func (s *PazaramaService) FillPazaramaCategoryIDs(filePath string) error {
+func (s *PazaramaService) FillPazaramaCategoryIDs(ctx context.Context, filePath string) error {
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
 		return err
@@ -41,7 +42,7 @@ func (s *PazaramaService) FillPazaramaCategoryIDs(filePath string) error {
 
 	for catName := range uniqueCategories {
 		var savedID string
-		err := database.DB.QueryRow("SELECT pazarama_id FROM category_mappings WHERE master_category_name = ?", catName).Scan(&savedID)
+		err := database.DB.QueryRowContext(ctx, "SELECT pazarama_id FROM category_mappings WHERE master_category_name = ?", catName).Scan(&savedID)
 
 		if err == nil && savedID != "" {
 			uniqueCategories[catName] = savedID
@@ -49,7 +50,7 @@ func (s *PazaramaService) FillPazaramaCategoryIDs(filePath string) error {
 			continue
 		}
 
-		matches := utils.FindTopCategoryMatches(catName, "pazarama")
+		matches := utils.FindTopCategoryMatches(ctx, catName, "pazarama")
 
 		if len(matches) == 0 {
 			fmt.Printf("[!] %s için hiçbir eşleşme bulunamadı! Manuel ID girin: ", catName)
@@ -75,7 +76,7 @@ func (s *PazaramaService) FillPazaramaCategoryIDs(filePath string) error {
 			}
 		}
 
-		database.DB.Exec("INSERT OR REPLACE INTO category_mappings (master_category_name, pazarama_id) VALUES (?, ?)", catName, uniqueCategories[catName])
+		database.DB.ExecContext(ctx, "INSERT OR REPLACE INTO category_mappings (master_category_name, pazarama_id) VALUES (?, ?)", catName, uniqueCategories[catName])
 	}
 
 	fmt.Println("[LOG] Excel dosyası güncelleniyor...")
@@ -94,7 +95,7 @@ func (s *PazaramaService) FillPazaramaCategoryIDs(filePath string) error {
 }
 
 // UploadSingleProductFromExcelPazarama metot haline getirildi, s.Client ve s.Cfg kullanıyor
-func (s *PazaramaService) UploadSingleProductFromExcelPazarama(token string, filePath string, rowIndex int) (string, core.PazaramaProductItem, error) {
+func (s *PazaramaService) UploadSingleProductFromExcelPazarama(ctx context.Context, filePath string, rowIndex int) (string, core.PazaramaProductItem, error) {
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
 		return "", core.PazaramaProductItem{}, fmt.Errorf("Excel dosyası açılamadı: %v", err)
@@ -117,7 +118,7 @@ func (s *PazaramaService) UploadSingleProductFromExcelPazarama(token string, fil
 	aciklama := p[9]
 
 	// s. üzerinden çağırıyoruz
-	brandId, err := s.GetBrandIDByName(token, markaAdi)
+	brandId, err := s.GetBrandIDByName(ctx, markaAdi)
 	if err != nil {
 		return "", core.PazaramaProductItem{}, err
 	}
@@ -130,7 +131,7 @@ func (s *PazaramaService) UploadSingleProductFromExcelPazarama(token string, fil
 	}
 
 	// s. üzerinden çağırıyoruz
-	defaultAttrs := s.GetDefaultAttributesFromDB(kategoriId)
+	defaultAttrs := s.GetDefaultAttributesFromDB(ctx, kategoriId)
 
 	productRequest := core.PazaramaProductItem{
 		Code:         barkod,
@@ -150,15 +151,19 @@ func (s *PazaramaService) UploadSingleProductFromExcelPazarama(token string, fil
 		Images:       pazaramaImages,
 		Attributes:   defaultAttrs,
 	}
+	s.applyVariant(ctx, &productRequest)
 
-	batchID, err := s.CreateProductPazarama(token, productRequest)
+	batchID, err := s.CreateProductPazarama(ctx, productRequest)
+	if err == nil {
+		s.trackBatch(context.WithoutCancel(ctx), batchID, []core.PazaramaProductItem{productRequest})
+	}
 	return batchID, productRequest, err
 }
 
 // BulkUploadPazarama artık dışarıdan client/token almıyor, servisten kullanıyor
-func (s *PazaramaService) BulkUploadPazarama(filePath string) error {
-	token, err := s.GetToken()
-	if err != nil {
+func (s *PazaramaService) BulkUploadPazarama(ctx context.Context, filePath string) error {
+	// Kimlik bilgileri hatalıysa satır satır denemeden hemen dön
+	if _, err := s.Auth.Token(ctx); err != nil {
 		return err
 	}
 
@@ -177,10 +182,18 @@ func (s *PazaramaService) BulkUploadPazarama(filePath string) error {
 	const chunkSize = 100
 	totalRows := len(rows)
 	checkedCategories := make(map[string]bool)
+	var firstErr error
+	sent, failed := 0, 0
 
 	fmt.Printf("\n[BULK] Operasyon Başlıyor: Toplam %d ürün...\n", totalRows-1)
 
 	for i := 1; i < totalRows; i++ {
+		// Kapanışta hazırlanan yarım paket gönderilmez; gönderilen paketler zaten takipte
+		if ctx.Err() != nil {
+			fmt.Printf("[DURDURULDU] Toplu yükleme kesildi; Excel'in %d. satırından itibaren gönderilmedi.\n", i+1-len(batch))
+			return ctx.Err()
+		}
+
 		p := rows[i]
 		if len(p) < 9 || p[0] == "" {
 			continue
@@ -195,14 +208,14 @@ func (s *PazaramaService) BulkUploadPazarama(filePath string) error {
 		kategoriId := p[8]
 		aciklama := p[9]
 
-		brandId, _ := s.GetBrandIDByName(token, markaAdi)
-		defaultAttrs := s.GetDefaultAttributesFromDB(kategoriId)
+		brandId, _ := s.GetBrandIDByName(ctx, markaAdi)
+		defaultAttrs := s.GetDefaultAttributesFromDB(ctx, kategoriId)
 
 		if len(defaultAttrs) == 0 && !checkedCategories[kategoriId] {
 			fmt.Printf("\n[LOG] %s kategorisi analiz ediliyor...\n", kategoriId)
-			s.AutoMapMandatoryAttributes(token, kategoriId)
+			s.AutoMapMandatoryAttributes(ctx, kategoriId)
 			checkedCategories[kategoriId] = true
-			defaultAttrs = s.GetDefaultAttributesFromDB(kategoriId)
+			defaultAttrs = s.GetDefaultAttributesFromDB(ctx, kategoriId)
 		}
 
 		var images []core.PazaramaImage
@@ -212,7 +225,7 @@ func (s *PazaramaService) BulkUploadPazarama(filePath string) error {
 			}
 		}
 
-		batch = append(batch, core.PazaramaProductItem{
+		item := core.PazaramaProductItem{
 			Code:         barkod,
 			Name:         urunAdi,
 			DisplayName:  urunAdi,
@@ -227,30 +240,40 @@ func (s *PazaramaService) BulkUploadPazarama(filePath string) error {
 			Attributes:   defaultAttrs,
 			Images:       images,
 			CurrencyType: "TRY",
-		})
+		}
+		s.applyVariant(ctx, &item)
+		batch = append(batch, item)
 
 		if len(batch) == chunkSize || i == totalRows-1 {
-			batchID, err := s.SendBatchToPazarama(token, batch)
-			if err != nil {
+			batchID, err := s.SendBatchToPazarama(ctx, batch)
+			switch {
+			case utils.IsInterceptedWrite(err) || (err != nil && ctx.Err() != nil):
+			case err != nil:
 				utils.WriteToLogFile(fmt.Sprintf("[HATA] Paket gönderilemedi: %v", err))
-			} else {
+				failed += len(batch)
+				if firstErr == nil {
+					firstErr = err
+				}
+			default:
 				utils.WriteToLogFile(fmt.Sprintf("[OK] Paket kuyruğa alındı: %s", batchID))
-				tempBatch := make([]core.PazaramaProductItem, len(batch))
-				copy(tempBatch, batch)
-				go s.WatchBatchStatus(token, batchID, tempBatch)
+				s.trackBatch(context.WithoutCancel(ctx), batchID, batch)
+				sent += len(batch)
 			}
 			batch = []core.PazaramaProductItem{}
-			time.Sleep(2 * time.Second)
+			pause(ctx, 2*time.Second)
 		}
 	}
 
+	if failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "BulkUpload", Done: sent, Failed: failed, Err: firstErr}
+	}
 	return nil
 }
 
 // UploadMissingProductsPazarama metot haline getirildi
-func (s *PazaramaService) UploadMissingProductsPazarama(originalPath string, missingPath string) error {
-	token, err := s.GetToken()
-	if err != nil {
+func (s *PazaramaService) UploadMissingProductsPazarama(ctx context.Context, originalPath string, missingPath string) error {
+	// Kimlik bilgileri hatalıysa satır satır denemeden hemen dön
+	if _, err := s.Auth.Token(ctx); err != nil {
 		return err
 	}
 
@@ -281,8 +304,15 @@ func (s *PazaramaService) UploadMissingProductsPazarama(originalPath string, mis
 	rows, _ := fOrig.GetRows(fOrig.GetSheetName(0))
 	var batch []core.PazaramaProductItem
 	checkedCats := make(map[string]bool)
+	var firstErr error
+	sent, failed := 0, 0
 
 	for i := 1; i < len(rows); i++ {
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] Eksik ürün yüklemesi kesildi; gönderilmeyenler sonraki çalıştırmada tekrar denenebilir.")
+			return ctx.Err()
+		}
+
 		p := rows[i]
 		if len(p) < 9 || p[0] == "" {
 			continue
@@ -292,13 +322,13 @@ func (s *PazaramaService) UploadMissingProductsPazarama(originalPath string, mis
 			continue
 		}
 
-		brandId, _ := s.GetBrandIDByName(token, p[6])
+		brandId, _ := s.GetBrandIDByName(ctx, p[6])
 		kategoriId := p[8]
-		defaultAttrs := s.GetDefaultAttributesFromDB(kategoriId)
+		defaultAttrs := s.GetDefaultAttributesFromDB(ctx, kategoriId)
 		if len(defaultAttrs) == 0 && !checkedCats[kategoriId] {
-			s.AutoMapMandatoryAttributes(token, kategoriId)
+			s.AutoMapMandatoryAttributes(ctx, kategoriId)
 			checkedCats[kategoriId] = true
-			defaultAttrs = s.GetDefaultAttributesFromDB(kategoriId)
+			defaultAttrs = s.GetDefaultAttributesFromDB(ctx, kategoriId)
 		}
 
 		item := core.PazaramaProductItem{
@@ -322,19 +352,30 @@ func (s *PazaramaService) UploadMissingProductsPazarama(originalPath string, mis
 				item.Images = append(item.Images, core.PazaramaImage{Imageurl: p[imgIdx]})
 			}
 		}
+		s.applyVariant(ctx, &item)
 
 		batch = append(batch, item)
 
 		if len(batch) == 50 || i == len(rows)-1 {
-			batchID, err := s.SendBatchToPazarama(token, batch)
-			if err == nil {
-				tempBatch := make([]core.PazaramaProductItem, len(batch))
-				copy(tempBatch, batch)
-				go s.WatchBatchStatus(token, batchID, tempBatch)
+			batchID, err := s.SendBatchToPazarama(ctx, batch)
+			switch {
+			case utils.IsInterceptedWrite(err) || (err != nil && ctx.Err() != nil):
+			case err != nil:
+				failed += len(batch)
+				if firstErr == nil {
+					firstErr = err
+				}
+			default:
+				s.trackBatch(context.WithoutCancel(ctx), batchID, batch)
+				sent += len(batch)
 			}
 			batch = []core.PazaramaProductItem{}
-			time.Sleep(2 * time.Second)
+			pause(ctx, 2*time.Second)
 		}
 	}
+
+	if failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "UploadMissingProducts", Done: sent, Failed: failed, Err: firstErr}
+	}
 	return nil
 }
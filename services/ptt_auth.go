@@ -0,0 +1,378 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	pttLoginURL     = "https://tedarik-api.pttavm.com/auth/login"
+	pttVerifyOTPURL = "https://tedarik-api.pttavm.com/auth/verify-otp"
+	pttRefreshURL   = "https://tedarik-api.pttavm.com/auth/refresh-token"
+)
+
+const (
+	// Süresi bu kadar içinde dolacak token yenilenir; istek yoldayken düşmesin
+	tokenSkew = time.Minute
+	// Ne expiresIn ne JWT exp gelirse token bu kadar geçerli sayılır
+	defaultTokenTTL = time.Hour
+	// file/http sağlayıcılarının kodu bekleme süresi
+	otpWaitTimeout  = 5 * time.Minute
+	otpPollInterval = 2 * time.Second
+)
+
+// OtpCodeProvider panel girişindeki tek kullanımlık doğrulama kodunu temin eder
+type OtpCodeProvider interface {
+	OtpCode(ctx context.Context, otpID string) (string, error)
+	// Interactive kodu konsoldan soruyorsa true döner; arka plan işleri bu durumda giriş yapmaz
+	Interactive() bool
+}
+
+// PromptOtpProvider kodu konsoldan, menüyle ortak okuyucu (utils.Stdin) üzerinden sorar
+type PromptOtpProvider struct{}
+
+func (PromptOtpProvider) OtpCode(ctx context.Context, otpID string) (string, error) {
+	fmt.Print("\n[?] PttAVM doğrulama kodu (SMS/e-posta): ")
+
+	// Okuma bloklanır; kapanışta kodu beklemeden dönülür
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := utils.Stdin.ReadString('\n')
+		lines <- line
+	}()
+
+	var code string
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case code = <-lines:
+	}
+	if code = strings.TrimSpace(code); code == "" {
+		return "", fmt.Errorf("doğrulama kodu girilmedi")
+	}
+	return code, nil
+}
+
+func (PromptOtpProvider) Interactive() bool { return true }
+
+// FileOtpProvider kodun bir dosyaya yazılmasını bekler (Örn: SMS yönlendirme betiği yazar); okunan dosya silinir
+type FileOtpProvider struct {
+	Path string
+}
+
+func (p FileOtpProvider) OtpCode(ctx context.Context, otpID string) (string, error) {
+	// Önceki girişten kalan kod yanlışlıkla kullanılmasın
+	os.Remove(p.Path)
+	fmt.Printf("[LOG] PttAVM doğrulama kodu '%s' dosyasında bekleniyor...\n", p.Path)
+
+	return pollOtp(ctx, func() (string, error) {
+		raw, err := os.ReadFile(p.Path)
+		if err != nil {
+			return "", nil
+		}
+		code := strings.TrimSpace(string(raw))
+		if code != "" {
+			os.Remove(p.Path)
+		}
+		return code, nil
+	})
+}
+
+func (FileOtpProvider) Interactive() bool { return false }
+
+// HTTPOtpProvider kodu bir geri çağırma servisinden sorgular; servis kod gelene kadar 200 dışı döner.
+// Yanıt düz metin kod ya da {"code": "..."} olabilir.
+type HTTPOtpProvider struct {
+	URL    string
+	Client *resty.Client
+}
+
+func (p HTTPOtpProvider) OtpCode(ctx context.Context, otpID string) (string, error) {
+	fmt.Println("[LOG] PttAVM doğrulama kodu geri çağırma servisinden bekleniyor...")
+
+	return pollOtp(ctx, func() (string, error) {
+		resp, err := p.Client.R().SetContext(ctx).
+			SetQueryParam("otpId", otpID).
+			Get(p.URL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", err
+			}
+			return "", nil
+		}
+		if resp.StatusCode() != 200 {
+			return "", nil
+		}
+
+		var body struct {
+			Code string `json:"code"`
+		}
+		if json.Unmarshal(resp.Body(), &body) == nil && body.Code != "" {
+			return strings.TrimSpace(body.Code), nil
+		}
+		return strings.TrimSpace(resp.String()), nil
+	})
+}
+
+func (HTTPOtpProvider) Interactive() bool { return false }
+
+// pollOtp kod gelene, süre dolana ya da kapanış başlayana kadar fetch'i tekrarlar
+func pollOtp(ctx context.Context, fetch func() (string, error)) (string, error) {
+	deadline := time.Now().Add(otpWaitTimeout)
+	for {
+		code, err := fetch()
+		if err != nil {
+			return "", err
+		}
+		if code != "" {
+			return code, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("doğrulama kodu %s içinde gelmedi", otpWaitTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(otpPollInterval):
+		}
+	}
+}
+
+// NewOtpProvider config'deki otp_provider ayarına göre sağlayıcıyı seçer
+func NewOtpProvider(cfg core.PttConfig, client *resty.Client) OtpCodeProvider {
+	switch strings.ToLower(cfg.OtpProvider) {
+	case "file":
+		return FileOtpProvider{Path: cfg.OtpFile}
+	case "http":
+		return HTTPOtpProvider{URL: cfg.OtpURL, Client: client}
+	default:
+		return PromptOtpProvider{}
+	}
+}
+
+// PttAuth tedarik-api panel oturumunu yönetir: panel_email/panel_psswd ile giriş yapar, OTP adımını
+// sağlayıcıya sorar, access/refresh token'ları bitiş zamanlarıyla saklar ve süresi dolmadan yeniler.
+type PttAuth struct {
+	Client  *resty.Client
+	Account core.PttConfig
+	Key     string // oturumun auth_tokens'taki anahtarı (hesap anahtarı)
+	Otp     OtpCodeProvider
+
+	mu    sync.Mutex
+	token core.AuthToken
+}
+
+// NewPttAuth kayıtlı oturumu DB'den yükler; yoksa config'deki eski elle girilmiş token 401 alana kadar kullanılır
+func NewPttAuth(client *resty.Client, account core.PttConfig, otp OtpCodeProvider) *PttAuth {
+	a := &PttAuth{Client: client, Account: account, Key: account.Key(), Otp: otp}
+	if t, ok := database.GetAuthToken(context.Background(), a.Key); ok && t.AccessToken != "" {
+		a.token = t
+		utils.RegisterSecret(t.AccessToken, t.RefreshToken)
+	} else if account.Token != "" {
+		a.token = core.AuthToken{AccessToken: account.Token, ExpiresAt: tokenExpiry(account.Token, 0)}
+	}
+	return a
+}
+
+// Token geçerli access token'ı döndürür; gerekirse yeniler ya da (OTP dahil) yeniden giriş yapar
+func (a *PttAuth) Token(ctx context.Context) (string, error) {
+	return a.acquire(ctx, true)
+}
+
+// BackgroundToken arka plan işleri içindir: yenileme yapar ama OTP konsoldan soruluyorsa giriş yapmaz
+func (a *PttAuth) BackgroundToken(ctx context.Context) (string, error) {
+	return a.acquire(ctx, !a.Otp.Interactive())
+}
+
+func (a *PttAuth) acquire(ctx context.Context, allowLogin bool) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token.AccessToken != "" && time.Until(a.token.ExpiresAt) > tokenSkew {
+		return a.token.AccessToken, nil
+	}
+
+	if a.token.RefreshToken != "" {
+		err := a.refresh(ctx)
+		if err == nil {
+			return a.token.AccessToken, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		utils.WriteToLogFile(fmt.Sprintf("[UYARI] PTT token yenilenemedi, yeniden giriş yapılacak: %v", err))
+	}
+
+	if !allowLogin {
+		return "", &core.AuthError{Platform: a.Key, Message: "panel oturumu sona erdi; PTT menüsünden giriş yapın"}
+	}
+	if err := a.login(ctx); err != nil {
+		return "", err
+	}
+	return a.token.AccessToken, nil
+}
+
+// Invalidate 401 alınan token'ı geçersiz sayar; aynı anda başka bir istek token'ı yenilediyse dokunmaz
+func (a *PttAuth) Invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token.AccessToken == token {
+		a.token.ExpiresAt = time.Time{}
+	}
+}
+
+// Login refresh token'ı beklemeden baştan giriş yapar (menüden elle oturum açmak için)
+func (a *PttAuth) Login(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.login(ctx)
+}
+
+// ExpiresAt mevcut access token'ın bitiş zamanı (oturum yoksa sıfır)
+func (a *PttAuth) ExpiresAt() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token.AccessToken == "" {
+		return time.Time{}
+	}
+	return a.token.ExpiresAt
+}
+
+func (a *PttAuth) login(ctx context.Context) error {
+	if a.Account.PanelEmail == "" || a.Account.PanelPasswd == "" {
+		return &core.AuthError{Platform: a.Key, Message: "panel_email/panel_psswd tanımlı değil"}
+	}
+	fmt.Println("[LOG] PttAVM paneline giriş yapılıyor...")
+
+	// Giriş pazaryerinde veri değiştirmez; dry-run ve acil durdurmada da oturum açılabilmeli.
+	// Tekrar denenmez: her giriş yeni OTP gönderip bekleyen otpId'yi geçersiz kılar.
+	var res core.PttLoginResponse
+	resp, err := utils.GuardExempt(a.Client.R().SetContext(ctx)).
+		SetHeader("content-type", "application/json").
+		SetHeader("referer", "https://tedarikci.pttavm.com/").
+		SetBody(core.PttLoginRequest{Email: a.Account.PanelEmail, Password: a.Account.PanelPasswd}).
+		SetResult(&res).
+		Post(pttLoginURL)
+	if err != nil {
+		return fmt.Errorf("PTT girişi yapılamadı: %w", err)
+	}
+	if !resp.IsSuccess() || !res.IsSuccess {
+		return a.loginError(resp)
+	}
+
+	if res.Data.OtpRequired {
+		code, err := a.Otp.OtpCode(ctx, res.Data.OtpId)
+		if err != nil {
+			return &core.AuthError{Platform: a.Key, Message: "OTP kodu alınamadı: " + err.Error()}
+		}
+
+		verify := core.PttVerifyOTPRequest{OtpCode: code, OtpId: res.Data.OtpId, PreOtpToken: res.Data.PreOtpToken}
+		res = core.PttLoginResponse{}
+		// OTP kodu tek kullanımlık; tekrar gönderilmemeli
+		resp, err = utils.GuardExempt(a.Client.R().SetContext(ctx)).
+			SetHeader("content-type", "application/json").
+			SetHeader("referer", "https://tedarikci.pttavm.com/").
+			SetBody(verify).
+			SetResult(&res).
+			Post(pttVerifyOTPURL)
+		if err != nil {
+			return fmt.Errorf("PTT OTP doğrulanamadı: %w", err)
+		}
+		if !resp.IsSuccess() || !res.IsSuccess {
+			return a.loginError(resp)
+		}
+	}
+
+	if err := a.store(ctx, resp, res); err != nil {
+		return err
+	}
+	fmt.Printf("[OK] PttAVM oturumu açıldı (geçerlilik: %s).\n", a.token.ExpiresAt.Format("15:04"))
+	return nil
+}
+
+func (a *PttAuth) refresh(ctx context.Context) error {
+	var res core.PttLoginResponse
+	resp, err := utils.Retryable(a.Client.R().SetContext(ctx)).
+		SetHeader("content-type", "application/json").
+		SetBody(core.PttRefreshTokenRequest{RefreshToken: a.token.RefreshToken}).
+		SetResult(&res).
+		Post(pttRefreshURL)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() || !res.IsSuccess {
+		return a.loginError(resp)
+	}
+	if err := a.store(ctx, resp, res); err != nil {
+		return err
+	}
+	utils.WriteToLogFile(fmt.Sprintf("[LOG] %s panel token'ı yenilendi.", a.Key))
+	return nil
+}
+
+// store token'ları gövdeden ya da çerezlerden alır, bitiş zamanını hesaplayıp kaydeder
+func (a *PttAuth) store(ctx context.Context, resp *resty.Response, res core.PttLoginResponse) error {
+	access, refresh := res.Data.AccessToken, res.Data.RefreshToken
+	for _, c := range resp.Cookies() {
+		switch {
+		case access == "" && strings.EqualFold(c.Name, "accessToken"):
+			access = c.Value
+		case refresh == "" && strings.EqualFold(c.Name, "refreshToken"):
+			refresh = c.Value
+		}
+	}
+	if access == "" {
+		return &core.AuthError{Platform: a.Key, Message: "giriş yanıtında access token yok"}
+	}
+	// Yenileme yanıtı yeni refresh token dönmezse eskisi geçerli kalır
+	if refresh == "" {
+		refresh = a.token.RefreshToken
+	}
+
+	a.token = core.AuthToken{AccessToken: access, RefreshToken: refresh, ExpiresAt: tokenExpiry(access, res.Data.ExpiresIn)}
+	utils.RegisterSecret(access, refresh)
+	// Oturum kapanış başlamış olsa da saklanmalı; yoksa sonraki açılışta tekrar OTP istenir
+	database.SaveAuthToken(context.WithoutCancel(ctx), a.Key, a.token)
+
+	return nil
+}
+
+// loginError giriş/yenileme yanıtını AuthError'a çevirir; istek sınırı ayrıca bildirilir
+func (a *PttAuth) loginError(resp *resty.Response) error {
+	err := utils.ResponseError(a.Key, "Login", resp)
+	if rejected, ok := err.(*core.RemoteRejected); ok {
+		return &core.AuthError{Platform: a.Key, Message: rejected.Message}
+	}
+	return err
+}
+
+// tokenExpiry expiresIn verilmişse onu, yoksa JWT içindeki exp alanını kullanır
+func tokenExpiry(token string, expiresIn int) time.Time {
+	if expiresIn > 0 {
+		return time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			var claims struct {
+				Exp int64 `json:"exp"`
+			}
+			if json.Unmarshal(payload, &claims) == nil && claims.Exp > 0 {
+				return time.Unix(claims.Exp, 0)
+			}
+		}
+	}
+	return time.Now().Add(defaultTokenTTL)
+}
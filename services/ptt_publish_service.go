@@ -0,0 +1,134 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PublishProducts ptt_id'si olmayan master ürünleri UpdateProductsV3 formatına çevirip yükler.
+// Kategori category_mappings.ptt_id üzerinden çözülür, sonuçlar ptt_sync_status'a yazılır.
+func (s *PttService) PublishProducts(ctx context.Context) error {
+	products, err := database.GetProductsMissingPlatform(ctx, s.Key)
+	if err != nil {
+		return fmt.Errorf("ürünler okunamadı: %v", err)
+	}
+
+	fmt.Printf("\n[PTT-YAYIN] PTT'de ilanı olmayan %d ürün inceleniyor...\n", len(products))
+
+	var items []core.PttProduct
+	var firstErr error
+	skipped := 0
+	for _, p := range products {
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] PTT yayını kesildi; gönderilmeyen ürünler sonraki yayında tekrar seçilecek.")
+			return ctx.Err()
+		}
+
+		// Sonucu beklenen ya da kabul edilip henüz senkronizasyonla bağlanmamış ürün tekrar gönderilmez
+		if status := syncStatus(ctx, s.Key, p); status == "PENDING_IMPORT" || status == "SYNCED" {
+			continue
+		}
+
+		item, err := s.buildPttProduct(ctx, p)
+		if err != nil {
+			fmt.Printf("[!] %s atlandı: %v\n", p.Barcode, err)
+			database.SetSyncStatus(ctx, p.Barcode, s.Key, "PUBLISH_ERROR", err.Error())
+			skipped++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		items = append(items, item)
+	}
+
+	failed := 0
+	if len(items) > 0 {
+		uploadErr := s.BulkUploadToPtt(ctx, items)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var partial *core.PartialResult
+		if errors.As(uploadErr, &partial) {
+			failed = partial.Failed
+			uploadErr = partial.Err
+		}
+		if firstErr == nil {
+			firstErr = uploadErr
+		}
+	}
+
+	fmt.Printf("[OK] PTT yayın akışı tamamlandı. Gönderilen: %d | Atlanan: %d | Gönderilemeyen: %d\n", len(items)-failed, skipped, failed)
+	if skipped+failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "PublishProducts", Done: len(items) - failed, Failed: skipped + failed, Err: firstErr}
+	}
+	return nil
+}
+
+func (s *PttService) buildPttProduct(ctx context.Context, p core.Product) (core.PttProduct, error) {
+	categoryID, err := s.resolveCategory(ctx, p.CategoryName)
+	if err != nil {
+		return core.PttProduct{}, err
+	}
+	if strings.TrimSpace(p.Brand) == "" {
+		return core.PttProduct{}, &core.ValidationError{Platform: s.Key, Subject: p.Barcode, Fields: []core.FieldError{{Field: "Marka", Message: "marka boş"}}}
+	}
+
+	var images []string
+	for _, img := range strings.Split(p.Images, "|") {
+		if img = strings.TrimSpace(img); img != "" {
+			images = append(images, img)
+		}
+	}
+	if len(images) == 0 {
+		return core.PttProduct{}, &core.ValidationError{Platform: s.Key, Subject: p.Barcode, Fields: []core.FieldError{{Field: "Görseller", Message: "görsel yok"}}}
+	}
+
+	markup := s.Markup(p)
+
+	return core.PttProduct{
+		Barkod:         p.Barcode,
+		StokKodu:       p.Barcode,
+		UrunAdi:        p.ProductName,
+		Aciklama:       p.Description,
+		Marka:          p.Brand,
+		KategoriAdi:    p.CategoryName,
+		KategoriId:     categoryID,
+		Fiyat:          p.Price * markup,
+		Stok:           p.Stock,
+		KdvOrani:       p.VatRate,
+		HazirlikSuresi: p.DeliveryTime,
+		Gorseller:      images,
+	}, nil
+}
+
+// resolveCategory master kategori adını PTT kategori ID'sine çevirir (category_mappings.ptt_id)
+func (s *PttService) resolveCategory(ctx context.Context, categoryName string) (int, error) {
+	if strings.TrimSpace(categoryName) == "" {
+		return 0, core.NewValidationError(s.Key, "Kategori", "ürünün kategorisi boş")
+	}
+
+	if m, ok := database.GetCategoryMapping(ctx, categoryName); ok && m.PttID != 0 {
+		return m.PttID, nil
+	}
+
+	// PTT kategorileri platform_categories'e "PTT" olarak kaydediliyor
+	matches := utils.FindTopCategoryMatches(ctx, categoryName, "PTT")
+	if len(matches) > 0 && matches[0].Score >= 0.95 {
+		fmt.Printf("[LOG] PTT Otomatik Eşleşti (%%%.0f): %s -> %s\n", matches[0].Score*100, categoryName, matches[0].Name)
+		database.SaveCategoryMappingID(ctx, categoryName, "ptt", matches[0].ID)
+		return strconv.Atoi(matches[0].ID)
+	}
+
+	if len(matches) > 0 {
+		return 0, core.NewValidationError(s.Key, "Kategori", fmt.Sprintf("PTT kategori eşleşmesi yok (en yakın: %s %%%.0f)", matches[0].Name, matches[0].Score*100))
+	}
+	return 0, core.NewValidationError(s.Key, "Kategori", "PTT kategori eşleşmesi yok: "+categoryName)
+
+}
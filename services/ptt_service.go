@@ -1,14 +1,14 @@
 package services
 
 import (
-	"arbitraj-bot/config"
 	"arbitraj-bot/core"
 	"arbitraj-bot/database"
 	"arbitraj-bot/utils"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,86 +19,151 @@ import (
 
 // PttService PTT SOAP ve REST işlemlerini yöneten ana yapı
 type PttService struct {
-	Client *resty.Client
-	Cfg    *core.Config
+	Client  *resty.Client
+	Cfg     *core.Config
+	Account core.PttConfig // servisin çalıştığı mağaza
+	Key     string         // hesabın DB'deki platform anahtarı (Örn: "ptt", "ptt:magaza2")
+	Auth    *PttAuth       // tedarik-api (REST) panel oturumu
 }
 
-// NewPttService servisi bağımlılıklarla başlatır
-func NewPttService(client *resty.Client, cfg *core.Config) *PttService {
+// NewPttService servisi bağımlılıklarla ve verilen mağaza hesabıyla başlatır
+func NewPttService(client *resty.Client, cfg *core.Config, account core.PttConfig) *PttService {
 	return &PttService{
-		Client: client,
-		Cfg:    cfg,
+		Client:  client,
+		Cfg:     cfg,
+		Account: account,
+		Key:     account.Key(),
+		Auth:    NewPttAuth(client, account, NewOtpProvider(account, client)),
 	}
 }
 
 // --- Ana Fonksiyonlar ---
 
 // SyncProducts PTT ürünlerini çeker ve merkezi DB'ye işler
-func (s *PttService) SyncProducts() error {
+func (s *PttService) SyncProducts(ctx context.Context) error {
 	fmt.Println("[PTT] Ürün senkronizasyonu başlatılıyor...")
-	products, err := s.fetchFromAPI()
+	products, err := s.fetchFromAPI(ctx)
 	if err != nil {
 		return err
 	}
 
+	sync := beginSync(ctx, s.Key)
+	seen := make(map[string]bool)
 	for _, ptt := range products {
-		fmt.Printf("[PTT-AKIS] İşleniyor: %s | Stok: %d\n", ptt.Barkod, ptt.MevcutStok)
-
+		if ctx.Err() != nil {
+			return sync.interrupt(ctx)
+		}
 		cleanBarcode := utils.CleanPttBarcode(ptt.Barkod)
+		seen[cleanBarcode] = true
+
+		listingID := strconv.FormatInt(ptt.UrunId, 10)
+		hash, changed := sync.changed(listingID, ptt)
+		if !changed {
+			continue
+		}
+		fmt.Printf("[PTT-AKIS] İşleniyor: %s | Stok: %d\n", ptt.Barkod, ptt.MevcutStok)
 
 		p := core.Product{
 
 			Barcode:     cleanBarcode,
 			ProductName: ptt.UrunAdi,
 			Description: ptt.Aciklama,
-			PttId:       strconv.FormatInt(ptt.UrunId, 10),
+			PttId:       listingID,
 			Price:       ptt.MevcutFiyat,
 			VatRate:     ptt.KdvOrani,
 			Stock:       ptt.MevcutStok,
 			IsDirty:     0,
 		}
-		database.SaveProduct(p)
+		saveSyncedProduct(ctx, s.Key, s.Account.Markup, p)
+		database.UpsertListing(ctx, core.Listing{
+			Platform:        s.Key,
+			ExternalID:      listingID,
+			PlatformBarcode: ptt.Barkod,
+			MasterBarcode:   cleanBarcode,
+			LastPrice:       ptt.MevcutFiyat,
+			LastStock:       ptt.MevcutStok,
+		})
+		database.SaveStockSnapshot(ctx, s.Key, cleanBarcode, p.PttId, ptt.MevcutStok, ptt.MevcutFiyat)
+		sync.commit(ctx, listingID, hash)
 	}
+	sync.finish(ctx)
 	fmt.Printf("[OK] %d adet PTT ürünü sisteme işlendi.\n", len(products))
+
+	reconcileDelisted(ctx, s.Key, seen)
+
+	// PTT'de sipariş akışı yok: satışları stok düşüşlerinden tahmin ediyoruz
+	InferSalesFromSnapshots(ctx, s.Key)
+
+	// Platformdan gelen paket stokları yerine bileşenlerden türetilen değer geçerli
+	RecalculateAllBundles(ctx)
 	return nil
 }
 
-// UpdateStockPriceRest PTT Tedarikçi API üzerinden detaylı güncelleme yapar
-func (s *PttService) UpdateStockPriceRest(productID string, stock int, price float64) (string, error) {
+// UpdateStockPriceRest PTT Tedarikçi API üzerinden detaylı güncelleme yapar; değerler gecikmeli geri okunarak doğrulanır.
+// force=false iken son gönderimle aynı değerler detay okunmadan ErrUnchanged ile atlanır.
+func (s *PttService) UpdateStockPriceRest(ctx context.Context, productID string, stock int, price float64, force bool) (string, error) {
+	// Sabit gönderilen alanlar da özete dahil; değişirlerse ürün tekrar gönderilir
+	hash := utils.PushFingerprint(price, stock, "evo_category_id=1090|cargo_from_supplier=1|single_box=1")
+	if skipUnchanged(ctx, s.Key, productID, hash, force) {
+		return "", ErrUnchanged
+	}
+
+	respBody, cleanBarcode, err := s.sendStockPriceRest(ctx, productID, stock, price)
+	if err != nil {
+		return respBody, err
+	}
+
+	// Gönderim yapıldı; kayıtlar kapanış başlamış olsa da tutulmalı
+	bg := context.WithoutCancel(ctx)
+	// Gönderilen fiyat marjlı platform fiyatıdır; master fiyatın üzerine yazılmaz
+	database.SetSyncStatus(bg, cleanBarcode, s.Key, "SYNCED", "Fiyat/stok gönderildi")
+	database.RecordStockChange(bg, s.Key, cleanBarcode, stock, "PUSH")
+	database.SavePushFingerprint(bg, s.Key, productID, cleanBarcode, hash, price, stock)
+	scheduleVerification(bg, s.Cfg, s.Key, productID, cleanBarcode, price, stock)
+	fmt.Printf("[+] PTT Senkronizasyonu Başarılı: %s\n", cleanBarcode)
+	return respBody, nil
+}
+
+// sendStockPriceRest ürün detayını okuyup fiyat/stok alanlarını değiştirerek geri yazar; temiz barkodu döndürür
+func (s *PttService) sendStockPriceRest(ctx context.Context, productID string, stock int, price float64) (string, string, error) {
 	getURL := fmt.Sprintf("https://tedarik-api.pttavm.com/product/detail/%s", productID)
 	updateURL := fmt.Sprintf("https://tedarik-api.pttavm.com/product/update/%s", productID)
 
-	for {
-		resp, err := s.Client.R().
-			SetHeader("authorization", "Bearer "+s.Cfg.Ptt.Token).
+	for attempt := 0; ; attempt++ {
+		token, err := s.Auth.Token(ctx)
+		if err != nil {
+			return "", "", err
+		}
+
+		resp, err := s.Client.R().SetContext(ctx).
+			SetHeader("authorization", "Bearer "+token).
 			SetHeader("accept", "application/json").
 			Get(getURL)
 
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
-		if resp.StatusCode() == 401 {
-			fmt.Println("\n[!] PttAVM Token süresi dolmuş!")
-			fmt.Print("[?] Yeni Bearer Token'ı yapıştırın: ")
-			var newToken string
-			fmt.Scanln(&newToken)
-			s.Cfg.Ptt.Token = strings.TrimSpace(newToken)
-
-			config.SaveConfig("config/config.json", *s.Cfg)
-			fmt.Println("[+] Token güncellendi, tekrar deneniyor...")
+		// Token sunucu tarafında düşürülmüş olabilir; bir kez yenileyip (gerekirse yeniden giriş) tekrar deniyoruz
+		if resp.StatusCode() == http.StatusUnauthorized && attempt == 0 {
+			fmt.Println("[!] PttAVM oturumu geçersiz, yenileniyor...")
+			s.Auth.Invalidate(token)
 			continue
 		}
 
+		if !resp.IsSuccess() {
+			return "", "", utils.ResponseError(s.Key, "GetProductDetail", resp)
+		}
+
 		var result map[string]interface{}
 		json.Unmarshal(resp.Body(), &result)
 		raw, ok := result["data"].(map[string]interface{})
 		if !ok {
-			return "HATA: Detay verisi bulunamadı", nil
+			return "", "", fmt.Errorf("PTT ürün detayı bulunamadı (%s)", productID)
 		}
 
 		// Resim indirme ve DB'ye işleme
-		s.handleProductImage(raw)
+		s.handleProductImage(ctx, raw)
 
 		payload := map[string]interface{}{
 			"contents":            raw["contents"],
@@ -117,58 +182,118 @@ func (s *PttService) UpdateStockPriceRest(productID string, stock int, price flo
 			"product_id":          productID,
 		}
 
-		updateResp, err := s.Client.R().
-			SetHeader("authorization", "Bearer "+s.Cfg.Ptt.Token).
+		if err := utils.GuardWrite(ctx, s.Key, "UpdateStockPriceRest", http.MethodPost, updateURL, payload); err != nil {
+			return "", "", err
+		}
+
+		updateResp, err := s.Client.R().SetContext(ctx).
+			SetHeader("authorization", "Bearer "+token).
 			SetHeader("content-type", "application/json").
 			SetHeader("referer", "https://tedarikci.pttavm.com/").
 			SetBody(payload).
 			Post(updateURL)
 
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
-		if updateResp.IsSuccess() {
-			rawBarcode, _ := raw["barcode"].(string)
-			cleanBarcode := utils.CleanPttBarcode(rawBarcode)
-			database.SaveProduct(core.Product{
-				Barcode:       cleanBarcode,
-				Stock:         stock,
-				Price:         price,
-				PttSyncStatus: "SYNCED",
-			})
-			fmt.Printf("[+] PTT Senkronizasyonu Başarılı: %s\n", cleanBarcode)
+		if !updateResp.IsSuccess() {
+			if updateResp.StatusCode() == http.StatusUnauthorized {
+				s.Auth.Invalidate(token)
+			}
+			return updateResp.String(), "", utils.ResponseError(s.Key, "UpdateStockPriceRest", updateResp)
 		}
-		return updateResp.String(), nil
+
+		rawBarcode, _ := raw["barcode"].(string)
+		return updateResp.String(), utils.CleanPttBarcode(rawBarcode), nil
 	}
 }
 
-// BulkUploadToPtt Ürünleri paketler halinde PTT'ye yükler
-func (s *PttService) BulkUploadToPtt(allProducts []core.PttProduct) {
+// GetProductDetail ürünün platformdaki güncel fiyat (KDV hariç) ve stoğunu okur.
+// Arka planda çalıştığı için oturum yenilenebilir ama OTP kullanıcıya sorulmaz; giriş gerekiyorsa hata döner.
+func (s *PttService) GetProductDetail(ctx context.Context, productID string) (float64, int, error) {
+	token, err := s.Auth.BackgroundToken(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	resp, err := s.Client.R().SetContext(ctx).
+		SetHeader("authorization", "Bearer "+token).
+		SetHeader("accept", "application/json").
+		Get(fmt.Sprintf("https://tedarik-api.pttavm.com/product/detail/%s", productID))
+
+	if err != nil {
+		return 0, 0, err
+	}
+	if !resp.IsSuccess() {
+		if resp.StatusCode() == http.StatusUnauthorized {
+			s.Auth.Invalidate(token)
+		}
+		return 0, 0, utils.ResponseError(s.Key, "GetProductDetail", resp)
+	}
+
+	var result map[string]interface{}
+	json.Unmarshal(resp.Body(), &result)
+	raw, ok := result["data"].(map[string]interface{})
+	if !ok {
+		return 0, 0, fmt.Errorf("PTT ürün detayı bulunamadı (%s)", productID)
+	}
+
+	return rawNumber(raw["vat_excluded_price"]), int(rawNumber(raw["quantity"])), nil
+}
+
+// BulkUploadToPtt Ürünleri paketler halinde PTT'ye yükler; gönderilemeyen paket varsa PartialResult döner
+func (s *PttService) BulkUploadToPtt(ctx context.Context, allProducts []core.PttProduct) error {
 	const batchSize = 1000
+	var firstErr error
+	sent, failed := 0, 0
 	for i := 0; i < len(allProducts); i += batchSize {
 		end := i + batchSize
 		if end > len(allProducts) {
 			end = len(allProducts)
 		}
 
+		// Kapanışta yeni paket gönderilmez; gönderilen paketlerin sonuçları zaten yazıldı
+		if ctx.Err() != nil {
+			fmt.Printf("[DURDURULDU] PTT yüklemesi kesildi; %d. üründen itibaren gönderilmedi.\n", i+1)
+			return ctx.Err()
+		}
+
 		fmt.Printf("\n[>] PTT Paketi Gönderiliyor: %d - %d...\n", i+1, end)
-		err := s.uploadBatchToPtt(allProducts[i:end])
+		batch := allProducts[i:end]
+		result, err := s.uploadBatchToPtt(ctx, batch)
+		if utils.IsInterceptedWrite(err) || (err != nil && ctx.Err() != nil) {
+			continue
+		}
 		if err != nil {
 			fmt.Printf(" [!] Paket hatası: %v\n", err)
+			for _, p := range batch {
+				database.SetSyncStatus(ctx, pttBatchBarcode(p), s.Key, "PUBLISH_ERROR", err.Error())
+			}
+			failed += len(batch)
+			if firstErr == nil {
+				firstErr = err
+			}
 		} else {
-			fmt.Printf(" [+] Paket başarıyla sıraya alındı.\n")
+			// Paket PTT'ye ulaştı; sonuçlar kapanış başlamış olsa da yazılmalı
+			s.saveUploadOutcomes(context.WithoutCancel(ctx), batch, result)
+			sent += len(batch)
 		}
 
 		if end < len(allProducts) {
-			time.Sleep(5 * time.Second)
+			pause(ctx, 5*time.Second)
 		}
 	}
+
+	if failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "BulkUpload", Done: sent, Failed: failed, Err: firstErr}
+	}
+	return nil
 }
 
 // --- Yardımcı API Metodları ---
 
-func (s *PttService) fetchFromAPI() ([]core.PttProduct, error) {
+func (s *PttService) fetchFromAPI(ctx context.Context) ([]core.PttProduct, error) {
 	var allProducts []core.PttProduct
 	page := 0
 	for {
@@ -183,22 +308,27 @@ func (s *PttService) fetchFromAPI() ([]core.PttProduct, error) {
 		   <s:Body>
 			  <tem:StokKontrolListesi><tem:SearchAktifPasif>0</tem:SearchAktifPasif><tem:SearchPage>%d</tem:SearchPage></tem:StokKontrolListesi>
 		   </s:Body>
-		</s:Envelope>`, s.Cfg.Ptt.Username, s.Cfg.Ptt.Password, page)
+		</s:Envelope>`, s.Account.Username, s.Account.Password, page)
 
-		resp, err := s.Client.R().
+		// Liste sorgusu yan etkisiz olduğu için POST olsa da tekrar denenebilir
+		resp, err := utils.Retryable(s.Client.R().SetContext(ctx)).
 			SetHeader("Content-Type", "text/xml; charset=utf-8").
 			SetHeader("SOAPAction", "http://tempuri.org/IService/StokKontrolListesi").
 			SetBody([]byte(payload)).Post(url)
 
 		if err != nil {
-			log.Printf("[HATA] PTT Sayfa %d çekilemedi: %v", page, err)
-			break
+			return nil, partialFetch(s.Key, len(allProducts), fmt.Errorf("PTT sayfa %d çekilemedi: %w", page, err))
+		}
+		if !resp.IsSuccess() {
+			return nil, partialFetch(s.Key, len(allProducts), utils.ResponseError(s.Key, "SyncProducts", resp))
 		}
 
 		//fmt.Println("[DEBUG-PTT-XML] Ham Yanıt:", resp.String())
 
 		var result core.PttListResponse
-		xml.Unmarshal(resp.Body(), &result)
+		if err := xml.Unmarshal(resp.Body(), &result); err != nil {
+			return nil, partialFetch(s.Key, len(allProducts), fmt.Errorf("PTT sayfa %d okunamadı: %w", page, err))
+		}
 
 		if len(result.Products) == 0 {
 			break
@@ -212,13 +342,80 @@ func (s *PttService) fetchFromAPI() ([]core.PttProduct, error) {
 	return allProducts, nil
 }
 
-func (s *PttService) uploadBatchToPtt(products []core.PttProduct) error {
+// pttBatchBarcode pakette ürünü tanımlayan barkod (Barkod yoksa stok kodu)
+func pttBatchBarcode(p core.PttProduct) string {
+	if p.Barkod != "" {
+		return p.Barkod
+	}
+	return p.StokKodu
+}
+
+// saveUploadOutcomes UpdateProductsV3 sonucunu barkod bazında ptt_sync_status alanına yazar
+func (s *PttService) saveUploadOutcomes(ctx context.Context, batch []core.PttProduct, result core.PttUploadResponse) {
+	itemResults := make(map[string]int)
+	for i, item := range result.Result.Items {
+		itemResults[utils.CleanPttBarcode(item.Barcode)] = i
+	}
+
+	var queued []string
+	okCount, failCount := 0, 0
+	for _, p := range batch {
+		barcode := utils.CleanPttBarcode(pttBatchBarcode(p))
+
+		if idx, ok := itemResults[barcode]; ok {
+			item := result.Result.Items[idx]
+			switch {
+			case item.Success && item.ProductId > 0:
+				s.linkAccepted(ctx, barcode, item.ProductId)
+				okCount++
+			case item.Success && result.Result.TrackingId != "":
+				// UrunId yanıtta yok; takip sonucundan alınacak
+				database.SetSyncStatus(ctx, barcode, s.Key, "PENDING_IMPORT", "trackingId: "+result.Result.TrackingId)
+				queued = append(queued, barcode)
+				okCount++
+			case item.Success:
+				database.SetSyncStatus(ctx, barcode, s.Key, "SYNCED", "PTT ürünü kabul etti; ilan sonraki PTT senkronizasyonunda bağlanacak")
+				okCount++
+			default:
+				database.SetSyncStatus(ctx, barcode, s.Key, "REJECTED", item.Message)
+				failCount++
+			}
+			continue
+		}
+
+		// Ürün bazlı sonuç yoksa paket sonucu geçerli
+		if result.Result.Success {
+			msg := "PTT kuyruğa aldı"
+			if result.Result.TrackingId != "" {
+				msg = "trackingId: " + result.Result.TrackingId
+			}
+			database.SetSyncStatus(ctx, barcode, s.Key, "PENDING_IMPORT", msg)
+			queued = append(queued, barcode)
+			okCount++
+		} else {
+			database.SetSyncStatus(ctx, barcode, s.Key, "PUBLISH_ERROR", result.Result.Message)
+			failCount++
+		}
+	}
+
+	if result.Result.TrackingId != "" && len(queued) > 0 {
+		database.SavePublishBatch(ctx, s.Key, result.Result.TrackingId, queued)
+		EnqueueBatchJob(ctx, JobPttBatch, s.Key, result.Result.TrackingId)
+	}
+	fmt.Printf(" [+] Paket işlendi. Başarılı: %d | Hatalı: %d\n", okCount, failCount)
+}
+
+// linkAccepted kabul edilen ürünün PTT UrunId'sini yazar; ürün bir sonraki yayında tekrar seçilmez
+func (s *PttService) linkAccepted(ctx context.Context, barcode string, productID int64) {
+	database.SetSyncStatus(ctx, barcode, s.Key, "SYNCED", "PTT ürünü kabul etti")
+	database.SetPlatformID(ctx, barcode, s.Key, strconv.FormatInt(productID, 10))
+}
+
+func (s *PttService) uploadBatchToPtt(ctx context.Context, products []core.PttProduct) (core.PttUploadResponse, error) {
+	var result core.PttUploadResponse
 	var itemsXML strings.Builder
 	for _, p := range products {
-		barcode := p.Barkod
-		if barcode == "" {
-			barcode = p.StokKodu
-		}
+		barcode := pttBatchBarcode(p)
 		kdv := p.KdvOrani
 		if kdv == 0 {
 			kdv = 20
@@ -226,6 +423,29 @@ func (s *PttService) uploadBatchToPtt(products []core.PttProduct) error {
 
 		priceWithoutVat := p.Fiyat / (1 + float64(kdv)/100.0)
 
+		// Varyant bilgisi verilmediyse master DB'den tamamlıyoruz
+		if p.GroupCode == "" {
+			if variant, ok := database.GetVariant(ctx, utils.CleanPttBarcode(barcode)); ok {
+				p.GroupCode = variant.GroupCode
+				p.VariantAttributes = variant.Attributes
+			}
+		}
+
+		var attrXML strings.Builder
+		for _, a := range p.VariantAttributes {
+			attrXML.WriteString(fmt.Sprintf(`<ept:ProductAttributeV3><ept:Name>%s</ept:Name><ept:Value>%s</ept:Value></ept:ProductAttributeV3>`,
+				utils.SanitizeXML(a.Name), utils.SanitizeXML(a.Value)))
+		}
+
+		// WCF DataContract alfabetik sıra bekler: Attributes ve GroupCode ilgili yerlere yerleşmeli
+		var attrBlock, groupBlock string
+		if attrXML.Len() > 0 {
+			attrBlock = "<ept:Attributes>" + attrXML.String() + "</ept:Attributes>"
+		}
+		if p.GroupCode != "" {
+			groupBlock = "<ept:GroupCode>" + utils.SanitizeXML(p.GroupCode) + "</ept:GroupCode>"
+		}
+
 		var imgXML strings.Builder
 		for _, img := range p.Gorseller {
 			if strings.TrimSpace(img) != "" {
@@ -235,10 +455,10 @@ func (s *PttService) uploadBatchToPtt(products []core.PttProduct) error {
 
 		itemsXML.WriteString(fmt.Sprintf(`
 			<ept:ProductV3Request>
-				<ept:Active>true</ept:Active>
+				<ept:Active>true</ept:Active>%s
 				<ept:Barcode>%s</ept:Barcode>
 				<ept:Brand>%s</ept:Brand>
-				<ept:CategoryId>%d</ept:CategoryId>
+				<ept:CategoryId>%d</ept:CategoryId>%s
 				<ept:Images>%s</ept:Images>
 				<ept:LongDescription><![CDATA[%s]]></ept:LongDescription>
 				<ept:Name>%s</ept:Name>
@@ -247,7 +467,7 @@ func (s *PttService) uploadBatchToPtt(products []core.PttProduct) error {
 				<ept:Quantity>%d</ept:Quantity>
 				<ept:VATRate>%d</ept:VATRate>
 			</ept:ProductV3Request>`,
-			barcode, utils.SanitizeXML(p.Marka), p.KategoriId, imgXML.String(),
+			attrBlock, barcode, utils.SanitizeXML(p.Marka), p.KategoriId, groupBlock, imgXML.String(),
 			utils.SanitizeXMLOnly(p.Aciklama), utils.SanitizeXML(p.UrunAdi),
 			p.Fiyat, priceWithoutVat, p.Stok, kdv))
 	}
@@ -260,39 +480,160 @@ func (s *PttService) uploadBatchToPtt(products []core.PttProduct) error {
 	      </wsse:Security>
 	   </soapenv:Header>
 	   <soapenv:Body><tem:UpdateProductsV3><tem:items>%s</tem:items></tem:UpdateProductsV3></soapenv:Body>
-	</soapenv:Envelope>`, s.Cfg.Ptt.Username, s.Cfg.Ptt.Password, itemsXML.String())
+	</soapenv:Envelope>`, s.Account.Username, s.Account.Password, itemsXML.String())
 
-	resp, err := s.Client.R().
+	if err := utils.GuardWrite(ctx, s.Key, "UpdateProductsV3", http.MethodPost, "https://ws.pttavm.com:93/service.svc", soapXML); err != nil {
+		return result, err
+	}
+
+	resp, err := s.Client.R().SetContext(ctx).
 		SetHeader("Content-Type", "text/xml;charset=UTF-8").
 		SetHeader("SOAPAction", "http://tempuri.org/IService/UpdateProductsV3").
 		SetBody([]byte(soapXML)).Post("https://ws.pttavm.com:93/service.svc")
 
 	if err != nil {
-		return err
+		return result, err
 	}
-	fmt.Printf("[PTT Yanıtı]: %s\n", resp.String())
-	return nil
+
+	if err := xml.Unmarshal(resp.Body(), &result); err != nil {
+		if !resp.IsSuccess() {
+			return result, utils.ResponseError(s.Key, "UpdateProductsV3", resp)
+		}
+		return result, fmt.Errorf("PTT yanıtı çözümlenemedi (HTTP %d): %v", resp.StatusCode(), err)
+	}
+	if result.Fault != "" {
+		return result, &core.RemoteRejected{Platform: s.Key, Operation: "UpdateProductsV3", StatusCode: resp.StatusCode(), Message: result.Fault}
+	}
+	if !resp.IsSuccess() {
+		return result, utils.ResponseError(s.Key, "UpdateProductsV3", resp)
+	}
+
+	return result, nil
+}
+
+// CheckBatchJob iş kuyruğundan çağrılır: trackingId'yi bir kez sorgular ve kesinleşen ürünleri DB'ye yazar.
+// Paketteki tüm ürünler kesinleştiyse paketi kapatır ve done=true döner.
+func (s *PttService) CheckBatchJob(ctx context.Context, trackingID string) (bool, error) {
+	if !database.IsPublishBatchOpen(ctx, s.Key, trackingID) {
+		return true, nil
+	}
+
+	result, err := s.FetchTrackingResult(ctx, trackingID)
+	if err != nil {
+		return false, err
+	}
+
+	pending := 0
+	seen := make(map[string]bool)
+	for _, item := range result.Result.Items {
+		barcode := utils.CleanPttBarcode(item.Barcode)
+		seen[barcode] = true
+		switch {
+		case item.Success && item.ProductId > 0:
+			s.linkAccepted(ctx, barcode, item.ProductId)
+		case item.Success:
+			database.SetSyncStatus(ctx, barcode, s.Key, "SYNCED", "PTT ürünü kabul etti; ilan sonraki PTT senkronizasyonunda bağlanacak")
+		default:
+			database.SetSyncStatus(ctx, barcode, s.Key, "REJECTED", item.Message)
+		}
+		utils.WriteToLogFile(fmt.Sprintf("[PTT-SONUÇ] %s -> başarılı: %t %s", barcode, item.Success, item.Message))
+	}
+
+	// Yanıtta görünmeyen ürün henüz işlenmemiştir; PTT işi bitirdiyse sonucu bildirilmemiş demektir
+	for _, b := range database.GetPublishBatchBarcodes(ctx, s.Key, trackingID) {
+		if seen[b] {
+			continue
+		}
+		if result.Result.Completed {
+			database.SetSyncStatus(ctx, b, s.Key, "PUBLISH_ERROR", "PTT takip sonucunda ürün yok: "+result.Result.Message)
+			continue
+		}
+		pending++
+	}
+
+	if pending > 0 {
+		return false, nil
+	}
+	database.ClosePublishBatch(ctx, s.Key, trackingID)
+	return true, nil
+}
+
+// FetchTrackingResult UpdateProductsV3 ile kuyruğa alınan paketin ürün bazlı sonucunu çeker
+func (s *PttService) FetchTrackingResult(ctx context.Context, trackingID string) (core.PttTrackingResponse, error) {
+	var result core.PttTrackingResponse
+	body := "<tem:GetTrackingResultV3><tem:trackingId>" + utils.SanitizeXML(trackingID) + "</tem:trackingId></tem:GetTrackingResultV3>"
+
+	// Takip sorgusu yan etkisiz olduğu için POST olsa da tekrar denenebilir
+	resp, err := utils.Retryable(s.Client.R().SetContext(ctx)).
+		SetHeader("Content-Type", "text/xml;charset=UTF-8").
+		SetHeader("SOAPAction", "http://tempuri.org/IService/GetTrackingResultV3").
+		SetBody([]byte(s.getBasicSoapEnvelope("", body))).
+		Post("https://ws.pttavm.com:93/service.svc")
+	if err != nil {
+		return result, err
+	}
+
+	if err := xml.Unmarshal(resp.Body(), &result); err != nil {
+		if !resp.IsSuccess() {
+			return result, utils.ResponseError(s.Key, "GetTrackingResultV3", resp)
+		}
+		return result, fmt.Errorf("PTT takip yanıtı çözümlenemedi (HTTP %d): %v", resp.StatusCode(), err)
+	}
+	if result.Fault != "" {
+		return result, &core.RemoteRejected{Platform: s.Key, Operation: "GetTrackingResultV3", StatusCode: resp.StatusCode(), Message: result.Fault}
+	}
+	if !resp.IsSuccess() {
+		return result, utils.ResponseError(s.Key, "GetTrackingResultV3", resp)
+	}
+	return result, nil
 }
 
 // --- Kategori İşlemleri ---
 
-func (s *PttService) ListAllPttCategories() {
+// ListAllPttCategories kategori ağacını çekip kaydeder; alt ağacı alınamayan ana kategori varsa PartialResult döner
+func (s *PttService) ListAllPttCategories(ctx context.Context) error {
 	fmt.Println("\n[*] PTT Kategori Hiyerarşisi İşleniyor...")
-	mainCats := s.fetchMainCategoriesData()
+	mainCats, err := s.fetchMainCategoriesData(ctx)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	failed := 0
 	for _, main := range mainCats {
-		database.SavePlatformCategory("PTT", "0", "Root", main.CategoryID, main.CategoryName, false)
-		s.fetchSubTree(main)
-		time.Sleep(300 * time.Millisecond)
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] PTT kategori çekimi kesildi; kaydedilen kategoriler korunuyor.")
+			return ctx.Err()
+		}
+		database.SavePlatformCategory(ctx, "PTT", "0", "Root", main.CategoryID, main.CategoryName, false)
+		if err := s.fetchSubTree(ctx, main); err != nil {
+			fmt.Printf("[HATA] %s alt kategorileri alınamadı: %v\n", main.CategoryName, err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		pause(ctx, 300*time.Millisecond)
+	}
+	if failed > 0 {
+		return &core.PartialResult{Platform: s.Key, Operation: "SyncCategories", Done: len(mainCats) - failed, Failed: failed, Err: firstErr}
 	}
 	fmt.Println("[OK] PTT Kategorileri başarıyla kaydedildi.")
+	return nil
 }
 
-func (s *PttService) fetchMainCategoriesData() []core.PlatformCategory {
+func (s *PttService) fetchMainCategoriesData(ctx context.Context) ([]core.PlatformCategory, error) {
 	soapXML := s.getBasicSoapEnvelope("GetMainCategories", "")
-	resp, _ := s.Client.R().
+	resp, err := utils.Retryable(s.Client.R().SetContext(ctx)).
 		SetHeader("Content-Type", "text/xml;charset=UTF-8").
 		SetHeader("SOAPAction", "http://tempuri.org/IService/GetMainCategories").
 		SetBody([]byte(soapXML)).Post("https://ws.pttavm.com:93/service.svc")
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, utils.ResponseError(s.Key, "GetMainCategories", resp)
+	}
 
 	var results []core.PlatformCategory
 	blocks := strings.Split(resp.String(), "<a:category>")
@@ -305,17 +646,23 @@ func (s *PttService) fetchMainCategoriesData() []core.PlatformCategory {
 			})
 		}
 	}
-	return results
+	return results, nil
 }
 
-func (s *PttService) fetchSubTree(parent core.PlatformCategory) {
+func (s *PttService) fetchSubTree(ctx context.Context, parent core.PlatformCategory) error {
 	body := fmt.Sprintf("<tem:GetCategoryTree><tem:parent_id>%s</tem:parent_id><tem:last_update>2025</tem:last_update></tem:GetCategoryTree>", parent.CategoryID)
 	soapXML := s.getBasicSoapEnvelope("", body)
 
-	resp, _ := s.Client.R().
+	resp, err := utils.Retryable(s.Client.R().SetContext(ctx)).
 		SetHeader("Content-Type", "text/xml;charset=UTF-8").
 		SetHeader("SOAPAction", "http://tempuri.org/IService/GetCategoryTree").
 		SetBody([]byte(soapXML)).Post("https://ws.pttavm.com:93/service.svc")
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return utils.ResponseError(s.Key, "GetCategoryTree", resp)
+	}
 
 	raw := resp.String()
 	idRegex := regexp.MustCompile(`<(?:a:)?(?:id|category_id)>(\d+)</(?:a:)?(?:id|category_id)>`)
@@ -333,8 +680,9 @@ func (s *PttService) fetchSubTree(parent core.PlatformCategory) {
 		if i < len(names) {
 			currentName = strings.ReplaceAll(names[i][1], "&amp;", "&")
 		}
-		database.SavePlatformCategory("PTT", parent.CategoryID, parent.CategoryName, currentID, currentName, true)
+		database.SavePlatformCategory(ctx, "PTT", parent.CategoryID, parent.CategoryName, currentID, currentName, true)
 	}
+	return nil
 }
 
 // --- Küçük Yardımcılar ---
@@ -351,7 +699,7 @@ func (s *PttService) getBasicSoapEnvelope(method string, bodyContent string) str
 	      </wsse:Security>
 	   </soapenv:Header>
 	   <soapenv:Body>%s</soapenv:Body>
-	</soapenv:Envelope>`, s.Cfg.Ptt.Username, s.Cfg.Ptt.Password, bodyContent)
+	</soapenv:Envelope>`, s.Account.Username, s.Account.Password, bodyContent)
 }
 
 func (s *PttService) extractTag(data, tag string) string {
@@ -369,7 +717,7 @@ func (s *PttService) extractTag(data, tag string) string {
 	return data[start : start+end]
 }
 
-func (s *PttService) handleProductImage(raw map[string]interface{}) {
+func (s *PttService) handleProductImage(ctx context.Context, raw map[string]interface{}) {
 	if photos, ok := raw["photos"].([]interface{}); ok && len(photos) > 0 {
 		var url string
 		switch v := photos[0].(type) {
@@ -383,7 +731,7 @@ func (s *PttService) handleProductImage(raw map[string]interface{}) {
 			clean := utils.CleanPttBarcode(barcode)
 			path, err := utils.DownloadImage(url, clean)
 			if err == nil {
-				database.UpdateProductImage(clean, path)
+				database.UpdateProductImage(ctx, clean, path)
 			}
 		}
 	}
@@ -396,6 +744,18 @@ func (s *PttService) getFloatFromRaw(raw map[string]interface{}, key string) flo
 	return 0
 }
 
+// rawNumber PTT'nin sayı ya da metin olarak döndürdüğü sayısal alanları çevirir
+func rawNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(n), ",", "."), 64)
+		return f
+	}
+	return 0
+}
+
 func (s *PttService) formatPhotos(rawPhotos interface{}) []map[string]interface{} {
 	formatted := []map[string]interface{}{}
 	if photos, ok := rawPhotos.([]interface{}); ok {
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"arbitraj-bot/database"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnchanged aynı fiyat/stok ilana daha önce başarıyla gönderildiği için isteğin atlandığını belirtir
+var ErrUnchanged = errors.New("değişiklik yok: aynı fiyat/stok daha önce gönderildi")
+
+// skipUnchanged force verilmediyse ve ilana son gönderilen içerik aynıysa gönderimi atlar
+func skipUnchanged(ctx context.Context, platform, externalID, hash string, force bool) bool {
+	if force || !database.IsPushUnchanged(ctx, platform, externalID, hash) {
+		return false
+	}
+	fmt.Printf("[ATLANDI] %s %s: fiyat/stok son gönderimle aynı.\n", platform, externalID)
+	return true
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/utils"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVerifyDelay = 2 * time.Minute
+	// Uyuşmazlıkta değerler en fazla bu kadar tekrar gönderilir, sonra FAILED olarak bırakılır
+	maxVerifyRepush = 3
+	priceTolerance  = 0.01
+)
+
+// scheduleVerification gönderilen değerleri kaydedip gecikmeli geri okuma işini kuyruğa alır
+func scheduleVerification(ctx context.Context, cfg *core.Config, platform, externalID, barcode string, price float64, stock int) {
+	delay := defaultVerifyDelay
+	if cfg != nil && cfg.VerifyDelaySeconds > 0 {
+		delay = time.Duration(cfg.VerifyDelaySeconds) * time.Second
+	}
+
+	database.SavePushVerification(ctx, platform, externalID, barcode, price, stock)
+	database.RequeueJob(ctx, JobVerifyPush, platform, verifyJobID(platform, externalID), delay, jobPolicies[JobVerifyPush].Timeout)
+}
+
+// İş tablosunda external_id tekil olduğundan platform ön eki ekleniyor
+func verifyJobID(platform, externalID string) string {
+	return platform + ":" + externalID
+}
+
+// verifyPush ilanı geri okuyup gönderilen fiyat/stok ile karşılaştırır.
+// Uyuşmazlıkta durum MISMATCH yazılır ve değerler tekrar gönderilir; iş geri çekilmeyle yeniden denenir.
+func verifyPush(ctx context.Context, job core.Job, m *Marketplaces) (bool, error) {
+	externalID := strings.TrimPrefix(job.ExternalID, job.Platform+":")
+	v, ok := database.GetPushVerification(ctx, job.Platform, externalID)
+	if !ok || v.Status == "VERIFIED" || v.Status == "FAILED" {
+		return true, nil
+	}
+
+	hb, pzr, ptt := m.HBFor(job.Platform), m.PazaramaFor(job.Platform), m.PttFor(job.Platform)
+	if hb == nil && pzr == nil && ptt == nil {
+		return false, unknownAccount(job.Platform)
+	}
+
+	var seenPrice float64
+	var seenStock int
+	var err error
+	switch core.PlatformOf(job.Platform) {
+	case "hb":
+		var l core.HBProduct
+		l, err = hb.GetListing(ctx, externalID)
+		seenPrice, seenStock = l.Price, l.AvailableStock
+	case "pazarama":
+		var p core.PazaramaProduct
+		p, err = pzr.GetProductByCode(ctx, externalID)
+		seenPrice, seenStock = p.SalePrice, p.StockCount
+	case "ptt":
+		seenPrice, seenStock, err = ptt.GetProductDetail(ctx, externalID)
+	default:
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("geri okuma başarısız: %v", err)
+	}
+
+	if math.Abs(seenPrice-v.ExpectedPrice) <= priceTolerance && seenStock == v.ExpectedStock {
+		database.SetVerificationResult(ctx, job.Platform, externalID, "VERIFIED", seenPrice, seenStock, "")
+		database.SetListingSyncResult(ctx, job.Platform, externalID, "VERIFIED", "Fiyat/stok platformda doğrulandı")
+		return true, nil
+	}
+
+	msg := fmt.Sprintf("Gönderilen %.2f TL / %d adet, platformda %.2f TL / %d adet görünüyor",
+		v.ExpectedPrice, v.ExpectedStock, seenPrice, seenStock)
+	utils.WriteToLogFile(fmt.Sprintf("[UYUŞMAZLIK] %s %s: %s", job.Platform, externalID, msg))
+	database.SetListingSyncResult(ctx, job.Platform, externalID, "MISMATCH", msg)
+	// Uygulanmamış gönderim "zaten gönderildi" sayılıp sonraki güncellemede atlanmamalı
+	database.ClearPushFingerprint(ctx, job.Platform, externalID)
+
+	if v.RepushCount >= maxVerifyRepush {
+		msg = fmt.Sprintf("%d tekrar gönderime rağmen uygulanmadı: %s", v.RepushCount, msg)
+		database.SetVerificationResult(ctx, job.Platform, externalID, "FAILED", seenPrice, seenStock, msg)
+		if v.Barcode != "" {
+			database.SetSyncStatus(ctx, v.Barcode, job.Platform, "VERIFY_FAILED", msg)
+		}
+		return true, nil
+	}
+
+	database.SetVerificationResult(ctx, job.Platform, externalID, "MISMATCH", seenPrice, seenStock, msg)
+	if v.Barcode != "" {
+		database.SetSyncStatus(ctx, v.Barcode, job.Platform, "MISMATCH", msg)
+	}
+
+	switch core.PlatformOf(job.Platform) {
+	case "hb":
+		err = hb.sendPriceStock(ctx, externalID, v.ExpectedPrice, v.ExpectedStock)
+	case "pazarama":
+		err = pzr.sendPriceStock(ctx, externalID, v.ExpectedPrice, v.ExpectedStock)
+	case "ptt":
+		_, _, err = ptt.sendStockPriceRest(ctx, externalID, v.ExpectedStock, v.ExpectedPrice)
+	}
+	if err != nil {
+		return false, fmt.Errorf("uyuşmazlık sonrası tekrar gönderilemedi: %v", err)
+	}
+
+	database.IncrementRepush(context.WithoutCancel(ctx), job.Platform, externalID)
+	return false, fmt.Errorf("uyuşmazlık: %s (tekrar gönderildi)", msg)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"context"
+	"fmt"
+	"math"
+)
+
+// BuildReorderSuggestions son 'lookbackDays' günlük satış hızına göre, stoğu 'targetCoverDays'
+// gün (+ tedarik süresi) yetecek seviyeye çıkaracak satın alma önerilerini üretir
+func BuildReorderSuggestions(ctx context.Context, lookbackDays int, targetCoverDays int) ([]core.ReorderSuggestion, error) {
+	velocity, err := database.GetSalesVelocity(ctx, lookbackDays)
+	if err != nil {
+		return nil, fmt.Errorf("satış hızı hesaplanamadı: %v", err)
+	}
+
+	candidates, err := database.GetReorderCandidates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tedarikçi listesi okunamadı: %v", err)
+	}
+
+	var suggestions []core.ReorderSuggestion
+	for _, c := range candidates {
+		daily := velocity[c.Barcode]
+		if daily <= 0 {
+			// Satışı olmayan ürün için sipariş önermiyoruz
+			continue
+		}
+
+		stock := c.Stock
+		if stock < 0 {
+			stock = 0
+		}
+		daysOfCover := float64(stock) / daily
+
+		need := daily*float64(targetCoverDays+c.LeadTimeDays) - float64(stock)
+		if need <= 0 {
+			continue
+		}
+
+		qty := int(math.Ceil(need))
+		if qty < c.MinOrderQty {
+			qty = c.MinOrderQty
+		}
+
+		suggestions = append(suggestions, core.ReorderSuggestion{
+			SupplierName:  c.SupplierName,
+			SupplierSku:   c.SupplierSku,
+			Barcode:       c.Barcode,
+			ProductName:   c.ProductName,
+			Stock:         c.Stock,
+			DailyVelocity: daily,
+			DaysOfCover:   daysOfCover,
+			SuggestedQty:  qty,
+			UnitCost:      c.UnitCost,
+			TotalCost:     float64(qty) * c.UnitCost,
+		})
+	}
+
+	fmt.Printf("[REORDER] %d ürün için satın alma önerisi oluşturuldu.\n", len(suggestions))
+	return suggestions, nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"arbitraj-bot/database"
+	"context"
+	"fmt"
+)
+
+// InferSalesFromSnapshots platformdaki son iki stok fotoğrafını karşılaştırır.
+// Bizim gönderdiğimiz stok değişiklikleri (stock_change_journal) ve kayıtlı siparişlerle
+// açıklanamayan düşüşler tahmini satış olarak estimated_sales tablosuna yazılır.
+func InferSalesFromSnapshots(ctx context.Context, platform string) int {
+	movements, err := database.GetLatestStockMovements(ctx, platform)
+	if err != nil {
+		fmt.Printf("[HATA] %s stok hareketleri okunamadı: %v\n", platform, err)
+		return 0
+	}
+
+	inferred := 0
+	for _, m := range movements {
+		// Bu sync'te gelmeyen ürünlerin son iki fotoğrafı zaten işlenmiş olabilir
+		if database.HasEstimatedSale(ctx, platform, m.Barcode, m.CurTakenAt) {
+			continue
+		}
+
+		// Aradaki son "push" yeni başlangıç noktasıdır: ondan önceki düşüşler bizim işimiz.
+		// Aynı stok barkodun her ilanına gönderildiğinden başlangıç ilan sayısıyla çarpılır.
+		baseline := m.PrevStock
+		if pushed, ok := database.GetLastPushedStock(ctx, platform, m.Barcode, m.PrevTakenAt, m.CurTakenAt); ok {
+			baseline = pushed * m.Listings
+		}
+
+		// Sipariş kaydı olan kısım zaten satıştır, tahmine tekrar eklemiyoruz
+		ordered := database.GetOrderedQuantity(ctx, platform, m.Barcode, m.PrevTakenAt, m.CurTakenAt)
+
+		unexplained := baseline - m.CurStock - ordered
+		if unexplained <= 0 {
+			continue
+		}
+
+		database.SaveEstimatedSale(ctx, platform, m.Barcode, unexplained, m.CurPrice, m.PrevTakenAt, m.CurTakenAt)
+		inferred += unexplained
+
+		// Paket satıldıysa bileşenlerin master stoğundan düşülmeli, bileşenleri içeren paketler yeniden hesaplanır
+		if _, ok := database.GetBundle(ctx, m.Barcode); ok {
+			ApplySale(ctx, m.Barcode, unexplained)
+		}
+		fmt.Printf("[TAHMİN] %s | %s: %d adet satış tahmin edildi (%d -> %d)\n", platform, m.Barcode, unexplained, baseline, m.CurStock)
+	}
+
+	if inferred > 0 {
+		fmt.Printf("[OK] %s için toplam %d adet tahmini satış kaydedildi.\n", platform, inferred)
+	}
+	return inferred
+}
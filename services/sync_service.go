@@ -3,14 +3,19 @@ package services
 import (
 	"arbitraj-bot/core"
 	"arbitraj-bot/database"
+	"context"
 	"fmt"
 	"log"
 )
 
-func SyncPttToMaster(pttProducts []core.PttProduct) {
+func SyncPttToMaster(ctx context.Context, pttProducts []core.PttProduct) {
 	fmt.Printf("\n[PTT] %d ürün Master DB ile eşleştiriliyor...\n", len(pttProducts))
 
 	for _, p := range pttProducts {
+		if ctx.Err() != nil {
+			fmt.Println("[DURDURULDU] PTT eşleştirmesi kesildi.")
+			return
+		}
 
 		query := `
 		UPDATE products SET 
@@ -22,7 +27,7 @@ func SyncPttToMaster(pttProducts []core.PttProduct) {
 			price = CASE WHEN price = 0.0 THEN ? ELSE price END
 		WHERE barcode = ?;`
 
-		result, err := database.DB.Exec(query, p.UrunId, p.MevcutStok, p.MevcutFiyat, p.Barkod)
+		result, err := database.DB.ExecContext(ctx, query, p.UrunId, p.MevcutStok, p.MevcutFiyat, p.Barkod)
 		if err != nil {
 			log.Printf("[HATA] PTT Eşleşme Hatası (%s): %v", p.Barkod, err)
 			continue
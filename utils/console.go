@@ -0,0 +1,10 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+)
+
+// Stdin menünün ve konsoldan girdi soran servislerin ortak okuyucusu. Ayrı okuyucu açılırsa
+// birinin tamponuna düşen girdi diğerine hiç ulaşmaz.
+var Stdin = bufio.NewReader(os.Stdin)
@@ -2,7 +2,11 @@ package utils
 
 import (
 	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -33,7 +37,9 @@ func SaveToExcel(products []core.PazaramaProduct) error {
 	return f.SaveAs(ExcelPath)
 }
 
-func ProcessExcelAndUpdate(client *resty.Client, token string) error {
+// ProcessExcelAndUpdate Excel'deki işlemleri Pazarama'ya toplu gönderir; force=false iken
+// son başarılı gönderimle aynı fiyat/stoğa sahip satırlar pakete alınmaz
+func ProcessExcelAndUpdate(ctx context.Context, client *resty.Client, token string, force bool) error {
 	f, err := excelize.OpenFile(ExcelPath)
 	if err != nil {
 		return err
@@ -46,6 +52,8 @@ func ProcessExcelAndUpdate(client *resty.Client, token string) error {
 	}
 
 	var updateItems []map[string]interface{}
+	hashes := make(map[string]string)
+	skipped := 0
 
 	fmt.Println("\n--- MEVCUT DURUM VE HESAPLAMALAR ---")
 	for i, row := range rows {
@@ -90,6 +98,14 @@ func ProcessExcelAndUpdate(client *resty.Client, token string) error {
 			// Gerçekleşen hesabı belirginleştir
 			fmt.Printf("   ==> GÜNCELLEME: Yeni Fiyat: %.2f | Yeni Stok: %d\n -------------------------------------------------------------\n", newPrice, newStock)
 
+			hash := PushFingerprint(newPrice, newStock, fmt.Sprintf("listPrice=%.2f", newPrice+1))
+			if !force && database.IsPushUnchanged(ctx, "pazarama", barcode, hash) {
+				fmt.Println("   ==> ATLANDI: Aynı fiyat/stok daha önce gönderildi.")
+				skipped++
+				continue
+			}
+			hashes[barcode] = hash
+
 			updateItems = append(updateItems, map[string]interface{}{
 				"code":       barcode,
 				"salePrice":  newPrice,
@@ -99,23 +115,47 @@ func ProcessExcelAndUpdate(client *resty.Client, token string) error {
 		}
 	}
 
+	if skipped > 0 {
+		fmt.Printf("\n[LOG] %d ürün değişmediği için atlandı.\n", skipped)
+	}
 	if len(updateItems) == 0 {
 		fmt.Println("\n[!] Güncellenecek bir değişiklik saptanmadı.")
 		return nil
 	}
 
 	if core.AskConfirmation(fmt.Sprintf("%d ürün için Pazarama V2 güncellensin mi?", len(updateItems))) {
-		resp, err := client.R().
+		url := "https://isortagimapi.pazarama.com/product/updatePriceAndInventory-v2"
+		body := map[string]interface{}{"items": updateItems}
+		if err := GuardWrite(ctx, "pazarama", "ExcelPriceStockUpdate", http.MethodPost, url, body); err != nil {
+			if errors.Is(err, ErrDryRun) {
+				return nil
+			}
+			return err
+		}
+
+		resp, err := client.R().SetContext(ctx).
 			SetAuthToken(token).
 			SetHeader("Content-Type", "application/json").
 			SetHeader("x-platform", "1").
-			SetBody(map[string]interface{}{"items": updateItems}).
-			Post("https://isortagimapi.pazarama.com/product/updatePriceAndInventory-v2")
+			SetBody(body).
+			Post(url)
 
-		if err == nil && resp.StatusCode() == 200 {
-			fmt.Printf("[BAŞARILI] Yanıt: %s\n", resp.String())
-		} else {
-			fmt.Printf("[-] Hata: %s\n", resp.String())
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode() != 200 {
+			return ResponseError("pazarama", "ExcelPriceStockUpdate", resp)
+		}
+
+		fmt.Printf("[BAŞARILI] Yanıt: %s\n", resp.String())
+		// Gönderim yapıldı; kayıtlar kapanış başlamış olsa da tutulmalı
+		bg := context.WithoutCancel(ctx)
+		for _, item := range updateItems {
+			code, _ := item["code"].(string)
+			stock, _ := item["stockCount"].(int)
+			price, _ := item["salePrice"].(float64)
+			database.RecordStockChange(bg, "pazarama", strings.TrimSuffix(code, "-PZR"), stock, "EXCEL")
+			database.SavePushFingerprint(bg, "pazarama", code, strings.TrimSuffix(code, "-PZR"), hashes[code], price, stock)
 		}
 	}
 	return nil
@@ -231,3 +271,301 @@ func ReadProductsFromExcel(path string) ([]core.ExcelProduct, error) {
 	}
 	return products, nil
 }
+
+const PurchaseOrderExcelPath = "./storage/Satin_Alma_Onerileri.xlsx"
+
+// SavePurchaseOrdersToExcel önerileri tedarikçi başına ayrı sayfa olacak şekilde Excel'e yazar
+func SavePurchaseOrdersToExcel(suggestions []core.ReorderSuggestion) (string, error) {
+	f := excelize.NewFile()
+	headers := []string{"Barkod", "Tedarikçi Kodu", "Ürün Adı", "Mevcut Stok", "Günlük Satış", "Kaç Gün Yeter", "ÖNERİLEN ADET", "Birim Maliyet", "Toplam Maliyet"}
+
+	rowBySheet := make(map[string]int)
+	for _, s := range suggestions {
+		sheet := purchaseSheetName(s.SupplierName)
+		if _, ok := rowBySheet[sheet]; !ok {
+			if len(rowBySheet) == 0 {
+				f.SetSheetName("Sheet1", sheet)
+			} else {
+				f.NewSheet(sheet)
+			}
+			for i, h := range headers {
+				cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+				f.SetCellValue(sheet, cell, h)
+			}
+			rowBySheet[sheet] = 1
+		}
+
+		rowBySheet[sheet]++
+		row := strconv.Itoa(rowBySheet[sheet])
+		f.SetCellValue(sheet, "A"+row, s.Barcode)
+		f.SetCellValue(sheet, "B"+row, s.SupplierSku)
+		f.SetCellValue(sheet, "C"+row, s.ProductName)
+		f.SetCellValue(sheet, "D"+row, s.Stock)
+		f.SetCellValue(sheet, "E"+row, fmt.Sprintf("%.2f", s.DailyVelocity))
+		f.SetCellValue(sheet, "F"+row, fmt.Sprintf("%.1f", s.DaysOfCover))
+		f.SetCellValue(sheet, "G"+row, s.SuggestedQty)
+		f.SetCellValue(sheet, "H"+row, s.UnitCost)
+		f.SetCellValue(sheet, "I"+row, s.TotalCost)
+	}
+
+	if err := f.SaveAs(PurchaseOrderExcelPath); err != nil {
+		return "", err
+	}
+	return PurchaseOrderExcelPath, nil
+}
+
+// Excel sayfa adları en fazla 31 karakter olabilir ve bazı karakterleri kabul etmez
+func purchaseSheetName(supplier string) string {
+	name := strings.TrimSpace(supplier)
+	if name == "" {
+		name = "Tedarikçisiz"
+	}
+	name = strings.NewReplacer(":", "", "\\", "", "/", "", "?", "", "*", "", "[", "", "]", "").Replace(name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	return name
+}
+
+// ReadSuppliersFromExcel tedarikçi listesini okur
+// Sütunlar: Barkod | Tedarikçi | Tedarikçi Kodu | Birim Maliyet | Min. Sipariş | Tedarik Süresi (Gün) | Stok (opsiyonel)
+func ReadSuppliersFromExcel(path string) ([]core.SupplierInfo, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, err
+	}
+
+	var suppliers []core.SupplierInfo
+	for i, row := range rows {
+		if i == 0 || len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		s := core.SupplierInfo{
+			Barcode:      strings.TrimSpace(row[0]),
+			SupplierName: strings.TrimSpace(row[1]),
+		}
+		if len(row) > 2 {
+			s.SupplierSku = strings.TrimSpace(row[2])
+		}
+		if len(row) > 3 {
+			s.UnitCost = StringToFloat(row[3])
+		}
+		if len(row) > 4 {
+			s.MinOrderQty = StringToInt(row[4])
+		}
+		if len(row) > 5 {
+			s.LeadTimeDays = StringToInt(row[5])
+		}
+		if len(row) > 6 && strings.TrimSpace(row[6]) != "" {
+			s.Stock = StringToInt(row[6])
+			s.StockReported = true
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, nil
+}
+
+// ReadOrdersFromExcel sipariş listesini okur, her satır bir sipariş satırıdır
+// Sütunlar: Platform (hb/pazarama/ptt) | Sipariş No | Barkod | Adet | Birim Fiyat | Tarih (opsiyonel, YYYY-MM-DD HH:MM:SS)
+func ReadOrdersFromExcel(path string) ([]core.OrderLine, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []core.OrderLine
+	for i, row := range rows {
+		if i == 0 || len(row) < 4 || strings.TrimSpace(row[1]) == "" || strings.TrimSpace(row[2]) == "" {
+			continue
+		}
+		o := core.OrderLine{
+			Platform:    strings.ToLower(strings.TrimSpace(row[0])),
+			OrderNumber: strings.TrimSpace(row[1]),
+			Barcode:     strings.TrimSpace(row[2]),
+			Quantity:    StringToInt(row[3]),
+		}
+		if o.Quantity <= 0 {
+			continue
+		}
+		if len(row) > 4 {
+			o.UnitPrice = StringToFloat(row[4])
+		}
+		if len(row) > 5 {
+			o.OrderDate = strings.TrimSpace(row[5])
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+const ProfitReportExcelPath = "./storage/Satis_Kar_Raporu.xlsx"
+
+// SaveProfitReportToExcel gerçek ve tahmini satışlardan oluşan kârlılık raporunu yazar
+func SaveProfitReportToExcel(summaries []core.SalesSummary) (string, error) {
+	f := excelize.NewFile()
+	sheet := "Kar Raporu"
+	f.SetSheetName("Sheet1", sheet)
+
+	headers := []string{"Barkod", "Ürün Adı", "Satış Adedi", "Tahmini Adet", "Ciro", "Maliyet", "Kâr"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, h)
+	}
+
+	for i, s := range summaries {
+		row := strconv.Itoa(i + 2)
+		f.SetCellValue(sheet, "A"+row, s.Barcode)
+		f.SetCellValue(sheet, "B"+row, s.ProductName)
+		f.SetCellValue(sheet, "C"+row, s.Quantity)
+		f.SetCellValue(sheet, "D"+row, s.EstimatedQty)
+		f.SetCellValue(sheet, "E"+row, s.Revenue)
+		f.SetCellValue(sheet, "F"+row, s.Cost)
+		f.SetCellValue(sheet, "G"+row, s.Profit)
+	}
+
+	if err := f.SaveAs(ProfitReportExcelPath); err != nil {
+		return "", err
+	}
+	return ProfitReportExcelPath, nil
+}
+
+const DelistedReportExcelPath = "./storage/Kaldirilan_Ilanlar_Raporu.xlsx"
+
+// SaveDelistedReportToExcel platformdan kaldırıldığı tespit edilen ilanları yazar
+func SaveDelistedReportToExcel(listings []core.DelistedListing) (string, error) {
+	f := excelize.NewFile()
+	sheet := "Kaldirilan Ilanlar"
+	f.SetSheetName("Sheet1", sheet)
+
+	headers := []string{"Platform", "Barkod", "Ürün Adı", "Platform ID", "Eksik Sync", "İlk Eksik", "DELISTED Tarihi"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, h)
+	}
+
+	for i, l := range listings {
+		row := strconv.Itoa(i + 2)
+		f.SetCellValue(sheet, "A"+row, l.Platform)
+		f.SetCellValue(sheet, "B"+row, l.Barcode)
+		f.SetCellValue(sheet, "C"+row, l.ProductName)
+		f.SetCellValue(sheet, "D"+row, l.PlatformID)
+		f.SetCellValue(sheet, "E"+row, l.MissCount)
+		f.SetCellValue(sheet, "F"+row, l.FirstMissedAt)
+		f.SetCellValue(sheet, "G"+row, l.DelistedAt)
+	}
+
+	if err := f.SaveAs(DelistedReportExcelPath); err != nil {
+		return "", err
+	}
+	return DelistedReportExcelPath, nil
+}
+
+// ReadBundlesFromExcel paket tanımlarını okur, her satır bir bileşendir
+// Sütunlar: Paket Barkod | Bileşen Barkod | Adet | Fiyat Kuralı (Örn: *0.95)
+func ReadBundlesFromExcel(path string) ([]core.Bundle, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	bundles := make(map[string]*core.Bundle)
+	for i, row := range rows {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		bundleBarcode := strings.TrimSpace(row[0])
+		componentBarcode := strings.TrimSpace(row[1])
+		if bundleBarcode == "" || componentBarcode == "" {
+			continue
+		}
+
+		b, ok := bundles[bundleBarcode]
+		if !ok {
+			b = &core.Bundle{BundleBarcode: bundleBarcode}
+			bundles[bundleBarcode] = b
+			order = append(order, bundleBarcode)
+		}
+
+		qty := 1
+		if len(row) > 2 && StringToInt(row[2]) > 0 {
+			qty = StringToInt(row[2])
+		}
+		if len(row) > 3 && strings.TrimSpace(row[3]) != "" {
+			b.PriceRule = strings.TrimSpace(row[3])
+		}
+		b.Components = append(b.Components, core.BundleComponent{Barcode: componentBarcode, Quantity: qty})
+	}
+
+	var result []core.Bundle
+	for _, barcode := range order {
+		result = append(result, *bundles[barcode])
+	}
+	return result, nil
+}
+
+// ReadVariantsFromExcel varyant gruplarını okur, her satır bir eksen değeridir
+// Sütunlar: Barkod | Grup Kodu | Özellik Adı (Örn: Renk) | Değer (Örn: Kırmızı)
+func ReadVariantsFromExcel(path string) ([]core.VariantInfo, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	variants := make(map[string]*core.VariantInfo)
+	for i, row := range rows {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		barcode := strings.TrimSpace(row[0])
+		groupCode := strings.TrimSpace(row[1])
+		if barcode == "" || groupCode == "" {
+			continue
+		}
+
+		v, ok := variants[barcode]
+		if !ok {
+			v = &core.VariantInfo{Barcode: barcode, GroupCode: groupCode}
+			variants[barcode] = v
+			order = append(order, barcode)
+		}
+
+		if len(row) > 3 && strings.TrimSpace(row[2]) != "" && strings.TrimSpace(row[3]) != "" {
+			v.Attributes = append(v.Attributes, core.VariantAttribute{
+				Name:  strings.TrimSpace(row[2]),
+				Value: strings.TrimSpace(row[3]),
+			})
+		}
+	}
+
+	var result []core.VariantInfo
+	for _, barcode := range order {
+		result = append(result, *variants[barcode])
+	}
+	return result, nil
+}
@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// PushFingerprint gönderimin etkin içeriğinin özetini üretir. Fiyat kuruş hassasiyetinde alınır;
+// content platforma özgü diğer gönderilen alanları (Örn: liste fiyatı) taşır.
+func PushFingerprint(price float64, stock int, content string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%.2f|%d|%s", price, stock, content)))
+	return hex.EncodeToString(sum[:])
+}
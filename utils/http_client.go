@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Host bazında iki istek arasındaki en kısa süre (pazaryeri limitlerinin altında kalacak şekilde)
+var hostRateLimits = map[string]time.Duration{
+	"isortagimapi.pazarama.com":            200 * time.Millisecond,
+	"listing-external-sit.hepsiburada.com": 250 * time.Millisecond,
+	"mpop-sit.hepsiburada.com":             250 * time.Millisecond,
+	"ws.pttavm.com":                        500 * time.Millisecond,
+}
+
+const defaultHostRateLimit = 200 * time.Millisecond
+
+// hostLimiter aynı host'a giden istekleri sabit aralıklarla sıraya koyar
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait sıradaki boşluğa kadar bekler; kapanışta (ctx iptali) beklemeyi keser
+func (l *hostLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type (
+	retryableKey   struct{}
+	guardExemptKey struct{}
+)
+
+// Retryable POST ile yapılan ama yan etkisi olmayan (sorgu, token) isteklerin de tekrar denenmesine izin verir
+func Retryable(r *resty.Request) *resty.Request {
+	return r.SetContext(context.WithValue(r.Context(), retryableKey{}, true))
+}
+
+// GuardExempt pazaryerindeki veriyi değiştirmeyen ama tekrarlanması da güvenli olmayan POST'ları (panel girişi,
+// OTP doğrulama) dry-run ve acil durdurma kontrolünden muaf tutar; istek tekrar denenmez
+func GuardExempt(r *resty.Request) *resty.Request {
+	return r.SetContext(context.WithValue(r.Context(), guardExemptKey{}, true))
+}
+
+// NewHTTPClient tüm servislerin paylaştığı, host bazlı hız sınırı ve
+// idempotent isteklerde Retry-After'a uyan üstel geri çekilmeli tekrar denemesi olan istemciyi kurar
+func NewHTTPClient() *resty.Client {
+	var mu sync.Mutex
+	limiters := make(map[string]*hostLimiter)
+
+	client := resty.New().
+		SetTimeout(60 * time.Second).
+		SetRetryCount(4).
+		SetRetryWaitTime(time.Second).
+		SetRetryMaxWaitTime(time.Minute)
+
+	client.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return nil
+		}
+
+		mu.Lock()
+		l, ok := limiters[u.Hostname()]
+		if !ok {
+			interval, known := hostRateLimits[u.Hostname()]
+			if !known {
+				interval = defaultHostRateLimit
+			}
+			l = &hostLimiter{interval: interval}
+			limiters[u.Hostname()] = l
+		}
+		mu.Unlock()
+
+		// Servislerde yakalanmamış bir yazma isteği kalırsa acil durdurma ve dry-run burada da gönderimi keser
+		if !isReadOnly(r) && !isGuardExempt(r) {
+			if err := GuardWrite(r.Context(), u.Hostname(), "HTTP", r.Method, r.URL, r.Body); err != nil {
+				return err
+			}
+		}
+
+		return l.wait(r.Context())
+	})
+
+	client.AddRetryCondition(func(resp *resty.Response, err error) bool {
+		var req *resty.Request
+		if resp != nil {
+			req = resp.Request
+		}
+		// Kapanış başladıysa tekrar denemeyip çağırana hemen dönüyoruz
+		if req == nil || !isIdempotent(req) || req.Context().Err() != nil {
+			return false
+		}
+		if err != nil {
+			return true
+		}
+		return resp.StatusCode() == http.StatusTooManyRequests || resp.StatusCode() >= 500
+	})
+
+	// Sunucu ne kadar beklememizi söylüyorsa o kadar bekliyoruz; 0 dönerse resty jitter'lı üstel beklemeye düşer
+	client.SetRetryAfter(func(c *resty.Client, resp *resty.Response) (time.Duration, error) {
+		if resp == nil {
+			return 0, nil
+		}
+		return parseRetryAfter(resp.Header().Get("Retry-After")), nil
+	})
+
+	client.AddRetryHook(func(resp *resty.Response, err error) {
+		if resp != nil && resp.Request != nil {
+			WriteToLogFile("[RETRY] " + resp.Request.Method + " " + resp.Request.URL + " -> " + resp.Status())
+		}
+	})
+
+	return client
+}
+
+func isIdempotent(r *resty.Request) bool {
+	switch r.Method {
+	case http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return isReadOnly(r)
+}
+
+// isReadOnly GET/HEAD/OPTIONS ile Retryable işaretli sorgu/token isteklerini ayırır
+func isReadOnly(r *resty.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	retryable, _ := r.Context().Value(retryableKey{}).(bool)
+	return retryable
+}
+
+func isGuardExempt(r *resty.Request) bool {
+	exempt, _ := r.Context().Value(guardExemptKey{}).(bool)
+	return exempt
+}
+
+// parseRetryAfter saniye ya da HTTP tarihi formatındaki Retry-After başlığını süreye çevirir
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
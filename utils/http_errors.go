@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"arbitraj-bot/core"
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ResponseError başarısız HTTP yanıtını core hata tiplerinden birine çevirir:
+// 401/403 AuthError, 429 RateLimited, diğerleri platform mesajıyla RemoteRejected olur
+func ResponseError(platform, operation string, resp *resty.Response) error {
+	body := strings.TrimSpace(resp.String())
+	switch resp.StatusCode() {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		msg := resp.Status()
+		if body != "" {
+			msg += ": " + body
+		}
+		return &core.AuthError{Platform: platform, Message: msg}
+	case http.StatusTooManyRequests:
+		return &core.RateLimited{Platform: platform, RetryAfter: parseRetryAfter(resp.Header().Get("Retry-After"))}
+	}
+	if body == "" {
+		body = resp.Status()
+	}
+	return &core.RemoteRejected{Platform: platform, Operation: operation, StatusCode: resp.StatusCode(), Message: body}
+}
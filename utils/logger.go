@@ -30,12 +30,13 @@ func LogJSON(v interface{}) {
 		fmt.Printf("[!] JSON Log Hatası: %v\n", err)
 		return
 	}
+	text := Redact(string(data))
 	// Ekrana bas (Karşılaştırma yapman için)
-	fmt.Println(string(data))
+	fmt.Println(text)
 
 	// Dosyaya da yaz (Kalıcı kayıt için)
 	if InfoLogger != nil {
-		InfoLogger.Println("\n" + string(data))
+		InfoLogger.Println("\n" + text)
 	}
 }
 
@@ -48,7 +49,7 @@ func WriteToLogFile(message string) {
 	defer f.Close()
 
 	currentTime := time.Now().Format("2006-01-02 15:04:05")
-	logMessage := fmt.Sprintf("[%s] %s\n", currentTime, message)
+	logMessage := fmt.Sprintf("[%s] %s\n", currentTime, Redact(message))
 
 	if _, err := f.WriteString(logMessage); err != nil {
 		fmt.Printf("[HATA] Yazma hatası: %v\n", err)
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Bu uzunluktan kısa değerler maskelenmez; "1", "abc" gibi değerler her yerde geçip logu bozar
+const minSecretLength = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret değerleri log, debug çıktısı ve kaydedilen istek gövdelerinde maskelenecekler listesine ekler
+func RegisterSecret(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	for _, v := range values {
+		if len(v) < minSecretLength || slices.Contains(secrets, v) {
+			continue
+		}
+		secrets = append(secrets, v)
+	}
+	// Uzun değer önce değişsin; bir secret diğerini içeriyorsa parçası açıkta kalmasın
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// Redact metindeki kayıtlı secret'ları *** ile değiştirir
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for _, v := range secrets {
+		if strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, "***")
+		}
+	}
+	return s
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter yazılan her şeyi Redact'tan geçirir (Örn: log.SetOutput için)
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
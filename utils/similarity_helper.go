@@ -2,6 +2,7 @@ package utils
 
 import (
 	"arbitraj-bot/database"
+	"context"
 	"sort"
 	"strings"
 
@@ -16,8 +17,12 @@ type MatchResult struct {
 	Score float64
 }
 
-func FindTopCategoryMatches(myCategoryName string, platform string) []MatchResult {
-	rows, _ := database.DB.Query("SELECT category_id, category_name FROM platform_categories WHERE platform = ? AND is_leaf = 1", platform)
+func FindTopCategoryMatches(ctx context.Context, myCategoryName string, platform string) []MatchResult {
+	// Kapanışta sorgu iptal edilir; aday yok sayılır
+	rows, err := database.DB.QueryContext(ctx, "SELECT category_id, category_name FROM platform_categories WHERE platform = ? AND is_leaf = 1", platform)
+	if err != nil {
+		return nil
+	}
 	defer rows.Close()
 
 	var results []MatchResult
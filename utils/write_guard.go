@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"arbitraj-bot/database"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrDryRun yazma isteğinin dry-run modunda gönderilmeden kaydedildiğini belirtir.
+// Çağıranlar bu hatada DB'ye başarı ya da hata durumu yazmaz.
+var ErrDryRun = errors.New("dry-run: istek gönderilmedi, dry_run_requests tablosuna kaydedildi")
+
+// ErrWritesHalted acil durdurma açıkken engellenip blocked_writes kuyruğuna alınan istek
+var ErrWritesHalted = errors.New("acil durdurma açık: istek gönderilmedi, blocked_writes kuyruğuna alındı")
+
+var dryRun atomic.Bool
+
+func SetDryRun(enabled bool) {
+	dryRun.Store(enabled)
+}
+
+func IsDryRun() bool {
+	return dryRun.Load()
+}
+
+// IsInterceptedWrite isteğin hiç gönderilmediğini (dry-run ya da acil durdurma) belirtir
+func IsInterceptedWrite(err error) bool {
+	return errors.Is(err, ErrDryRun) || errors.Is(err, ErrWritesHalted)
+}
+
+// GuardWrite her yazma isteğinden önce çağrılır. Acil durdurma açıksa isteği kuyruğa alır,
+// dry-run açıksa kaydeder; her iki durumda da çağıran isteği göndermeden dönen hatayla çıkar.
+// Kapanış başladıysa (ctx iptal) istek ne gönderilir ne kaydedilir.
+func GuardWrite(ctx context.Context, platform, operation, method, url string, body interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if halted, reason := database.IsWritesHalted(ctx); halted {
+		database.SaveBlockedWrite(ctx, platform, operation, method, url, writePayload(body))
+		fmt.Printf("[DURDURULDU] %s %s gönderilmedi (%s).\n", platform, operation, reason)
+		return ErrWritesHalted
+	}
+
+	if IsDryRun() {
+		payload := writePayload(body)
+		database.SaveDryRunRequest(ctx, platform, operation, method, url, payload)
+		fmt.Printf("[DRY-RUN] %s %s gönderilmedi (%d bayt kaydedildi).\n", platform, operation, len(payload))
+		return ErrDryRun
+	}
+	return nil
+}
+
+// writePayload SOAP/ham gövdeleri olduğu gibi, diğerlerini okunabilir JSON olarak saklar.
+// SOAP gövdeleri kullanıcı adı/şifre taşıdığından kayıttan önce secret'lar maskelenir.
+func writePayload(body interface{}) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return Redact(b)
+	case []byte:
+		return Redact(string(b))
+	}
+
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return Redact(fmt.Sprintf("%+v", body))
+	}
+	return Redact(string(data))
+}
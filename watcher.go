@@ -0,0 +1,144 @@
+package main
+
+import (
+	"arbitraj-bot/core"
+	"arbitraj-bot/database"
+	"arbitraj-bot/services"
+	"arbitraj-bot/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const watcherInterval = 30 * time.Second
+
+// StartWatcher is_dirty=1 olan ürünlerin fiyat/stok değişikliklerini bağlı oldukları pazaryerlerine iter.
+// Yalnızca ACTIVE ilanlara gönderim yapılır; kaldırılmış (DELISTED) ve kapatılacak (DEACTIVATE) ilanlar atlanır.
+// Her ilan, platform anahtarındaki mağazanın hesabıyla ve o mağazanın marjıyla gönderilir.
+func StartWatcher(ctx context.Context, markets *services.Marketplaces) {
+	for {
+		// Acil durdurma açıkken pazaryerlerine hiç çıkılmaz; değişiklikler kirli kalıp devamda gönderilir
+		if halted, reason := database.IsWritesHalted(ctx); halted {
+			utils.WriteToLogFile("[DURDURULDU] Watcher bekliyor: " + reason)
+		} else {
+			products, err := database.GetDirtyProducts(ctx)
+			if err != nil && ctx.Err() == nil {
+				utils.WriteToLogFile(fmt.Sprintf("[HATA] Watcher kirli ürünleri okuyamadı: %v", err))
+			}
+
+			// Kapanışta sıradaki ürüne geçilmez; gönderilmeyenler kirli kalır
+			for _, p := range products {
+				if ctx.Err() != nil {
+					break
+				}
+				pushProduct(ctx, p, markets)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			utils.WriteToLogFile("[LOG] Watcher durduruldu.")
+			return
+		case <-time.After(watcherInterval):
+		}
+	}
+}
+
+// pushProduct ürünün her aktif ilanına ayrı gönderim yapar; ilan tablosu boşsa eski tekil ID sütunlarına düşer
+func pushProduct(ctx context.Context, p core.Product, markets *services.Marketplaces) {
+	listings := p.Listings
+	if len(listings) == 0 {
+		listings = legacyListings(p)
+	}
+
+	// Gönderilen ilanın sonucu kapanış başlamış olsa da yazılmalı
+	bg := context.WithoutCancel(ctx)
+
+	// Platform bazında ilk hata; ürün satırındaki durum buna göre yazılır
+	results := make(map[string]error)
+	intercepted := false
+	for _, l := range listings {
+		if l.Status != "ACTIVE" {
+			continue
+		}
+
+		// config'den çıkarılmış mağazanın ilanı atlanır
+		var err error
+		if hb := markets.HBFor(l.Platform); hb != nil {
+			err = hb.UpdatePriceStock(ctx, l.ExternalID, p.Price*hb.Markup(p), p.Stock, false)
+		} else if pzr := markets.PazaramaFor(l.Platform); pzr != nil {
+			err = pzr.UpdatePriceStock(ctx, l.ExternalID, p.Price*pzr.Markup(p), p.Stock, false)
+		} else if ptt := markets.PttFor(l.Platform); ptt != nil {
+			_, err = ptt.UpdateStockPriceRest(ctx, l.ExternalID, p.Stock, p.Price*ptt.Markup(p), false)
+		} else {
+			continue
+		}
+
+		// Dry-run, acil durdurma ya da kapanışta istek gönderilmez; ürün kirli kalır, durum yazılmaz
+		if utils.IsInterceptedWrite(err) || (err != nil && ctx.Err() != nil) {
+			intercepted = true
+			continue
+		}
+
+		// Aynı fiyat/stok zaten gönderilmiş; istek atılmadan başarılı sayılır
+		if errors.Is(err, services.ErrUnchanged) {
+			database.SetListingSyncResult(bg, l.Platform, l.ExternalID, "SYNCED", "Değişiklik yok, gönderilmedi")
+			if _, seen := results[l.Platform]; !seen {
+				results[l.Platform] = nil
+			}
+			continue
+		}
+
+		if err != nil {
+			database.SetListingSyncResult(bg, l.Platform, l.ExternalID, "ERROR", err.Error())
+		} else {
+			database.SetListingSyncResult(bg, l.Platform, l.ExternalID, "SYNCED", "Fiyat/stok gönderildi")
+		}
+		if prev, seen := results[l.Platform]; !seen || prev == nil {
+			results[l.Platform] = err
+		}
+	}
+
+	// Gönderilemeyen ilan varsa durum yazılır ama ürün kirli kalır; kalan ilanlar devamda gönderilir
+	// (gönderilmiş olanlar gönderim özeti sayesinde tekrar gönderilmez)
+	for platform, err := range results {
+		recordPush(bg, p.Barcode, platform, err, intercepted)
+	}
+
+	if len(results) == 0 && !intercepted {
+		database.MarkClean(bg, p.Barcode)
+	}
+}
+
+// legacyListings listings tablosu oluşmadan önceki tekil ID sütunlarından ilan listesi üretir
+func legacyListings(p core.Product) []core.Listing {
+	var listings []core.Listing
+	add := func(platform, id, syncStatus string) {
+		if id == "" {
+			return
+		}
+		status := "ACTIVE"
+		if syncStatus == "DELISTED" {
+			status = "DELISTED"
+		}
+		listings = append(listings, core.Listing{Platform: platform, ExternalID: id, MasterBarcode: p.Barcode, Status: status})
+	}
+	add("hb", p.HbSku, p.HbSyncStatus)
+	add("pazarama", p.PazaramaId, p.PazaramaSyncStatus)
+	add("ptt", p.PttId, p.PttSyncStatus)
+	return listings
+}
+
+// recordPush platformun gönderim sonucunu yazar; keepDirty ise is_dirty'ye dokunulmaz
+func recordPush(ctx context.Context, barcode, platform string, err error, keepDirty bool) {
+	status, message := "SYNCED", "Fiyat/stok gönderildi"
+	if err != nil {
+		status, message = "ERROR", err.Error()
+	}
+	if keepDirty {
+		database.SetSyncStatus(ctx, barcode, platform, status, message)
+		return
+	}
+	database.UpdateSyncResult(ctx, barcode, platform, status, message)
+}